@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/hoshichaam/pln_backend_go/internal/database"
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	var (
+		userFlag   = flag.String("user", "", "Backfill satu user saja (UUID); kosong = semua user")
+		dryRunFlag = flag.Bool("dry-run", false, "Hitung jumlah baris tanpa menulis ke database")
+	)
+	flag.Parse()
+
+	database.ConnectDB()
+	defer database.DB.Close()
+
+	svc := services.NewBalanceBackfillService(
+		repositories.NewBackfillRepo(database.DB),
+		repositories.NewWalletRepo(database.DB),
+	)
+	report, err := svc.Run(context.Background(), services.BackfillOptions{
+		UserID: *userFlag,
+		DryRun: *dryRunFlag,
+	})
+	log.Printf("Backfill balance_after user=%d baris=%d dry-run=%v", report.Users, report.Rows, *dryRunFlag)
+	if err != nil {
+		log.Printf("Backfill berhenti dengan error: %v", err)
+		os.Exit(1)
+	}
+}
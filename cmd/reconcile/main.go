@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/hoshichaam/pln_backend_go/internal/database"
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	var (
+		userFlag  = flag.String("user", "", "Rekonsiliasi satu user saja (UUID); kosong = semua wallet")
+		applyFlag = flag.Bool("apply", false, "Tulis jurnal penyesuaian untuk selisih yang ditemukan")
+		jsonFlag  = flag.Bool("json", false, "Cetak laporan dalam format JSON")
+	)
+	flag.Parse()
+
+	database.ConnectDB()
+	defer database.DB.Close()
+
+	walletRepo := repositories.NewWalletRepo(database.DB)
+	ledgerSvc := services.NewLedgerService(repositories.NewLedgerRepo(database.DB))
+	// rekonsiliasi tidak membuka lot baru, jadi TTL lot tidak relevan di sini
+	lotSvc := services.NewLotService(repositories.NewLotRepo(database.DB), services.LotConfig{})
+	pointsSvc := services.NewPointsService(repositories.NewPointsRepo(database.DB), lotSvc)
+	reconSvc := services.NewReconciliationService(repositories.NewReconciliationRepo(database.DB), walletRepo, ledgerSvc, pointsSvc)
+
+	report, err := reconSvc.Run(context.Background(), services.ReconcileOptions{
+		UserID:      *userFlag,
+		Apply:       *applyFlag,
+		TriggeredBy: "cmd",
+	})
+	if err != nil {
+		log.Printf("Rekonsiliasi berhenti dengan error: %v", err)
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		for _, m := range report.Mismatches {
+			fmt.Printf("user=%s field=%s source=%s expected=%s actual=%s adjusted=%v\n",
+				m.UserID, m.Field, m.Source, m.Expected, m.Actual, m.Adjusted)
+		}
+		log.Printf("Rekonsiliasi run=%s user=%d selisih=%d penyesuaian=%d belum-terselesaikan=%d",
+			report.RunID, report.UsersChecked, len(report.Mismatches), report.Adjustments, report.Unresolved())
+	}
+
+	if err != nil || report.Unresolved() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type BeneficiaryHandler struct {
+	svc *services.BeneficiaryService
+}
+
+func NewBeneficiaryHandler(s *services.BeneficiaryService) *BeneficiaryHandler {
+	return &BeneficiaryHandler{svc: s}
+}
+
+func (h *BeneficiaryHandler) Create(c *fiber.Ctx) error {
+	var in services.CreateBeneficiaryInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if in.UserID != "" && in.UserID != callerID(c) {
+		return mapError(c, services.ErrForbidden{Msg: "tidak boleh menambah rekening tujuan untuk user lain"})
+	}
+	in.UserID = callerID(c)
+	res, err := h.svc.Create(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
+func (h *BeneficiaryHandler) List(c *fiber.Ctx) error {
+	if c.Params("userId") != callerID(c) {
+		return mapError(c, services.ErrForbidden{Msg: "tidak boleh melihat rekening tujuan user lain"})
+	}
+	res, err := h.svc.List(c.Context(), callerID(c))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *BeneficiaryHandler) Delete(c *fiber.Ctx) error {
+	if c.Params("userId") != callerID(c) {
+		return mapError(c, services.ErrForbidden{Msg: "tidak boleh menghapus rekening tujuan user lain"})
+	}
+	if err := h.svc.Delete(c.Context(), callerID(c), c.Params("beneficiaryId")); err != nil {
+		return mapError(c, err)
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type HoldHandler struct {
+	svc  *services.HoldService
+	idem *services.IdempotencyService
+}
+
+func NewHoldHandler(s *services.HoldService, idem *services.IdempotencyService) *HoldHandler {
+	return &HoldHandler{svc: s, idem: idem}
+}
+
+func (h *HoldHandler) Create(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "hold-create", h.create)
+}
+
+// Semua rute hold dipasang di belakang JWTRequired; hold hanya bisa dibuat dan
+// dikelola oleh pemilik wallet-nya.
+func (h *HoldHandler) create(c *fiber.Ctx) error {
+	var in services.CreateHoldInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if in.UserID != "" && in.UserID != callerID(c) {
+		return mapError(c, services.ErrForbidden{Msg: "tidak boleh membuat hold untuk user lain"})
+	}
+	in.UserID = callerID(c)
+	res, err := h.svc.Create(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
+func (h *HoldHandler) Get(c *fiber.Ctx) error {
+	res, err := h.svc.Get(c.Context(), callerID(c), c.Params("holdId"))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+// Capture dan Void memakai scope per hold supaya key yang sama untuk hold berbeda
+// tidak dianggap replay.
+func (h *HoldHandler) Capture(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "hold-capture:"+c.Params("holdId"), h.capture)
+}
+
+func (h *HoldHandler) capture(c *fiber.Ctx) error {
+	var in services.CaptureHoldInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	res, err := h.svc.Capture(c.Context(), callerID(c), c.Params("holdId"), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *HoldHandler) Void(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "hold-void:"+c.Params("holdId"), h.void)
+}
+
+func (h *HoldHandler) void(c *fiber.Ctx) error {
+	res, err := h.svc.Void(c.Context(), callerID(c), c.Params("holdId"))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type PayoutHandler struct {
+	svc  *services.PayoutService
+	idem *services.IdempotencyService
+}
+
+func NewPayoutHandler(s *services.PayoutService, idem *services.IdempotencyService) *PayoutHandler {
+	return &PayoutHandler{svc: s, idem: idem}
+}
+
+// PayoutNotification menerima notifikasi status payout dari provider di :provider;
+// rute lama /iris/notify jatuh ke Midtrans Iris. Signature bisa dihitung dari body
+// mentah, jadi body tidak boleh di-parse ulang sebelum diverifikasi.
+func (h *PayoutHandler) PayoutNotification(c *fiber.Ctx) error {
+	body := append([]byte(nil), c.Body()...)
+	provider := c.Params("provider", services.ProviderMidtrans)
+	if err := h.svc.HandleNotification(c.Context(), provider, body, requestHeader(c)); err != nil {
+		if _, ok := err.(services.ErrNotFoundResource); ok {
+			return c.Status(200).JSON(fiber.Map{"status": "ignored", "message": err.Error()})
+		}
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"status": "ok"})
+}
+
+func (h *PayoutHandler) ListApprovals(c *fiber.Ctx) error {
+	res, err := h.svc.ListApprovals(c.Context(), c.QueryInt("limit", 50))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *PayoutHandler) Approve(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "payout-approve:"+c.Params("payoutId"), h.approve)
+}
+
+func (h *PayoutHandler) approve(c *fiber.Ctx) error {
+	var in services.ApprovePayoutInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&in); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	in.PayoutID = c.Params("payoutId")
+	in.ApprovedBy, _ = c.Locals("userId").(string)
+	res, err := h.svc.Approve(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *PayoutHandler) Reject(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "payout-reject:"+c.Params("payoutId"), h.reject)
+}
+
+func (h *PayoutHandler) reject(c *fiber.Ctx) error {
+	var in services.RejectPayoutInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	in.PayoutID = c.Params("payoutId")
+	in.RejectedBy, _ = c.Locals("userId").(string)
+	res, err := h.svc.Reject(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *PayoutHandler) ListUnknown(c *fiber.Ctx) error {
+	res, err := h.svc.ListUnknown(c.Context(), c.QueryInt("limit", 50))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *PayoutHandler) Resolve(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "payout-resolve:"+c.Params("payoutId"), h.resolve)
+}
+
+func (h *PayoutHandler) resolve(c *fiber.Ctx) error {
+	var in services.ResolvePayoutInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	in.PayoutID = c.Params("payoutId")
+	in.ResolvedBy, _ = c.Locals("userId").(string)
+	res, err := h.svc.Resolve(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type ReversalHandler struct {
+	svc  *services.ReversalService
+	idem *services.IdempotencyService
+}
+
+func NewReversalHandler(s *services.ReversalService, idem *services.IdempotencyService) *ReversalHandler {
+	return &ReversalHandler{svc: s, idem: idem}
+}
+
+// Reverse hanya untuk admin; admin yang membalik dicatat bersama alasannya.
+func (h *ReversalHandler) Reverse(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "reversal:"+c.Params("transactionId"), h.reverse)
+}
+
+func (h *ReversalHandler) reverse(c *fiber.Ctx) error {
+	var in services.ReverseTransactionInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	in.TransactionID = c.Params("transactionId")
+	in.ReversedBy, _ = c.Locals("userId").(string)
+	res, err := h.svc.Reverse(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type StatementHandler struct {
+	svc *services.StatementService
+}
+
+func NewStatementHandler(s *services.StatementService) *StatementHandler {
+	return &StatementHandler{svc: s}
+}
+
+// Export mengirim e-statement sebagai CSV (default) atau PDF, misalnya
+// GET /wallet/statements/:userId?month=2025-01&format=pdf
+func (h *StatementHandler) Export(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", services.StatementFormatCSV))
+	if format != services.StatementFormatCSV && format != services.StatementFormatPDF {
+		return c.Status(400).JSON(fiber.Map{"error": "format harus csv atau pdf"})
+	}
+
+	st, err := h.svc.Open(c.Context(), c.Params("userId"), c.Query("month"))
+	if err != nil {
+		return mapError(c, err)
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	write := services.WriteStatementCSV
+	if format == services.StatementFormatPDF {
+		contentType = "application/pdf"
+		write = services.WriteStatementPDF
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, st.FileName(format)))
+
+	// Body di-stream setelah handler selesai; error di tengah jalan hanya bisa
+	// dicatat karena status 200 sudah terkirim.
+	userID := st.Header.UserID
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer st.Close()
+		if err := write(context.Background(), w, st); err != nil {
+			log.Printf("statement: gagal menulis %s user %s periode %s: %v", format, userID, st.Header.Period, err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("statement: koneksi user %s terputus: %v", userID, err)
+		}
+	})
+	return nil
+}
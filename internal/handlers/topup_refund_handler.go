@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/hoshichaam/pln_backend_go/internal/services"
+)
+
+type TopUpRefundHandler struct {
+	svc  *services.TopUpRefundService
+	idem *services.IdempotencyService
+}
+
+func NewTopUpRefundHandler(s *services.TopUpRefundService, idem *services.IdempotencyService) *TopUpRefundHandler {
+	return &TopUpRefundHandler{svc: s, idem: idem}
+}
+
+// Refund hanya untuk admin. Memanggil ulang untuk order yang refund-nya masih PENDING
+// mengirim ulang refund yang sama ke Midtrans.
+func (h *TopUpRefundHandler) Refund(c *fiber.Ctx) error {
+	return withIdempotency(c, h.idem, "topup-refund:"+c.Params("orderId"), h.refund)
+}
+
+func (h *TopUpRefundHandler) refund(c *fiber.Ctx) error {
+	var in services.RefundTopUpInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	in.OrderID = c.Params("orderId")
+	in.RequestedBy, _ = c.Locals("userId").(string)
+	res, err := h.svc.Refund(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
@@ -1,16 +1,81 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/hoshichaam/pln_backend_go/internal/services"
 )
 
+const headerIdempotencyKey = "Idempotency-Key"
+
 type WalletHandler struct {
-	svc *services.WalletService
+	svc  *services.WalletService
+	idem *services.IdempotencyService
 }
 
-func NewWalletHandler(s *services.WalletService) *WalletHandler {
-	return &WalletHandler{svc: s}
+func NewWalletHandler(s *services.WalletService, idem *services.IdempotencyService) *WalletHandler {
+	return &WalletHandler{svc: s, idem: idem}
+}
+
+func (h *WalletHandler) idempotent(c *fiber.Ctx, scope string, next fiber.Handler) error {
+	return withIdempotency(c, h.idem, scope, next)
+}
+
+// withIdempotency menjalankan next sekali per Idempotency-Key. Retry dengan key dan
+// body yang sama mendapat respons pertama tanpa menjalankan ulang mutasi saldo.
+// Key dipisah per pemilik request dan scope endpoint. Request tanpa header tetap
+// diproses seperti biasa.
+func withIdempotency(c *fiber.Ctx, idem *services.IdempotencyService, scope string, next fiber.Handler) error {
+	key := c.Get(headerIdempotencyKey)
+	if key == "" || idem == nil {
+		return next(c)
+	}
+
+	ticket, err := idem.Begin(c.Context(), idempotencyOwner(c), scope, key, services.RequestFingerprint(scope, c.Body()))
+	if err != nil {
+		return mapError(c, err)
+	}
+	if ticket.Replay != nil {
+		c.Set("Idempotent-Replayed", "true")
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Status(ticket.Replay.Code).Send(ticket.Replay.Body)
+	}
+
+	if err := next(c); err != nil {
+		if abortErr := idem.Abort(c.Context(), ticket); abortErr != nil {
+			log.Printf("idempotency: gagal melepas key %s/%s: %v", scope, key, abortErr)
+		}
+		return err
+	}
+
+	res := services.StoredResponse{
+		Code: c.Response().StatusCode(),
+		Body: bytes.Clone(c.Response().Body()),
+	}
+	if err := idem.Finish(c.Context(), ticket, res); err != nil {
+		log.Printf("idempotency: gagal menyimpan respons %s/%s: %v", scope, key, err)
+	}
+	return nil
+}
+
+// idempotencyOwner adalah user yang login, atau userId di body untuk endpoint yang
+// belum memakai JWT.
+func idempotencyOwner(c *fiber.Ctx) string {
+	if id := callerID(c); id != "" {
+		return id
+	}
+	var body struct {
+		UserID string `json:"userId"`
+	}
+	// body yang tidak valid tetap ditolak handler-nya; owner_id maksimal 64 karakter
+	if json.Unmarshal(c.Body(), &body) != nil || len(body.UserID) > 64 {
+		return ""
+	}
+	return strings.TrimSpace(body.UserID)
 }
 
 func (h *WalletHandler) GetSaldo(c *fiber.Ctx) error {
@@ -23,6 +88,10 @@ func (h *WalletHandler) GetSaldo(c *fiber.Ctx) error {
 }
 
 func (h *WalletHandler) KlaimVoucher(c *fiber.Ctx) error {
+	return h.idempotent(c, "klaim-voucher", h.klaimVoucher)
+}
+
+func (h *WalletHandler) klaimVoucher(c *fiber.Ctx) error {
 	var req services.KlaimVoucherInput
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
@@ -34,6 +103,10 @@ func (h *WalletHandler) KlaimVoucher(c *fiber.Ctx) error {
 }
 
 func (h *WalletHandler) TopUp(c *fiber.Ctx) error {
+	return h.idempotent(c, "topup", h.topUp)
+}
+
+func (h *WalletHandler) topUp(c *fiber.Ctx) error {
 	var in services.TopUpInput
 	if err := c.BodyParser(&in); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
@@ -45,7 +118,44 @@ func (h *WalletHandler) TopUp(c *fiber.Ctx) error {
 	return c.Status(201).JSON(fiber.Map{"data": res})
 }
 
+func (h *WalletHandler) ChargeTopUp(c *fiber.Ctx) error {
+	return h.idempotent(c, "topup-charge", h.chargeTopUp)
+}
+
+func (h *WalletHandler) chargeTopUp(c *fiber.Ctx) error {
+	var in services.ChargeTopUpInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	res, err := h.svc.ChargeTopUp(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
+func (h *WalletHandler) CancelTopUp(c *fiber.Ctx) error {
+	return h.idempotent(c, "topup-cancel:"+c.Params("orderId"), h.cancelTopUp)
+}
+
+func (h *WalletHandler) cancelTopUp(c *fiber.Ctx) error {
+	var in services.CancelTopUpInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	in.OrderID = c.Params("orderId")
+	res, err := h.svc.CancelTopUp(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
 func (h *WalletHandler) Withdraw(c *fiber.Ctx) error {
+	return h.idempotent(c, "withdraw", h.withdraw)
+}
+
+func (h *WalletHandler) withdraw(c *fiber.Ctx) error {
 	var in services.WithdrawInput
 	if err := c.BodyParser(&in); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
@@ -57,18 +167,105 @@ func (h *WalletHandler) Withdraw(c *fiber.Ctx) error {
 	return c.Status(201).JSON(fiber.Map{"data": res})
 }
 
+func (h *WalletHandler) Transfer(c *fiber.Ctx) error {
+	return h.idempotent(c, "transfer", h.transfer)
+}
+
+// transfer selalu mendebit user yang login; userId di body hanya boleh sama dengan
+// pemilik token.
+func (h *WalletHandler) transfer(c *fiber.Ctx) error {
+	var in services.TransferInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if in.UserID != "" && in.UserID != callerID(c) {
+		return mapError(c, services.ErrForbidden{Msg: "tidak boleh mentransfer saldo user lain"})
+	}
+	in.UserID = callerID(c)
+	res, err := h.svc.Transfer(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
+func (h *WalletHandler) RedeemPoints(c *fiber.Ctx) error {
+	return h.idempotent(c, "tukar-poin", h.redeemPoints)
+}
+
+func (h *WalletHandler) redeemPoints(c *fiber.Ctx) error {
+	var in services.RedeemPointsInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	res, err := h.svc.RedeemPoints(c.Context(), in)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
 func (h *WalletHandler) GetTransactions(c *fiber.Ctx) error {
 	userID := c.Params("userId")
 	if userID == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "userId wajib diisi"})
 	}
 
+	q := services.TransactionQuery{
+		UserID:    userID,
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		MinAmount: c.Query("minAmount"),
+		MaxAmount: c.Query("maxAmount"),
+		Cursor:    c.Query("cursor"),
+		Limit:     c.QueryInt("limit", 50),
+	}
+	// type bisa diulang (?type=A&type=B) atau dipisah koma (?type=A,B)
+	for _, v := range c.Context().QueryArgs().PeekMulti("type") {
+		q.Types = append(q.Types, strings.Split(string(v), ",")...)
+	}
+	page, err := h.svc.GetTransactions(c.Context(), q)
+	if err != nil {
+		return mapError(c, err)
+	}
+	var next any
+	if page.NextCursor != "" {
+		next = page.NextCursor
+	}
+	return c.Status(200).JSON(fiber.Map{"data": page.Items, "nextCursor": next})
+}
+
+func (h *WalletHandler) ExpiringSoon(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+	if userID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "userId wajib diisi"})
+	}
+	res, err := h.svc.ExpiringSoon(c.Context(), userID, c.QueryInt("days", 30))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(fiber.Map{"data": res})
+}
+
+func (h *WalletHandler) GetLimits(c *fiber.Ctx) error {
+	out, err := h.svc.GetLimits(c.Context(), c.Params("userId"))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(out)
+}
+
+func (h *WalletHandler) GetPointsHistory(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+	if userID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "userId wajib diisi"})
+	}
 	limit := c.QueryInt("limit", 50)
-	items, err := h.svc.GetTransactions(c.Context(), userID, limit)
+	res, err := h.svc.GetPointsHistory(c.Context(), userID, limit)
 	if err != nil {
 		return mapError(c, err)
 	}
-	return c.Status(200).JSON(fiber.Map{"data": items})
+	return c.Status(200).JSON(fiber.Map{"data": res})
 }
 
 func (h *WalletHandler) GetPaymentStatus(c *fiber.Ctx) error {
@@ -83,18 +280,12 @@ func (h *WalletHandler) GetPaymentStatus(c *fiber.Ctx) error {
 	return c.Status(200).JSON(fiber.Map{"data": res})
 }
 
-func (h *WalletHandler) MidtransNotification(c *fiber.Ctx) error {
-	if expected := h.svc.CallbackToken(); expected != "" {
-		if token := c.Get("X-Callback-Token"); token != expected {
-			return c.Status(403).JSON(fiber.Map{"error": "invalid callback token"})
-		}
-	}
-
-	var payload services.NotificationPayload
-	if err := c.BodyParser(&payload); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
-	}
-	if err := h.svc.HandleSnapNotification(c.Context(), payload); err != nil {
+// PaymentNotification menerima notifikasi pembayaran dari gateway di :provider; rute
+// lama /midtrans/notify tidak punya parameter itu dan jatuh ke Midtrans.
+func (h *WalletHandler) PaymentNotification(c *fiber.Ctx) error {
+	body := append([]byte(nil), c.Body()...)
+	provider := c.Params("provider", services.ProviderMidtrans)
+	if err := h.svc.HandlePaymentNotification(c.Context(), provider, body, requestHeader(c)); err != nil {
 		if _, ok := err.(services.ErrNotFoundResource); ok {
 			return c.Status(200).JSON(fiber.Map{"status": "ignored", "message": err.Error()})
 		}
@@ -116,17 +307,59 @@ func (h *WalletHandler) ListVouchers(c *fiber.Ctx) error {
 	return c.Status(200).JSON(fiber.Map{"data": items})
 }
 
+func (h *WalletHandler) GetWalletStatus(c *fiber.Ctx) error {
+	out, err := h.svc.GetWalletStatus(c.Context(), c.Params("userId"))
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(out)
+}
+
+// ChangeWalletStatus hanya untuk admin; admin yang mengubah dicatat di riwayat status.
+func (h *WalletHandler) ChangeWalletStatus(c *fiber.Ctx) error {
+	var req services.ChangeWalletStatusInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.UserID = c.Params("userId")
+	req.ChangedBy, _ = c.Locals("userId").(string)
+	out, err := h.svc.ChangeWalletStatus(c.Context(), req)
+	if err != nil {
+		return mapError(c, err)
+	}
+	return c.Status(200).JSON(out)
+}
+
+// callerID adalah user pemilik token JWT; kosong bila rute tidak memakai JWTRequired.
+func callerID(c *fiber.Ctx) string {
+	id, _ := c.Locals("userId").(string)
+	return id
+}
+
+// requestHeader membungkus c.Get untuk verifikasi notifikasi di service.
+func requestHeader(c *fiber.Ctx) func(string) string {
+	return func(key string) string { return c.Get(key) }
+}
+
 // mapper error
 func mapError(c *fiber.Ctx, err error) error {
 	switch err.(type) {
 	case services.ErrBadRequest:
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrForbidden:
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
 	case services.ErrConflict:
 		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
 	case services.ErrInsufficientBalance:
 		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrLimitExceeded:
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrIdempotencyMismatch:
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
 	case services.ErrNotFoundResource:
 		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrWalletUnavailable:
+		return c.Status(423).JSON(fiber.Map{"error": err.Error(), "walletStatus": err.(services.ErrWalletUnavailable).Status})
 	default:
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
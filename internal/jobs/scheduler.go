@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler menjalankan job berkala di goroutine terpisah dan menunggu
+// semuanya berhenti saat shutdown.
+type Scheduler struct {
+	wg sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every menjalankan fn setiap interval sampai ctx dibatalkan. Error dan panic
+// hanya dicatat ke log supaya satu job gagal tidak menjatuhkan server.
+func (s *Scheduler) Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		log.Printf("job %s: interval tidak valid, job tidak dijalankan", name)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		log.Printf("job %s: dijadwalkan setiap %s", name, interval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runOnce(ctx, name, fn)
+			}
+		}
+	}()
+}
+
+func runOnce(ctx context.Context, name string, fn func(context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job %s: panic: %v", name, r)
+		}
+	}()
+	started := time.Now()
+	if err := fn(ctx); err != nil {
+		log.Printf("job %s: gagal setelah %s: %v", name, time.Since(started), err)
+	}
+}
+
+// Wait menunggu semua job selesai setelah ctx dibatalkan.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	response "github.com/hoshichaam/pln_backend_go/pkg/response"
+)
+
+// AdminRequired harus dipasang setelah JWTRequired; hanya user dengan role admin yang diteruskan.
+func AdminRequired(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("userId").(string)
+		if userID == "" {
+			return response.Error(c, fiber.StatusUnauthorized, "authentication required")
+		}
+		return requireAdmin(c, db, userID)
+	}
+}
+
+// SelfOrAdminRequired harus dipasang setelah JWTRequired; request diteruskan bila
+// path parameter param sama dengan user pemilik token, atau user tersebut admin.
+func SelfOrAdminRequired(db *sql.DB, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("userId").(string)
+		if userID == "" {
+			return response.Error(c, fiber.StatusUnauthorized, "authentication required")
+		}
+		if c.Params(param) == userID {
+			return c.Next()
+		}
+		return requireAdmin(c, db, userID)
+	}
+}
+
+func requireAdmin(c *fiber.Ctx, db *sql.DB, userID string) error {
+	var role string
+	err := db.QueryRowContext(c.Context(), `SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return response.Error(c, fiber.StatusUnauthorized, "user not found")
+	}
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "failed to check user role")
+	}
+	if role != "admin" {
+		return response.Error(c, fiber.StatusForbidden, "admin access required")
+	}
+	return c.Next()
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+
+	response "github.com/hoshichaam/pln_backend_go/pkg/response"
+)
+
+// UserRateLimit membatasi max request per window untuk tiap user. Dipasang setelah
+// JWTRequired; request tanpa user dibatasi per IP.
+func UserRateLimit(max int, window time.Duration) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: window,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			if userID, _ := c.Locals("userId").(string); userID != "" {
+				return "user:" + userID
+			}
+			return "ip:" + c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return response.Error(c, fiber.StatusTooManyRequests, "too many requests, try again later")
+		},
+	})
+}
@@ -1,11 +1,13 @@
 package models
 
+import "github.com/hoshichaam/pln_backend_go/pkg/money"
+
 // SaldoResponse adalah struct yang kita kirimkan sebagai JSON untuk endpoint /saldo
 type SaldoResponse struct {
-	TotalSaldo      float64 `json:"total_saldo"`
-	SaldoPendapatan float64 `json:"saldo_pendapatan"`
-	SaldoRefund     float64 `json:"saldo_refund"`
-	EvPoin          int     `json:"ev_poin"`
+	TotalSaldo      money.Amount `json:"total_saldo"`
+	SaldoPendapatan money.Amount `json:"saldo_pendapatan"`
+	SaldoRefund     money.Amount `json:"saldo_refund"`
+	EvPoin          int          `json:"ev_poin"`
 }
 
 // KlaimRequest adalah struct untuk membaca body JSON sa// models/models.go
@@ -16,7 +18,7 @@ type KlaimRequest struct {
 
 // TarikSaldoRequest adalah struct untuk membaca body JSON saat tarik saldo
 type TarikSaldoRequest struct {
-	UserID    string  `json:"userId"    validate:"required"`
-	Jumlah    float64 `json:"jumlah"    validate:"required,gt=0"`
-	TipeSaldo string  `json:"tipeSaldo" validate:"required,oneof=Pendapatan Refund"`
+	UserID    string       `json:"userId"    validate:"required"`
+	Jumlah    money.Amount `json:"jumlah"    validate:"required,gt=0"`
+	TipeSaldo string       `json:"tipeSaldo" validate:"required,oneof=Pendapatan Refund"`
 }
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+type BalanceBackfillRow struct {
+	ID                 string
+	Type               string
+	Amount             money.Amount
+	BalanceAfterTopup  *money.Amount
+	BalanceAfterRedeem *money.Amount
+}
+
+// BackfillRepo dipakai cmd/backfill-balance untuk mengisi balance_after histori lama.
+type BackfillRepo interface {
+	// ListUsersMissingBalanceAfter mengembalikan user yang masih punya transaksi tanpa
+	// balance_after, terurut, mulai setelah afterUserID.
+	ListUsersMissingBalanceAfter(ctx context.Context, afterUserID string, limit int) ([]string, error)
+	// ListTransactionsForBackfill mengembalikan seluruh transaksi user dari yang terbaru.
+	ListTransactionsForBackfill(ctx context.Context, tx DBTX, userID string) ([]BalanceBackfillRow, error)
+	SetBalanceAfter(ctx context.Context, tx DBTX, id string, topup, redeem money.Amount) error
+}
+
+type backfillRepo struct{ db *sql.DB }
+
+func NewBackfillRepo(db *sql.DB) BackfillRepo { return &backfillRepo{db: db} }
+
+func (r *backfillRepo) ListUsersMissingBalanceAfter(ctx context.Context, afterUserID string, limit int) ([]string, error) {
+	const q = `
+		SELECT DISTINCT user_id
+		FROM transactions
+		WHERE balance_after_total IS NULL
+		  AND ($1::uuid IS NULL OR user_id > $1::uuid)
+		ORDER BY user_id
+		LIMIT $2
+	`
+	var after *string
+	if afterUserID != "" {
+		after = &afterUserID
+	}
+	rows, err := r.db.QueryContext(ctx, q, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *backfillRepo) ListTransactionsForBackfill(ctx context.Context, tx DBTX, userID string) ([]BalanceBackfillRow, error) {
+	const q = `
+		SELECT id, tipe_transaksi, jumlah, balance_after_topup, balance_after_redeem
+		FROM transactions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := tx.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []BalanceBackfillRow
+	for rows.Next() {
+		var rec BalanceBackfillRow
+		if err := rows.Scan(&rec.ID, &rec.Type, &rec.Amount, &rec.BalanceAfterTopup, &rec.BalanceAfterRedeem); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
+
+func (r *backfillRepo) SetBalanceAfter(ctx context.Context, tx DBTX, id string, topup, redeem money.Amount) error {
+	const q = `
+		UPDATE transactions
+		SET balance_after_total = $2::numeric + $3::numeric,
+		    balance_after_topup = $2,
+		    balance_after_redeem = $3
+		WHERE id = $1
+	`
+	_, err := tx.ExecContext(ctx, q, id, topup, redeem)
+	return err
+}
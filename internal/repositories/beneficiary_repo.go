@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type BeneficiaryRecord struct {
+	ID                string
+	UserID            string
+	Alias             sql.NullString
+	BankCode          string
+	BankName          sql.NullString
+	AccountNumber     string
+	AccountHolderName string
+	ValidatedAt       time.Time
+	CreatedAt         time.Time
+}
+
+type CreateBeneficiaryParams struct {
+	UserID            string
+	Alias             string
+	BankCode          string
+	BankName          string
+	AccountNumber     string
+	AccountHolderName string
+	ValidatedAt       time.Time
+}
+
+type BeneficiaryRepo interface {
+	// CreateBeneficiary mengembalikan ok=false bila rekening yang sama sudah tersimpan.
+	CreateBeneficiary(ctx context.Context, p CreateBeneficiaryParams) (rec BeneficiaryRecord, ok bool, err error)
+	// GetBeneficiary hanya mengembalikan rekening milik userID yang belum dihapus.
+	GetBeneficiary(ctx context.Context, userID, id string) (BeneficiaryRecord, error)
+	ListBeneficiaries(ctx context.Context, userID string) ([]BeneficiaryRecord, error)
+	// DeleteBeneficiary menghapus secara soft delete agar payout lama tetap bisa dilacak.
+	DeleteBeneficiary(ctx context.Context, userID, id string, now time.Time) error
+}
+
+type beneficiaryRepo struct{ db *sql.DB }
+
+func NewBeneficiaryRepo(db *sql.DB) BeneficiaryRepo { return &beneficiaryRepo{db: db} }
+
+const beneficiaryColumns = `
+	id, user_id, alias, bank_code, bank_name, account_number, account_holder_name,
+	validated_at, created_at
+`
+
+func scanBeneficiary(row rowScanner) (BeneficiaryRecord, error) {
+	var rec BeneficiaryRecord
+	err := row.Scan(
+		&rec.ID,
+		&rec.UserID,
+		&rec.Alias,
+		&rec.BankCode,
+		&rec.BankName,
+		&rec.AccountNumber,
+		&rec.AccountHolderName,
+		&rec.ValidatedAt,
+		&rec.CreatedAt,
+	)
+	return rec, err
+}
+
+func (r *beneficiaryRepo) CreateBeneficiary(ctx context.Context, p CreateBeneficiaryParams) (BeneficiaryRecord, bool, error) {
+	const q = `
+		INSERT INTO beneficiaries (user_id, alias, bank_code, bank_name, account_number, account_holder_name, validated_at)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5, $6, $7)
+		ON CONFLICT (user_id, bank_code, account_number) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING ` + beneficiaryColumns
+	rec, err := scanBeneficiary(r.db.QueryRowContext(ctx, q,
+		p.UserID, p.Alias, p.BankCode, p.BankName, p.AccountNumber, p.AccountHolderName, p.ValidatedAt,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, false, nil
+	}
+	return rec, err == nil, err
+}
+
+func (r *beneficiaryRepo) GetBeneficiary(ctx context.Context, userID, id string) (BeneficiaryRecord, error) {
+	const q = `
+		SELECT ` + beneficiaryColumns + `
+		FROM beneficiaries
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	`
+	rec, err := scanBeneficiary(r.db.QueryRowContext(ctx, q, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "beneficiary not found"}
+	}
+	return rec, err
+}
+
+func (r *beneficiaryRepo) ListBeneficiaries(ctx context.Context, userID string) ([]BeneficiaryRecord, error) {
+	const q = `
+		SELECT ` + beneficiaryColumns + `
+		FROM beneficiaries
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BeneficiaryRecord
+	for rows.Next() {
+		rec, err := scanBeneficiary(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (r *beneficiaryRepo) DeleteBeneficiary(ctx context.Context, userID, id string, now time.Time) error {
+	const q = `
+		UPDATE beneficiaries
+		SET deleted_at = $3
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	`
+	res, err := r.db.ExecContext(ctx, q, id, userID, now)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound{Message: "beneficiary not found"}
+	}
+	return nil
+}
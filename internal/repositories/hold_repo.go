@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Status hold saldo (enum wallet_hold_status).
+const (
+	HoldStatusActive   = "ACTIVE"
+	HoldStatusCaptured = "CAPTURED"
+	HoldStatusVoided   = "VOIDED"
+	HoldStatusExpired  = "EXPIRED"
+)
+
+type HoldRecord struct {
+	ID             string
+	UserID         string
+	BalanceType    string // "topup" atau "redeem"
+	Amount         money.Amount
+	CapturedAmount money.NullAmount
+	Status         string
+	ReferensiID    sql.NullString
+	Deskripsi      sql.NullString
+	TransactionID  sql.NullString
+	ExpiresAt      time.Time
+	ReleasedAt     sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type CreateHoldParams struct {
+	UserID      string
+	BalanceType string
+	Amount      money.Amount
+	ReferensiID *string
+	Deskripsi   string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+type FinishHoldParams struct {
+	ID             string
+	Status         string
+	CapturedAmount *money.Amount
+	TransactionID  *string
+	ReleasedAt     time.Time
+}
+
+type HoldRepo interface {
+	CreateHold(ctx context.Context, tx DBTX, p CreateHoldParams) (HoldRecord, error)
+	GetHold(ctx context.Context, id string) (HoldRecord, error)
+	GetHoldForUpdate(ctx context.Context, tx DBTX, id string) (HoldRecord, error)
+	// FinishHold menutup hold ACTIVE menjadi CAPTURED/VOIDED/EXPIRED.
+	FinishHold(ctx context.Context, tx DBTX, p FinishHoldParams) error
+
+	// AdjustHeld menambah (delta positif) atau mengurangi saldo ditahan di wallet_summary.
+	// Baris wallet_summary harus sudah dikunci oleh pemanggil.
+	AdjustHeld(ctx context.Context, tx DBTX, userID, balanceType string, delta money.Amount) error
+
+	// ListExpiredHoldIDs mengembalikan hold ACTIVE yang sudah lewat expires_at.
+	ListExpiredHoldIDs(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
+
+type holdRepo struct{ db *sql.DB }
+
+func NewHoldRepo(db *sql.DB) HoldRepo { return &holdRepo{db: db} }
+
+const holdColumns = `
+	id, user_id, balance_type, amount, captured_amount, status, referensi_id, deskripsi,
+	transaction_id, expires_at, released_at, created_at, updated_at
+`
+
+func scanHold(row *sql.Row) (HoldRecord, error) {
+	var rec HoldRecord
+	err := row.Scan(
+		&rec.ID,
+		&rec.UserID,
+		&rec.BalanceType,
+		&rec.Amount,
+		&rec.CapturedAmount,
+		&rec.Status,
+		&rec.ReferensiID,
+		&rec.Deskripsi,
+		&rec.TransactionID,
+		&rec.ExpiresAt,
+		&rec.ReleasedAt,
+		&rec.CreatedAt,
+		&rec.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "hold not found"}
+	}
+	return rec, err
+}
+
+func (r *holdRepo) CreateHold(ctx context.Context, tx DBTX, p CreateHoldParams) (HoldRecord, error) {
+	q := `
+		INSERT INTO wallet_holds (user_id, balance_type, amount, referensi_id, deskripsi, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $7)
+		RETURNING ` + holdColumns
+	return scanHold(tx.QueryRowContext(ctx, q,
+		p.UserID, p.BalanceType, p.Amount, p.ReferensiID, p.Deskripsi, p.ExpiresAt, p.CreatedAt,
+	))
+}
+
+func (r *holdRepo) GetHold(ctx context.Context, id string) (HoldRecord, error) {
+	q := `SELECT ` + holdColumns + ` FROM wallet_holds WHERE id = $1`
+	return scanHold(r.db.QueryRowContext(ctx, q, id))
+}
+
+func (r *holdRepo) GetHoldForUpdate(ctx context.Context, tx DBTX, id string) (HoldRecord, error) {
+	q := `SELECT ` + holdColumns + ` FROM wallet_holds WHERE id = $1 FOR UPDATE`
+	return scanHold(tx.QueryRowContext(ctx, q, id))
+}
+
+func (r *holdRepo) FinishHold(ctx context.Context, tx DBTX, p FinishHoldParams) error {
+	const q = `
+		UPDATE wallet_holds
+		SET status = $2::wallet_hold_status,
+		    captured_amount = $3,
+		    transaction_id = $4,
+		    released_at = $5
+		WHERE id = $1 AND status = 'ACTIVE'
+	`
+	res, err := tx.ExecContext(ctx, q, p.ID, p.Status, p.CapturedAmount, p.TransactionID, p.ReleasedAt)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound{Message: "active hold not found"}
+	}
+	return nil
+}
+
+func (r *holdRepo) AdjustHeld(ctx context.Context, tx DBTX, userID, balanceType string, delta money.Amount) error {
+	const q = `
+		UPDATE wallet_summary
+		SET held_topup  = held_topup  + CASE WHEN $2 = 'topup'  THEN $3::numeric ELSE 0 END,
+		    held_redeem = held_redeem + CASE WHEN $2 = 'redeem' THEN $3::numeric ELSE 0 END
+		WHERE user_id = $1
+	`
+	res, err := tx.ExecContext(ctx, q, userID, balanceType, delta)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound{Message: "wallet not found"}
+	}
+	return nil
+}
+
+func (r *holdRepo) ListExpiredHoldIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	const q = `
+		SELECT id
+		FROM wallet_holds
+		WHERE status = 'ACTIVE' AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, q, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	IdempotencyInProgress = "IN_PROGRESS"
+	IdempotencyCompleted  = "COMPLETED"
+)
+
+type IdempotencyRecord struct {
+	ID           string
+	OwnerID      string
+	Scope        string
+	Key          string
+	RequestHash  string
+	Status       string
+	ResponseCode sql.NullInt64
+	ResponseBody []byte
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type ReserveIdempotencyKeyParams struct {
+	OwnerID     string // kosong untuk request tanpa pemilik yang dikenal
+	Scope       string
+	Key         string
+	RequestHash string
+	ExpiresAt   time.Time
+	LockedUntil time.Time // batas lease IN_PROGRESS
+}
+
+type IdempotencyRepo interface {
+	// Reserve mencatat key baru berstatus IN_PROGRESS. Key yang kedaluwarsa, atau yang
+	// lease IN_PROGRESS-nya habis dengan request_hash sama, diambil alih dengan id baru.
+	// Selain itu record lama dikembalikan dengan reserved=false.
+	Reserve(ctx context.Context, p ReserveIdempotencyKeyParams) (rec IdempotencyRecord, reserved bool, err error)
+	Complete(ctx context.Context, id string, code int, body []byte) error
+	Release(ctx context.Context, id string) error
+}
+
+type idempotencyRepo struct{ db *sql.DB }
+
+func NewIdempotencyRepo(db *sql.DB) IdempotencyRepo { return &idempotencyRepo{db: db} }
+
+func (r *idempotencyRepo) Reserve(ctx context.Context, p ReserveIdempotencyKeyParams) (IdempotencyRecord, bool, error) {
+	// key kedaluwarsa boleh dipakai ulang; id diganti agar Complete/Release dari
+	// pemegang lease lama tidak menimpa baris yang sudah diambil alih
+	const insert = `
+		INSERT INTO idempotency_keys (owner_id, scope, idem_key, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (owner_id, scope, idem_key) DO UPDATE
+		SET id            = gen_random_uuid(),
+		    request_hash  = EXCLUDED.request_hash,
+		    status        = 'IN_PROGRESS',
+		    response_code = NULL,
+		    response_body = NULL,
+		    expires_at    = EXCLUDED.expires_at,
+		    locked_until  = EXCLUDED.locked_until,
+		    created_at    = now()
+		WHERE idempotency_keys.expires_at <= now()
+		   OR (idempotency_keys.status = 'IN_PROGRESS'
+		       AND idempotency_keys.locked_until <= now()
+		       AND idempotency_keys.request_hash = EXCLUDED.request_hash)
+		RETURNING ` + idempotencyColumns + `
+	`
+	rec, err := scanIdempotency(r.db.QueryRowContext(ctx, insert, p.OwnerID, p.Scope, p.Key, p.RequestHash, p.ExpiresAt, p.LockedUntil))
+	if err == nil {
+		return rec, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return rec, false, err
+	}
+
+	const q = `
+		SELECT ` + idempotencyColumns + `
+		FROM idempotency_keys
+		WHERE owner_id = $1 AND scope = $2 AND idem_key = $3
+	`
+	rec, err = scanIdempotency(r.db.QueryRowContext(ctx, q, p.OwnerID, p.Scope, p.Key))
+	return rec, false, err
+}
+
+const idempotencyColumns = `id, owner_id, scope, idem_key, request_hash, status, response_code, response_body, expires_at, created_at`
+
+func scanIdempotency(row *sql.Row) (IdempotencyRecord, error) {
+	var rec IdempotencyRecord
+	err := row.Scan(
+		&rec.ID,
+		&rec.OwnerID,
+		&rec.Scope,
+		&rec.Key,
+		&rec.RequestHash,
+		&rec.Status,
+		&rec.ResponseCode,
+		&rec.ResponseBody,
+		&rec.ExpiresAt,
+		&rec.CreatedAt,
+	)
+	return rec, err
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, id string, code int, body []byte) error {
+	const q = `
+		UPDATE idempotency_keys
+		SET status = 'COMPLETED', response_code = $2, response_body = $3
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, q, id, code, body)
+	return err
+}
+
+func (r *idempotencyRepo) Release(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE id = $1 AND status = 'IN_PROGRESS'`, id)
+	return err
+}
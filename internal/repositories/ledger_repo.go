@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Jenis akun ledger (enum ledger_account_kind).
+const (
+	LedgerAccountUserTopup        = "USER_TOPUP"
+	LedgerAccountUserRedeem       = "USER_REDEEM"
+	LedgerAccountMidtransClearing = "MIDTRANS_CLEARING"
+	LedgerAccountPayoutClearing   = "PAYOUT_CLEARING"
+	LedgerAccountVoucherFunding   = "VOUCHER_FUNDING"
+	LedgerAccountOpeningBalance   = "OPENING_BALANCE"
+	LedgerAccountReconAdjustment  = "RECONCILIATION_ADJUSTMENT"
+	LedgerAccountMerchant         = "MERCHANT_SETTLEMENT"
+	LedgerAccountPointsFunding    = "POINTS_FUNDING"
+	LedgerAccountExpiredBalance   = "EXPIRED_BALANCE"
+)
+
+// Sisi posting (enum ledger_side).
+const (
+	LedgerDebit  = "DEBIT"
+	LedgerCredit = "CREDIT"
+)
+
+// ledgerNormalSide menentukan sisi normal akun: akun saldo user, utang payout,
+// utang ke merchant dan pendapatan saldo hangus bertambah di sisi kredit, akun
+// sistem lain di sisi debit.
+func ledgerNormalSide(kind string) string {
+	switch kind {
+	case LedgerAccountUserTopup, LedgerAccountUserRedeem, LedgerAccountPayoutClearing, LedgerAccountMerchant, LedgerAccountExpiredBalance:
+		return LedgerCredit
+	default:
+		return LedgerDebit
+	}
+}
+
+type LedgerAccountRecord struct {
+	ID         string
+	UserID     sql.NullString
+	Kind       string
+	NormalSide string
+	Balance    money.Amount
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type CreateLedgerEntryParams struct {
+	EntryType     string
+	TransactionID *string
+	ReferensiID   *string
+	Deskripsi     string
+	CreatedAt     time.Time
+}
+
+type CreateLedgerPostingParams struct {
+	EntryID   string
+	AccountID string
+	Side      string
+	Amount    money.Amount
+	CreatedAt time.Time
+}
+
+type LedgerRepo interface {
+	// LockWallet memastikan baris wallet_summary ada lalu menguncinya (FOR UPDATE).
+	LockWallet(ctx context.Context, tx DBTX, userID string) error
+	GetOrCreateAccount(ctx context.Context, tx DBTX, userID *string, kind string) (LedgerAccountRecord, error)
+	ListUserAccounts(ctx context.Context, userID string) ([]LedgerAccountRecord, error)
+
+	CreateEntry(ctx context.Context, tx DBTX, p CreateLedgerEntryParams) (string, error)
+	CreatePosting(ctx context.Context, tx DBTX, p CreateLedgerPostingParams) error
+	// ApplyToBalance menambah saldo berjalan akun user sebesar delta dan mengembalikan saldo baru.
+	ApplyToBalance(ctx context.Context, tx DBTX, accountID string, delta money.Amount) (money.Amount, error)
+
+	// RebuildUserBalances menghitung ulang saldo berjalan akun user dari ledger_postings.
+	RebuildUserBalances(ctx context.Context, tx DBTX, userID string) error
+
+	// SyncWalletSummary menurunkan ulang wallet_summary dari saldo akun ledger user.
+	SyncWalletSummary(ctx context.Context, tx DBTX, userID string, now time.Time) error
+}
+
+type ledgerRepo struct{ db *sql.DB }
+
+func NewLedgerRepo(db *sql.DB) LedgerRepo { return &ledgerRepo{db: db} }
+
+func (r *ledgerRepo) LockWallet(ctx context.Context, tx DBTX, userID string) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO wallet_summary (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	); err != nil {
+		return err
+	}
+	var locked string
+	err := tx.QueryRowContext(ctx,
+		`SELECT user_id FROM wallet_summary WHERE user_id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound{Message: "wallet not found"}
+	}
+	return err
+}
+
+func (r *ledgerRepo) GetOrCreateAccount(ctx context.Context, tx DBTX, userID *string, kind string) (LedgerAccountRecord, error) {
+	const insert = `
+		INSERT INTO ledger_accounts (user_id, kind, normal_side)
+		VALUES ($1, $2::ledger_account_kind, $3::ledger_side)
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, insert, userID, kind, ledgerNormalSide(kind)); err != nil {
+		return LedgerAccountRecord{}, err
+	}
+
+	const q = `
+		SELECT id, user_id, kind, normal_side, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE kind = $2::ledger_account_kind AND user_id IS NOT DISTINCT FROM $1
+	`
+	var rec LedgerAccountRecord
+	err := tx.QueryRowContext(ctx, q, userID, kind).Scan(
+		&rec.ID,
+		&rec.UserID,
+		&rec.Kind,
+		&rec.NormalSide,
+		&rec.Balance,
+		&rec.CreatedAt,
+		&rec.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "ledger account not found"}
+	}
+	return rec, err
+}
+
+func (r *ledgerRepo) ListUserAccounts(ctx context.Context, userID string) ([]LedgerAccountRecord, error) {
+	const q = `
+		SELECT id, user_id, kind, normal_side, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE user_id = $1
+		ORDER BY kind
+	`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []LedgerAccountRecord
+	for rows.Next() {
+		var rec LedgerAccountRecord
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Kind, &rec.NormalSide, &rec.Balance, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
+
+func (r *ledgerRepo) CreateEntry(ctx context.Context, tx DBTX, p CreateLedgerEntryParams) (string, error) {
+	const q = `
+		INSERT INTO ledger_entries (entry_type, transaction_id, referensi_id, deskripsi, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	var id string
+	err := tx.QueryRowContext(ctx, q, p.EntryType, p.TransactionID, p.ReferensiID, p.Deskripsi, p.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *ledgerRepo) CreatePosting(ctx context.Context, tx DBTX, p CreateLedgerPostingParams) error {
+	const q = `
+		INSERT INTO ledger_postings (entry_id, account_id, side, amount, created_at)
+		VALUES ($1, $2, $3::ledger_side, $4, $5)
+	`
+	_, err := tx.ExecContext(ctx, q, p.EntryID, p.AccountID, p.Side, p.Amount, p.CreatedAt)
+	return err
+}
+
+func (r *ledgerRepo) ApplyToBalance(ctx context.Context, tx DBTX, accountID string, delta money.Amount) (money.Amount, error) {
+	const q = `
+		UPDATE ledger_accounts
+		SET balance = balance + $2
+		WHERE id = $1
+		RETURNING balance
+	`
+	var balance money.Amount
+	err := tx.QueryRowContext(ctx, q, accountID, delta).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound{Message: "ledger account not found"}
+	}
+	return balance, err
+}
+
+func (r *ledgerRepo) RebuildUserBalances(ctx context.Context, tx DBTX, userID string) error {
+	const q = `
+		UPDATE ledger_accounts a
+		SET balance = COALESCE((
+		      SELECT SUM(CASE WHEN p.side = a.normal_side THEN p.amount ELSE -p.amount END)
+		      FROM ledger_postings p
+		      WHERE p.account_id = a.id
+		    ), 0)
+		WHERE a.user_id = $1
+	`
+	_, err := tx.ExecContext(ctx, q, userID)
+	return err
+}
+
+func (r *ledgerRepo) SyncWalletSummary(ctx context.Context, tx DBTX, userID string, now time.Time) error {
+	const q = `
+		INSERT INTO wallet_summary (user_id, total_saldo, saldo_topup, saldo_redeem, ev_poin, updated_at)
+		SELECT $1,
+		       COALESCE(SUM(balance), 0),
+		       COALESCE(SUM(balance) FILTER (WHERE kind = 'USER_TOPUP'), 0),
+		       COALESCE(SUM(balance) FILTER (WHERE kind = 'USER_REDEEM'), 0),
+		       0,
+		       $2
+		FROM ledger_accounts
+		WHERE user_id = $1 AND kind IN ('USER_TOPUP', 'USER_REDEEM')
+		ON CONFLICT (user_id) DO UPDATE
+		SET total_saldo  = EXCLUDED.total_saldo,
+		    saldo_topup  = EXCLUDED.saldo_topup,
+		    saldo_redeem = EXCLUDED.saldo_redeem,
+		    updated_at   = EXCLUDED.updated_at
+	`
+	_, err := tx.ExecContext(ctx, q, userID, now)
+	return err
+}
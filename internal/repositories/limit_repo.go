@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// LimitRuleRecord: batas bernilai nol berarti tanpa batas (kolomnya NULL).
+type LimitRuleRecord struct {
+	Tier       string
+	Operation  string
+	PerTxnMax  money.Amount
+	DailyMax   money.Amount
+	MonthlyMax money.Amount
+}
+
+// LimitUsageEntry adalah satu pemakaian limit. Reference unik per user dan operasi,
+// jadi mencatat ulang sumber yang sama tidak menghitungnya dua kali.
+type LimitUsageEntry struct {
+	UserID     string
+	Operation  string
+	Reference  string
+	Amount     money.Amount
+	OccurredAt time.Time
+}
+
+type LimitRepo interface {
+	GetUserTier(ctx context.Context, userID string) (string, error)
+	GetRule(ctx context.Context, tier, operation string) (LimitRuleRecord, error)
+
+	// GetUsage menjumlahkan pemakaian yang belum dilepas sejak daySince dan
+	// monthSince. Untuk TOP_UP, order PENDING yang belum dibayar ikut dihitung.
+	GetUsage(ctx context.Context, userID, operation string, daySince, monthSince time.Time) (daily, monthly money.Amount, err error)
+	GetUsageTx(ctx context.Context, tx DBTX, userID, operation string, daySince, monthSince time.Time) (daily, monthly money.Amount, err error)
+	// AddUsage mencatat entri; false bila reference yang sama sudah tercatat.
+	AddUsage(ctx context.Context, tx DBTX, e LimitUsageEntry) (added bool, err error)
+	// SetUsageReleased melepas entri (releasedAt terisi) atau memulihkannya (nil).
+	SetUsageReleased(ctx context.Context, tx DBTX, userID, operation, reference string, releasedAt *time.Time) error
+}
+
+type limitRepo struct{ db *sql.DB }
+
+func NewLimitRepo(db *sql.DB) LimitRepo { return &limitRepo{db: db} }
+
+func (r *limitRepo) GetUserTier(ctx context.Context, userID string) (string, error) {
+	var tier string
+	err := r.db.QueryRowContext(ctx, `SELECT tier FROM users WHERE id = $1`, userID).Scan(&tier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound{Message: "user not found"}
+	}
+	return tier, err
+}
+
+func (r *limitRepo) GetRule(ctx context.Context, tier, operation string) (LimitRuleRecord, error) {
+	const q = `
+		SELECT tier, operation, per_txn_max, daily_max, monthly_max
+		FROM limit_rules
+		WHERE tier = $1 AND operation = $2
+	`
+	var (
+		rec                    LimitRuleRecord
+		perTxn, daily, monthly money.NullAmount
+	)
+	err := r.db.QueryRowContext(ctx, q, tier, operation).Scan(
+		&rec.Tier,
+		&rec.Operation,
+		&perTxn,
+		&daily,
+		&monthly,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "limit rule not found"}
+	}
+	rec.PerTxnMax, rec.DailyMax, rec.MonthlyMax = perTxn.Amount, daily.Amount, monthly.Amount
+	return rec, err
+}
+
+func (r *limitRepo) GetUsage(ctx context.Context, userID, operation string, daySince, monthSince time.Time) (money.Amount, money.Amount, error) {
+	return r.getUsage(ctx, r.db, userID, operation, daySince, monthSince)
+}
+
+func (r *limitRepo) GetUsageTx(ctx context.Context, tx DBTX, userID, operation string, daySince, monthSince time.Time) (money.Amount, money.Amount, error) {
+	return r.getUsage(ctx, tx, userID, operation, daySince, monthSince)
+}
+
+func (r *limitRepo) getUsage(ctx context.Context, exec DBTX, userID, operation string, daySince, monthSince time.Time) (money.Amount, money.Amount, error) {
+	// order PENDING dihitung dari created_at; setelah settlement order keluar dari
+	// bagian kedua dan digantikan entri dengan occurred_at yang sama
+	const q = `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE at > $3), 0),
+			COALESCE(SUM(amount), 0)
+		FROM (
+			SELECT amount, occurred_at AS at
+			FROM limit_usage_entries
+			WHERE user_id = $1 AND operation = $2 AND released_at IS NULL AND occurred_at > $4
+			UNION ALL
+			SELECT gross_amount, created_at
+			FROM payment_orders
+			WHERE $2 = 'TOP_UP' AND user_id = $1
+			  AND status = 'PENDING' AND NOT balance_applied AND created_at > $4
+		) usage
+	`
+	var daily, monthly money.Amount
+	err := exec.QueryRowContext(ctx, q, userID, operation, daySince, monthSince).Scan(&daily, &monthly)
+	return daily, monthly, err
+}
+
+func (r *limitRepo) AddUsage(ctx context.Context, tx DBTX, e LimitUsageEntry) (bool, error) {
+	const q = `
+		INSERT INTO limit_usage_entries (user_id, operation, reference, amount, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, operation, reference) DO NOTHING
+	`
+	res, err := tx.ExecContext(ctx, q, e.UserID, e.Operation, e.Reference, e.Amount, e.OccurredAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *limitRepo) SetUsageReleased(ctx context.Context, tx DBTX, userID, operation, reference string, releasedAt *time.Time) error {
+	const q = `
+		UPDATE limit_usage_entries
+		SET released_at = $4
+		WHERE user_id = $1 AND operation = $2 AND reference = $3
+	`
+	_, err := tx.ExecContext(ctx, q, userID, operation, reference, releasedAt)
+	return err
+}
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Jenis lot (enum balance_lot_kind).
+const (
+	LotKindRedeem = "REDEEM"
+	LotKindPoints = "POINTS" // Quantity berisi jumlah poin dalam satuan bulat
+)
+
+type LotRecord struct {
+	ID         string
+	UserID     string
+	Kind       string
+	SourceType string
+	SourceID   string
+	Original   money.Amount
+	Remaining  money.Amount
+	ExpiresAt  sql.NullTime
+	ExpiredAt  sql.NullTime
+	CreatedAt  time.Time
+}
+
+type CreateLotParams struct {
+	UserID     string
+	Kind       string
+	SourceType string
+	SourceID   string
+	Quantity   money.Amount
+	ExpiresAt  *time.Time // nil = tidak kedaluwarsa
+	CreatedAt  time.Time
+}
+
+type LotRepo interface {
+	CreateLot(ctx context.Context, tx DBTX, p CreateLotParams) error
+	// ListOpenLotsForUpdate mengunci lot yang masih bersisa dengan urutan konsumsi FIFO.
+	ListOpenLotsForUpdate(ctx context.Context, tx DBTX, userID, kind string) ([]LotRecord, error)
+	// ListDueLotsForUpdate mengunci lot bersisa yang sudah lewat expires_at.
+	ListDueLotsForUpdate(ctx context.Context, tx DBTX, userID string, now time.Time) ([]LotRecord, error)
+	// SetRemaining memperbarui sisa lot; expiredAt diisi bila lot habis karena kedaluwarsa.
+	SetRemaining(ctx context.Context, tx DBTX, id string, remaining money.Amount, expiredAt *time.Time) error
+
+	// ListDueUserIDs mengembalikan user dengan lot kedaluwarsa, terurut, mulai setelah afterUserID.
+	ListDueUserIDs(ctx context.Context, now time.Time, afterUserID string, limit int) ([]string, error)
+	ListExpiring(ctx context.Context, userID string, until time.Time) ([]LotRecord, error)
+}
+
+type lotRepo struct{ db *sql.DB }
+
+func NewLotRepo(db *sql.DB) LotRepo { return &lotRepo{db: db} }
+
+func (r *lotRepo) CreateLot(ctx context.Context, tx DBTX, p CreateLotParams) error {
+	const q = `
+		INSERT INTO balance_lots (user_id, kind, source_type, source_id, original, remaining, expires_at, created_at, updated_at)
+		VALUES ($1, $2::balance_lot_kind, $3, $4, $5, $5, $6, $7, $7)
+	`
+	_, err := tx.ExecContext(ctx, q, p.UserID, p.Kind, p.SourceType, p.SourceID, p.Quantity, p.ExpiresAt, p.CreatedAt)
+	return err
+}
+
+func (r *lotRepo) ListOpenLotsForUpdate(ctx context.Context, tx DBTX, userID, kind string) ([]LotRecord, error) {
+	const q = `
+		SELECT id, user_id, kind, source_type, source_id, original, remaining, expires_at, expired_at, created_at
+		FROM balance_lots
+		WHERE user_id = $1 AND kind = $2::balance_lot_kind AND remaining > 0
+		ORDER BY expires_at ASC NULLS LAST, created_at ASC, id ASC
+		FOR UPDATE
+	`
+	return queryLots(ctx, tx, q, userID, kind)
+}
+
+func (r *lotRepo) ListDueLotsForUpdate(ctx context.Context, tx DBTX, userID string, now time.Time) ([]LotRecord, error) {
+	const q = `
+		SELECT id, user_id, kind, source_type, source_id, original, remaining, expires_at, expired_at, created_at
+		FROM balance_lots
+		WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2
+		ORDER BY expires_at ASC, created_at ASC, id ASC
+		FOR UPDATE
+	`
+	return queryLots(ctx, tx, q, userID, now)
+}
+
+func (r *lotRepo) SetRemaining(ctx context.Context, tx DBTX, id string, remaining money.Amount, expiredAt *time.Time) error {
+	const q = `
+		UPDATE balance_lots
+		SET remaining = $2, expired_at = COALESCE($3, expired_at)
+		WHERE id = $1
+	`
+	_, err := tx.ExecContext(ctx, q, id, remaining, expiredAt)
+	return err
+}
+
+func (r *lotRepo) ListDueUserIDs(ctx context.Context, now time.Time, afterUserID string, limit int) ([]string, error) {
+	const q = `
+		SELECT DISTINCT user_id
+		FROM balance_lots
+		WHERE remaining > 0 AND expires_at <= $1
+		  AND ($2::uuid IS NULL OR user_id > $2::uuid)
+		ORDER BY user_id
+		LIMIT $3
+	`
+	var after *string
+	if afterUserID != "" {
+		after = &afterUserID
+	}
+	rows, err := r.db.QueryContext(ctx, q, now, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *lotRepo) ListExpiring(ctx context.Context, userID string, until time.Time) ([]LotRecord, error) {
+	const q = `
+		SELECT id, user_id, kind, source_type, source_id, original, remaining, expires_at, expired_at, created_at
+		FROM balance_lots
+		WHERE user_id = $1 AND remaining > 0 AND expires_at <= $2
+		ORDER BY expires_at ASC, created_at ASC
+	`
+	return queryLots(ctx, r.db, q, userID, until)
+}
+
+func queryLots(ctx context.Context, exec DBTX, q string, args ...any) ([]LotRecord, error) {
+	rows, err := exec.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []LotRecord
+	for rows.Next() {
+		var rec LotRecord
+		if err := rows.Scan(
+			&rec.ID,
+			&rec.UserID,
+			&rec.Kind,
+			&rec.SourceType,
+			&rec.SourceID,
+			&rec.Original,
+			&rec.Remaining,
+			&rec.ExpiresAt,
+			&rec.ExpiredAt,
+			&rec.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
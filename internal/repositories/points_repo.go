@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+type PointsRuleRecord struct {
+	EventType         string
+	FlatPoints        int
+	PointsPerUnit     int
+	UnitAmount        money.Amount
+	MinAmount         money.Amount
+	MaxPointsPerEvent sql.NullInt64
+	DailyCap          sql.NullInt64
+	Active            bool
+}
+
+type PointsEntryRecord struct {
+	ID            string
+	UserID        string
+	EventType     string
+	Delta         int
+	BalanceAfter  int
+	SourceID      string
+	TransactionID sql.NullString
+	Deskripsi     sql.NullString
+	CreatedAt     time.Time
+}
+
+type CreatePointsEntryParams struct {
+	UserID        string
+	EventType     string
+	Delta         int
+	BalanceAfter  int
+	SourceID      string
+	TransactionID *string
+	Deskripsi     string
+	CreatedAt     time.Time
+}
+
+type PointsRepo interface {
+	GetRule(ctx context.Context, tx DBTX, eventType string) (PointsRuleRecord, error)
+
+	// LockPoints mengunci baris wallet_summary user dan mengembalikan ev_poin saat ini.
+	LockPoints(ctx context.Context, tx DBTX, userID string) (int, error)
+	// SetPoints menulis ev_poin hasil perhitungan ledger poin ke wallet_summary.
+	SetPoints(ctx context.Context, tx DBTX, userID string, balance int) error
+
+	// CreateEntry mencatat perubahan poin. false berarti event sumber ini sudah pernah dicatat.
+	CreateEntry(ctx context.Context, tx DBTX, p CreatePointsEntryParams) (bool, error)
+	SumAwardedSince(ctx context.Context, tx DBTX, userID, eventType string, since time.Time) (int, error)
+	SumEntries(ctx context.Context, tx DBTX, userID string) (int, error)
+	ListEntries(ctx context.Context, userID string, limit int) ([]PointsEntryRecord, error)
+}
+
+type pointsRepo struct{ db *sql.DB }
+
+func NewPointsRepo(db *sql.DB) PointsRepo { return &pointsRepo{db: db} }
+
+func (r *pointsRepo) GetRule(ctx context.Context, tx DBTX, eventType string) (PointsRuleRecord, error) {
+	const q = `
+		SELECT event_type, flat_points, points_per_unit, unit_amount, min_amount,
+		       max_points_per_event, daily_cap, active
+		FROM points_rules
+		WHERE event_type = $1
+	`
+	var rec PointsRuleRecord
+	err := tx.QueryRowContext(ctx, q, eventType).Scan(
+		&rec.EventType,
+		&rec.FlatPoints,
+		&rec.PointsPerUnit,
+		&rec.UnitAmount,
+		&rec.MinAmount,
+		&rec.MaxPointsPerEvent,
+		&rec.DailyCap,
+		&rec.Active,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "points rule not found"}
+	}
+	return rec, err
+}
+
+func (r *pointsRepo) LockPoints(ctx context.Context, tx DBTX, userID string) (int, error) {
+	var poin int
+	err := tx.QueryRowContext(ctx,
+		`SELECT ev_poin FROM wallet_summary WHERE user_id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&poin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound{Message: "wallet not found"}
+	}
+	return poin, err
+}
+
+func (r *pointsRepo) SetPoints(ctx context.Context, tx DBTX, userID string, balance int) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE wallet_summary SET ev_poin = $2, updated_at = now() WHERE user_id = $1`,
+		userID, balance,
+	)
+	return err
+}
+
+func (r *pointsRepo) CreateEntry(ctx context.Context, tx DBTX, p CreatePointsEntryParams) (bool, error) {
+	const q = `
+		INSERT INTO points_ledger (user_id, event_type, delta, balance_after, source_id, transaction_id, deskripsi, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+		ON CONFLICT (user_id, event_type, source_id) DO NOTHING
+	`
+	res, err := tx.ExecContext(ctx, q,
+		p.UserID, p.EventType, p.Delta, p.BalanceAfter, p.SourceID, p.TransactionID, p.Deskripsi, p.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *pointsRepo) SumAwardedSince(ctx context.Context, tx DBTX, userID, eventType string, since time.Time) (int, error) {
+	const q = `
+		SELECT COALESCE(SUM(delta), 0)
+		FROM points_ledger
+		WHERE user_id = $1 AND event_type = $2 AND delta > 0 AND created_at >= $3
+	`
+	var total int
+	err := tx.QueryRowContext(ctx, q, userID, eventType, since).Scan(&total)
+	return total, err
+}
+
+func (r *pointsRepo) SumEntries(ctx context.Context, tx DBTX, userID string) (int, error) {
+	var total int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(delta), 0) FROM points_ledger WHERE user_id = $1`,
+		userID,
+	).Scan(&total)
+	return total, err
+}
+
+func (r *pointsRepo) ListEntries(ctx context.Context, userID string, limit int) ([]PointsEntryRecord, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	const q = `
+		SELECT id, user_id, event_type, delta, balance_after, source_id, transaction_id, deskripsi, created_at
+		FROM points_ledger
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, q, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []PointsEntryRecord
+	for rows.Next() {
+		var rec PointsEntryRecord
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.EventType, &rec.Delta, &rec.BalanceAfter, &rec.SourceID, &rec.TransactionID, &rec.Deskripsi, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
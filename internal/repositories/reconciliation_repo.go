@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+type CreateReconciliationRunParams struct {
+	TriggeredBy string
+	Apply       bool
+	StartedAt   time.Time
+}
+
+type FinishReconciliationRunParams struct {
+	ID           string
+	UsersChecked int
+	Mismatches   int
+	Adjustments  int
+	Error        *string
+	FinishedAt   time.Time
+}
+
+type CreateReconciliationMismatchParams struct {
+	RunID         string
+	UserID        string
+	Field         string
+	Source        string
+	Expected      money.Amount
+	Actual        money.Amount
+	Adjusted      bool
+	LedgerEntryID *string
+	CreatedAt     time.Time
+}
+
+type ReconciliationRepo interface {
+	CreateRun(ctx context.Context, p CreateReconciliationRunParams) (string, error)
+	FinishRun(ctx context.Context, p FinishReconciliationRunParams) error
+	CreateMismatch(ctx context.Context, tx DBTX, p CreateReconciliationMismatchParams) error
+
+	// ListWalletUserIDs mengembalikan user_id wallet_summary terurut, mulai setelah afterUserID.
+	ListWalletUserIDs(ctx context.Context, afterUserID string, limit int) ([]string, error)
+
+	// SumTransactionsByType menjumlahkan histori transactions user per tipe_transaksi.
+	SumTransactionsByType(ctx context.Context, tx DBTX, userID string) (map[string]money.Amount, error)
+	// SumPostingsByAccount menghitung saldo tiap akun ledger user langsung dari posting.
+	SumPostingsByAccount(ctx context.Context, tx DBTX, userID string) (map[string]money.Amount, error)
+	// SumSettledTopUps menjumlahkan payment_orders SETTLEMENT yang saldonya sudah diterapkan.
+	SumSettledTopUps(ctx context.Context, tx DBTX, userID string) (money.Amount, error)
+}
+
+type reconciliationRepo struct{ db *sql.DB }
+
+func NewReconciliationRepo(db *sql.DB) ReconciliationRepo { return &reconciliationRepo{db: db} }
+
+func (r *reconciliationRepo) CreateRun(ctx context.Context, p CreateReconciliationRunParams) (string, error) {
+	const q = `
+		INSERT INTO reconciliation_runs (triggered_by, apply, started_at)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+	var id string
+	err := r.db.QueryRowContext(ctx, q, p.TriggeredBy, p.Apply, p.StartedAt).Scan(&id)
+	return id, err
+}
+
+func (r *reconciliationRepo) FinishRun(ctx context.Context, p FinishReconciliationRunParams) error {
+	const q = `
+		UPDATE reconciliation_runs
+		SET users_checked = $2, mismatches = $3, adjustments = $4, error = $5, finished_at = $6
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, q, p.ID, p.UsersChecked, p.Mismatches, p.Adjustments, p.Error, p.FinishedAt)
+	return err
+}
+
+func (r *reconciliationRepo) CreateMismatch(ctx context.Context, tx DBTX, p CreateReconciliationMismatchParams) error {
+	const q = `
+		INSERT INTO reconciliation_mismatches (
+			run_id, user_id, field, source, expected, actual, adjusted, ledger_entry_id, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := tx.ExecContext(ctx, q,
+		p.RunID,
+		p.UserID,
+		p.Field,
+		p.Source,
+		p.Expected,
+		p.Actual,
+		p.Adjusted,
+		p.LedgerEntryID,
+		p.CreatedAt,
+	)
+	return err
+}
+
+func (r *reconciliationRepo) ListWalletUserIDs(ctx context.Context, afterUserID string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	const q = `
+		SELECT user_id
+		FROM wallet_summary
+		WHERE ($1::uuid IS NULL OR user_id > $1::uuid)
+		ORDER BY user_id
+		LIMIT $2
+	`
+	var after *string
+	if afterUserID != "" {
+		after = &afterUserID
+	}
+	rows, err := r.db.QueryContext(ctx, q, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+func (r *reconciliationRepo) SumTransactionsByType(ctx context.Context, tx DBTX, userID string) (map[string]money.Amount, error) {
+	const q = `
+		SELECT tipe_transaksi::text, COALESCE(SUM(jumlah), 0)
+		FROM transactions
+		WHERE user_id = $1
+		GROUP BY tipe_transaksi
+	`
+	return sumByKey(ctx, tx, q, userID)
+}
+
+func (r *reconciliationRepo) SumPostingsByAccount(ctx context.Context, tx DBTX, userID string) (map[string]money.Amount, error) {
+	const q = `
+		SELECT a.kind::text,
+		       COALESCE(SUM(CASE WHEN p.side = a.normal_side THEN p.amount ELSE -p.amount END), 0)
+		FROM ledger_accounts a
+		LEFT JOIN ledger_postings p ON p.account_id = a.id
+		WHERE a.user_id = $1
+		GROUP BY a.kind
+	`
+	return sumByKey(ctx, tx, q, userID)
+}
+
+func sumByKey(ctx context.Context, tx DBTX, q string, args ...any) (map[string]money.Amount, error) {
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]money.Amount)
+	for rows.Next() {
+		var (
+			key   string
+			total money.Amount
+		)
+		if err := rows.Scan(&key, &total); err != nil {
+			return nil, err
+		}
+		res[key] = total
+	}
+	return res, rows.Err()
+}
+
+func (r *reconciliationRepo) SumSettledTopUps(ctx context.Context, tx DBTX, userID string) (money.Amount, error) {
+	const q = `
+		SELECT COALESCE(SUM(gross_amount), 0)
+		FROM payment_orders
+		WHERE user_id = $1 AND status = 'SETTLEMENT' AND balance_applied
+	`
+	var total money.Amount
+	err := tx.QueryRowContext(ctx, q, userID).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ReversalRecord struct {
+	ID                    string
+	OriginalTransactionID string
+	ReversalTransactionID string
+	UserID                string
+	Reason                string
+	ReversedBy            sql.NullString
+	CreatedAt             time.Time
+}
+
+type CreateReversalParams struct {
+	OriginalTransactionID string
+	ReversalTransactionID string
+	UserID                string
+	Reason                string
+	ReversedBy            *string
+	CreatedAt             time.Time
+}
+
+type ReversalRepo interface {
+	GetTransaction(ctx context.Context, id string) (TransactionRecord, error)
+	GetTransactionForUpdate(ctx context.Context, tx DBTX, id string) (TransactionRecord, error)
+
+	// FindReversal mencari pembalikan yang menyentuh transaksi id, baik sebagai
+	// transaksi asal maupun sebagai transaksi pembalik.
+	FindReversal(ctx context.Context, tx DBTX, transactionID string) (ReversalRecord, error)
+	CreateReversal(ctx context.Context, tx DBTX, p CreateReversalParams) (ReversalRecord, error)
+}
+
+type reversalRepo struct{ db *sql.DB }
+
+func NewReversalRepo(db *sql.DB) ReversalRepo { return &reversalRepo{db: db} }
+
+func (r *reversalRepo) getTransaction(ctx context.Context, exec DBTX, q, id string) (TransactionRecord, error) {
+	var rec TransactionRecord
+	err := exec.QueryRowContext(ctx, q, id).Scan(
+		&rec.ID, &rec.UserID, &rec.Type, &rec.Amount, &rec.Description, &rec.ReferenceID, &rec.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "transaction not found"}
+	}
+	return rec, err
+}
+
+func (r *reversalRepo) GetTransaction(ctx context.Context, id string) (TransactionRecord, error) {
+	const q = `
+		SELECT id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at
+		FROM transactions
+		WHERE id = $1
+	`
+	return r.getTransaction(ctx, r.db, q, id)
+}
+
+func (r *reversalRepo) GetTransactionForUpdate(ctx context.Context, tx DBTX, id string) (TransactionRecord, error) {
+	const q = `
+		SELECT id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at
+		FROM transactions
+		WHERE id = $1
+		FOR UPDATE
+	`
+	return r.getTransaction(ctx, tx, q, id)
+}
+
+func (r *reversalRepo) FindReversal(ctx context.Context, tx DBTX, transactionID string) (ReversalRecord, error) {
+	const q = `
+		SELECT id, original_transaction_id, reversal_transaction_id, user_id, reason, reversed_by, created_at
+		FROM transaction_reversals
+		WHERE original_transaction_id = $1 OR reversal_transaction_id = $1
+		LIMIT 1
+	`
+	var rec ReversalRecord
+	err := tx.QueryRowContext(ctx, q, transactionID).Scan(
+		&rec.ID,
+		&rec.OriginalTransactionID,
+		&rec.ReversalTransactionID,
+		&rec.UserID,
+		&rec.Reason,
+		&rec.ReversedBy,
+		&rec.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "reversal not found"}
+	}
+	return rec, err
+}
+
+func (r *reversalRepo) CreateReversal(ctx context.Context, tx DBTX, p CreateReversalParams) (ReversalRecord, error) {
+	const q = `
+		INSERT INTO transaction_reversals (original_transaction_id, reversal_transaction_id, user_id, reason, reversed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	rec := ReversalRecord{
+		OriginalTransactionID: p.OriginalTransactionID,
+		ReversalTransactionID: p.ReversalTransactionID,
+		UserID:                p.UserID,
+		Reason:                p.Reason,
+		CreatedAt:             p.CreatedAt,
+	}
+	if p.ReversedBy != nil {
+		rec.ReversedBy = sql.NullString{String: *p.ReversedBy, Valid: true}
+	}
+	err := tx.QueryRowContext(ctx, q,
+		p.OriginalTransactionID, p.ReversalTransactionID, p.UserID, p.Reason, p.ReversedBy, p.CreatedAt,
+	).Scan(&rec.ID)
+	return rec, err
+}
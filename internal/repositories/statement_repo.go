@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// StatementRepo membaca data e-statement. Semua query berjalan di satu transaksi
+// read-only REPEATABLE READ supaya saldo awal, mutasi dan saldo akhir konsisten
+// walaupun ada transaksi baru selama file di-stream.
+type StatementRepo interface {
+	BeginSnapshot(ctx context.Context) (*sql.Tx, error)
+	GetBalances(ctx context.Context, tx DBTX, userID string) (topup, redeem money.Amount, err error)
+	// SumTransactionsByTypeSince menjumlahkan transaksi per tipe dengan created_at >= since.
+	SumTransactionsByTypeSince(ctx context.Context, tx DBTX, userID string, since time.Time) (map[string]money.Amount, error)
+	// ForEachTransaction memanggil fn untuk setiap transaksi dalam [from, to) dari yang terlama.
+	ForEachTransaction(ctx context.Context, tx DBTX, userID string, from, to time.Time, fn func(TransactionRecord) error) error
+}
+
+type statementRepo struct{ db *sql.DB }
+
+func NewStatementRepo(db *sql.DB) StatementRepo { return &statementRepo{db: db} }
+
+func (r *statementRepo) BeginSnapshot(ctx context.Context) (*sql.Tx, error) {
+	return r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+func (r *statementRepo) GetBalances(ctx context.Context, tx DBTX, userID string) (money.Amount, money.Amount, error) {
+	var topup, redeem money.Amount
+	err := tx.QueryRowContext(ctx,
+		`SELECT saldo_topup, saldo_redeem FROM wallet_summary WHERE user_id = $1`,
+		userID,
+	).Scan(&topup, &redeem)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrNotFound{Message: "wallet not found"}
+	}
+	return topup, redeem, err
+}
+
+func (r *statementRepo) SumTransactionsByTypeSince(ctx context.Context, tx DBTX, userID string, since time.Time) (map[string]money.Amount, error) {
+	const q = `
+		SELECT tipe_transaksi::text, COALESCE(SUM(jumlah), 0)
+		FROM transactions
+		WHERE user_id = $1 AND created_at >= $2
+		GROUP BY tipe_transaksi
+	`
+	return sumByKey(ctx, tx, q, userID, since)
+}
+
+func (r *statementRepo) ForEachTransaction(ctx context.Context, tx DBTX, userID string, from, to time.Time, fn func(TransactionRecord) error) error {
+	const q = `
+		SELECT id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at
+		FROM transactions
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := tx.QueryContext(ctx, q, userID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec TransactionRecord
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Type, &rec.Amount, &rec.Description, &rec.ReferenceID, &rec.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -6,6 +6,10 @@ import (
 	"errors"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
 )
 
 // =============== Errors ===============
@@ -24,74 +28,166 @@ type DBTX interface {
 type CreateTransactionParams struct {
 	UserID        string
 	TipeTransaksi string // contoh: "TOP_UP"
-	Jumlah        float64
+	Jumlah        money.Amount
 	Deskripsi     string
 	ReferensiID   *string // boleh nil
 	CreatedAt     time.Time
-}
 
-type AddSaldoParams struct {
-	UserID      string
-	DeltaTotal  float64
-	DeltaTopup  float64
-	DeltaRedeem float64
-	UpdatedAt   time.Time
+	// Efek transaksi ke saldo_topup/saldo_redeem, dipakai untuk balance_after.
+	// CreateTransaction harus dipanggil sebelum saldo diubah oleh ledger.
+	TopupDelta  money.Amount
+	RedeemDelta money.Amount
 }
 
 type TransactionRecord struct {
 	ID          string
 	UserID      string
 	Type        string
-	Amount      float64
+	Amount      money.Amount
 	Description sql.NullString
 	ReferenceID sql.NullString
 	CreatedAt   time.Time
+
+	// nil untuk histori lama yang belum di-backfill
+	BalanceAfterTotal  *money.Amount
+	BalanceAfterTopup  *money.Amount
+	BalanceAfterRedeem *money.Amount
+}
+
+// ListTransactionsParams memfilter histori transaksi. Field nil/kosong berarti tanpa filter.
+// BeforeCreatedAt+BeforeID adalah posisi keyset baris terakhir halaman sebelumnya.
+type ListTransactionsParams struct {
+	UserID          string
+	Types           []string
+	From            *time.Time // inklusif
+	To              *time.Time // eksklusif
+	MinAmount       *money.Amount
+	MaxAmount       *money.Amount
+	BeforeCreatedAt *time.Time
+	BeforeID        *string
+	Limit           int
 }
 
 type PaymentOrderRecord struct {
-	ID               string
-	UserID           string
-	OrderID          string
-	GrossAmount      float64
-	SnapToken        string
-	RedirectURL      string
-	Status           string
-	MidtransTrxID    sql.NullString
-	RawNotification  sql.NullString
-	SettledAt        sql.NullTime
-	BalanceApplied   bool
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	ID              string
+	UserID          string
+	OrderID         string
+	GrossAmount     money.Amount
+	SnapToken       string
+	RedirectURL     string
+	Status          string
+	ProviderTrxID   sql.NullString
+	RawNotification sql.NullString
+	SettledAt       sql.NullTime
+	BalanceApplied  bool
+	ExpiresAt       time.Time
+	CancelledAt     sql.NullTime
+	PaymentChannel  string
+	ChannelData     sql.NullString
+	Provider        string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	RefundStatus        sql.NullString
+	RefundKey           sql.NullString
+	RefundReason        sql.NullString
+	RefundTransactionID sql.NullString
+	RefundRequestedBy   sql.NullString
+	RefundRequestedAt   sql.NullTime
+	RefundedAt          sql.NullTime
+	RefundError         sql.NullString
+
+	TransactionID         sql.NullString // transaksi TOP_UP saat saldo masuk
+	ReversalTransactionID sql.NullString
+	ReversedAt            sql.NullTime
 }
 
 type CreatePaymentOrderParams struct {
 	UserID      string
 	OrderID     string
-	GrossAmount float64
+	GrossAmount money.Amount
+	SnapToken   string
+	RedirectURL string
+	ExpiresAt   time.Time
+	Channel     string
+	ChannelData []byte // instruksi bayar Core API dalam JSON; nil untuk Snap
+	Provider    string
+}
+
+type PaymentOrderCheckoutParams struct {
+	OrderID     string
 	SnapToken   string
 	RedirectURL string
+	ChannelData []byte
 }
 
+// Channel pembayaran top up (kolom payment_orders.payment_channel).
+const (
+	PaymentChannelSnap         = "SNAP"
+	PaymentChannelBankTransfer = "BANK_TRANSFER"
+	PaymentChannelQRIS         = "QRIS"
+	PaymentChannelGoPay        = "GOPAY"
+)
+
 type UpdatePaymentOrderStatusParams struct {
 	OrderID            string
 	Status             string
-	MidtransTrxID      *string
+	ProviderTrxID      *string
 	RawNotification    []byte
 	SettledAt          *time.Time
+	CancelledAt        *time.Time
 	BalanceAlreadyUsed *bool
 }
 
+// Status refund top up (kolom payment_orders.refund_status).
+const (
+	TopUpRefundPending  = "PENDING"
+	TopUpRefundRefunded = "REFUNDED"
+	TopUpRefundFailed   = "FAILED"
+)
+
+// UpdatePaymentOrderRefundParams: field nil tidak mengubah kolomnya, kecuali Error
+// yang selalu ditimpa supaya error lama hilang saat refund berhasil.
+type UpdatePaymentOrderRefundParams struct {
+	OrderID       string
+	PendingKey    string // bila diisi, update hanya berlaku selama refund PENDING dengan key ini
+	Status        string
+	Key           *string
+	Reason        *string
+	TransactionID *string
+	RequestedBy   *string
+	RequestedAt   *time.Time
+	RefundedAt    *time.Time
+	Error         *string
+}
+
 type PayoutRequestRecord struct {
-	ID                 string
-	UserID             string
-	Amount             float64
-	BankCode           string
-	BankName           sql.NullString
-	AccountNumber      string
-	AccountHolderName  string
-	Status             string
-	MidtransPayoutID   sql.NullString
-	RawResponse        sql.NullString
+	ID                string
+	UserID            string
+	Amount            money.Amount
+	BankCode          string
+	BankName          sql.NullString
+	AccountNumber     string
+	AccountHolderName string
+	Status            string
+	Provider          string
+	ProviderReference sql.NullString
+	RawResponse       sql.NullString
+	TransactionID     sql.NullString
+	PartnerTrxID      string
+	BeneficiaryEmail  sql.NullString
+	Notes             sql.NullString
+	Attempts          int
+	NextAttemptAt     sql.NullTime
+	LastError         sql.NullString
+	DispatchInFlight  bool
+	ApprovalStatus    sql.NullString
+	ApprovedBy        sql.NullString
+	ApprovalNote      sql.NullString
+	ApprovalDecidedAt sql.NullTime
+	// ApprovalInFlightAt terisi selama keputusan approval sedang dikirim ke provider.
+	ApprovalInFlightAt sql.NullTime
+	BeneficiaryID      sql.NullString
 	RequestedAt        time.Time
 	CompletedAt        sql.NullTime
 	CreatedAt          time.Time
@@ -100,24 +196,64 @@ type PayoutRequestRecord struct {
 
 type CreatePayoutRequestParams struct {
 	UserID            string
-	Amount            float64
+	Amount            money.Amount
 	BankCode          string
 	BankName          string
 	AccountNumber     string
 	AccountHolderName string
 	Status            string
-	MidtransPayoutID  *string
+	Provider          string
+	ProviderReference *string
 	RawResponse       []byte
+	PartnerTrxID      string
+	BeneficiaryID     *string
+	BeneficiaryEmail  string
+	Notes             string
 	RequestedAt       time.Time
 	CompletedAt       *time.Time
 }
 
 type UpdatePayoutRequestStatusParams struct {
-	ID               string
-	Status           string
-	MidtransPayoutID *string
-	RawResponse      []byte
-	CompletedAt      *time.Time
+	ID                string
+	Status            string
+	ProviderReference *string
+	RawResponse       []byte
+	TransactionID     *string
+	LastError         *string
+	NextAttemptAt     *time.Time
+	DispatchInFlight  *bool
+	ApprovalStatus    *string
+	CompletedAt       *time.Time
+}
+
+// ResolvePayoutRequestParams mencatat hasil rekonsiliasi manual payout UNKNOWN.
+type ResolvePayoutRequestParams struct {
+	ID                string
+	Status            string
+	ProviderReference *string
+	ApprovalStatus    *string
+	NextAttemptAt     *time.Time
+	CompletedAt       *time.Time
+	ResolvedBy        string
+	Note              string
+	ResolvedAt        time.Time
+}
+
+// Status persetujuan payout (kolom payout_requests.approval_status).
+const (
+	PayoutApprovalAuto     = "AUTO"
+	PayoutApprovalPending  = "PENDING"
+	PayoutApprovalApproved = "APPROVED"
+	PayoutApprovalRejected = "REJECTED"
+)
+
+type DecidePayoutApprovalParams struct {
+	ID        string
+	From      string // keputusan hanya berlaku bila approval_status masih From
+	Status    string
+	DecidedBy *string
+	Note      string
+	DecidedAt time.Time
 }
 
 type UserProfile struct {
@@ -127,10 +263,35 @@ type UserProfile struct {
 	Phone string
 }
 
+type WalletStatusRecord struct {
+	UserID    string
+	Status    string
+	Reason    sql.NullString
+	ChangedAt sql.NullTime
+}
+
+type WalletStatusHistoryRecord struct {
+	ID         string
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	ChangedBy  sql.NullString
+	CreatedAt  time.Time
+}
+
+type UpdateWalletStatusParams struct {
+	UserID     string
+	FromStatus string
+	Status     string
+	Reason     string
+	ChangedBy  *string
+	ChangedAt  time.Time
+}
+
 type VoucherRecord struct {
 	ID           string
 	Code         string
-	Amount       float64
+	Amount       money.Amount
 	Description  sql.NullString
 	ExpiresAt    sql.NullTime
 	Active       bool
@@ -144,31 +305,90 @@ type WalletRepo interface {
 	BeginTx(ctx context.Context) (*sql.Tx, error)
 
 	// Read saldo
-	GetSaldo(ctx context.Context, userID string) (total, topup, redeem float64, evPoin int, err error)
-	GetSaldoForUpdate(ctx context.Context, tx DBTX, userID string) (total, topup, redeem float64, evPoin int, err error)
-	ListTransactions(ctx context.Context, userID string, limit int) ([]TransactionRecord, error)
+	GetSaldo(ctx context.Context, userID string) (total, topup, redeem money.Amount, evPoin int, err error)
+	GetSaldoForUpdate(ctx context.Context, tx DBTX, userID string) (total, topup, redeem money.Amount, evPoin int, err error)
+	// GetHeld mengembalikan saldo yang sedang ditahan (hold) per sub-saldo.
+	GetHeld(ctx context.Context, userID string) (topup, redeem money.Amount, err error)
+	GetHeldTx(ctx context.Context, tx DBTX, userID string) (topup, redeem money.Amount, err error)
+	// ListTransactions mengembalikan transaksi terbaru lebih dulu, diurutkan (created_at, id).
+	ListTransactions(ctx context.Context, p ListTransactionsParams) ([]TransactionRecord, error)
 	ListAvailableVouchers(ctx context.Context, userID string, limit int) ([]VoucherRecord, error)
 	GetUserProfile(ctx context.Context, userID string) (*UserProfile, error)
+	FindUserByContact(ctx context.Context, emailOrPhone string) (*UserProfile, error)
+	SumTransactionsSince(ctx context.Context, tx DBTX, userID string, types []string, since time.Time) (money.Amount, error)
+
+	// Status wallet
+	GetWalletStatus(ctx context.Context, userID string) (WalletStatusRecord, error)
+	// GetWalletStatusTx membaca status sambil mengunci baris wallet_summary (FOR UPDATE).
+	GetWalletStatusTx(ctx context.Context, tx DBTX, userID string) (WalletStatusRecord, error)
+	// UpdateWalletStatus mengubah status dan mencatat riwayatnya.
+	UpdateWalletStatus(ctx context.Context, tx DBTX, p UpdateWalletStatusParams) error
+	ListWalletStatusHistory(ctx context.Context, userID string, limit int) ([]WalletStatusHistoryRecord, error)
 
 	// Voucher
-	GetVoucherByCode(ctx context.Context, kode string) (id string, nilai float64, aktif bool, exp sql.NullTime, err error)
+	GetVoucherByCode(ctx context.Context, kode string) (id string, nilai money.Amount, aktif bool, exp sql.NullTime, err error)
 	CreateVoucherClaim(ctx context.Context, tx DBTX, userID, voucherID string, now time.Time) (bool, error)
 
 	// Transaksi & saldo
-	CreateTransaction(ctx context.Context, tx DBTX, p CreateTransactionParams) error
-	AddSaldo(ctx context.Context, tx DBTX, p AddSaldoParams) error
+	CreateTransaction(ctx context.Context, tx DBTX, p CreateTransactionParams) (string, error)
 
 	// Payment orders
-	CreatePaymentOrder(ctx context.Context, p CreatePaymentOrderParams) error
+	CreatePaymentOrder(ctx context.Context, tx DBTX, p CreatePaymentOrderParams) error
+	// SetPaymentOrderCheckout menyimpan hasil checkout/charge gateway ke order yang
+	// sudah dibuat.
+	SetPaymentOrderCheckout(ctx context.Context, p PaymentOrderCheckoutParams) error
 	GetPaymentOrder(ctx context.Context, orderID string) (PaymentOrderRecord, error)
+	GetPaymentOrderForUpdate(ctx context.Context, tx DBTX, orderID string) (PaymentOrderRecord, error)
+	GetPaymentOrderByTransactionForUpdate(ctx context.Context, tx DBTX, transactionID string) (PaymentOrderRecord, error)
+	// SetPaymentOrderTransaction menghubungkan order dengan transaksi TOP_UP-nya.
+	SetPaymentOrderTransaction(ctx context.Context, tx DBTX, orderID, transactionID string) error
+	// MarkPaymentOrderReversed mencatat bahwa transaksi TOP_UP order sudah dibalik.
+	MarkPaymentOrderReversed(ctx context.Context, tx DBTX, orderID, reversalTransactionID string, at time.Time) error
+	// ListStalePendingOrders mengembalikan order PENDING yang dibuat di antara createdAfter
+	// dan createdBefore; yang belum pernah atau paling lama tidak dipolling dulu.
+	ListStalePendingOrders(ctx context.Context, createdAfter, createdBefore time.Time, limit int) ([]PaymentOrderRecord, error)
 	UpdatePaymentOrderStatus(ctx context.Context, p UpdatePaymentOrderStatusParams) error
+	// ListExpiredPendingOrders mengembalikan order PENDING yang expires_at-nya sudah
+	// lewat sebelum before dan saldonya belum masuk; yang belum pernah atau paling
+	// lama tidak dipolling dulu.
+	ListExpiredPendingOrders(ctx context.Context, before time.Time, limit int) ([]PaymentOrderRecord, error)
+	// ExpirePaymentOrder menandai EXPIRED satu order yang masih PENDING dan saldonya
+	// belum masuk; false bila order sudah berubah lebih dulu.
+	ExpirePaymentOrder(ctx context.Context, orderID string) (bool, error)
+	// MarkPaymentOrderPolled mencatat waktu status order terakhir ditanyakan ke gateway.
+	MarkPaymentOrderPolled(ctx context.Context, orderID string, at time.Time) error
+	UpdatePaymentOrderRefund(ctx context.Context, p UpdatePaymentOrderRefundParams) (ok bool, err error)
+	UpdatePaymentOrderRefundTx(ctx context.Context, tx DBTX, p UpdatePaymentOrderRefundParams) (ok bool, err error)
 
 	// Payout requests
-	CreatePayoutRequest(ctx context.Context, p CreatePayoutRequestParams) (PayoutRequestRecord, error)
+	CreatePayoutRequest(ctx context.Context, tx DBTX, p CreatePayoutRequestParams) (PayoutRequestRecord, error)
 	UpdatePayoutRequestStatus(ctx context.Context, p UpdatePayoutRequestStatusParams) error
 	GetPayoutRequestByID(ctx context.Context, id string) (PayoutRequestRecord, error)
+	GetPayoutRequestByProviderReference(ctx context.Context, provider, reference string) (PayoutRequestRecord, error)
+	GetPayoutRequestForUpdate(ctx context.Context, tx DBTX, id string) (PayoutRequestRecord, error)
 	UpdatePaymentOrderStatusTx(ctx context.Context, tx DBTX, p UpdatePaymentOrderStatusParams) error
 	UpdatePayoutRequestStatusTx(ctx context.Context, tx DBTX, p UpdatePayoutRequestStatusParams) error
+	// ListDispatchablePayoutIDs mengembalikan payout PENDING yang sudah waktunya dikirim ke Iris.
+	ListDispatchablePayoutIDs(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// ClaimPayoutDispatch menaikkan attempts dan menunda next_attempt_at sampai leaseUntil,
+	// sehingga payout yang sama tidak dikirim dua worker sekaligus. ok=false bila payout
+	// sudah tidak PENDING atau sedang diklaim worker lain.
+	ClaimPayoutDispatch(ctx context.Context, id string, now, leaseUntil time.Time) (rec PayoutRequestRecord, ok bool, err error)
+	// ListPayoutApprovals mengembalikan payout REQUESTED dengan approval_status tertentu, terlama dulu.
+	ListPayoutApprovals(ctx context.Context, approvalStatus string, limit int) ([]PayoutRequestRecord, error)
+	// DecidePayoutApproval mencatat keputusan approver. ok=false bila sudah diputuskan lebih dulu.
+	DecidePayoutApproval(ctx context.Context, tx DBTX, p DecidePayoutApprovalParams) (ok bool, err error)
+	// SetPayoutApprovalInFlight menandai (at != nil) atau menghapus tanda bahwa keputusan
+	// approval sedang dikirim ke provider.
+	SetPayoutApprovalInFlight(ctx context.Context, id string, at *time.Time) error
+	SetPayoutApprovalInFlightTx(ctx context.Context, tx DBTX, id string, at *time.Time) error
+	// SetPayoutLastError hanya memperbarui last_error payout yang belum final.
+	SetPayoutLastError(ctx context.Context, id, msg string) error
+	// ListPayoutRequestsByStatus mengembalikan payout berstatus status, terlama dulu.
+	ListPayoutRequestsByStatus(ctx context.Context, status string, limit int) ([]PayoutRequestRecord, error)
+	// ResolvePayoutRequest menerapkan hasil rekonsiliasi payout UNKNOWN. ok=false bila
+	// payout sudah tidak UNKNOWN.
+	ResolvePayoutRequest(ctx context.Context, tx DBTX, p ResolvePayoutRequestParams) (ok bool, err error)
 }
 
 // =============== Implementasi ===============
@@ -181,13 +401,13 @@ func (r *walletRepo) BeginTx(ctx context.Context) (*sql.Tx, error) {
 }
 
 // --- Saldo ---
-func (r *walletRepo) GetSaldo(ctx context.Context, userID string) (float64, float64, float64, int, error) {
+func (r *walletRepo) GetSaldo(ctx context.Context, userID string) (money.Amount, money.Amount, money.Amount, int, error) {
 	const q = `
   SELECT total_saldo, saldo_topup, saldo_redeem, ev_poin
   FROM wallet_summary
   WHERE user_id = $1
 `
-	var tot, topup, redeem float64
+	var tot, topup, redeem money.Amount
 	var poin int
 	err := r.db.QueryRowContext(ctx, q, userID).Scan(&tot, &topup, &redeem, &poin)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -196,14 +416,14 @@ func (r *walletRepo) GetSaldo(ctx context.Context, userID string) (float64, floa
 	return tot, topup, redeem, poin, err
 }
 
-func (r *walletRepo) GetSaldoForUpdate(ctx context.Context, tx DBTX, userID string) (float64, float64, float64, int, error) {
+func (r *walletRepo) GetSaldoForUpdate(ctx context.Context, tx DBTX, userID string) (money.Amount, money.Amount, money.Amount, int, error) {
 	const q = `
   SELECT total_saldo, saldo_topup, saldo_redeem, ev_poin
   FROM wallet_summary
   WHERE user_id = $1
   FOR UPDATE
 `
-	var tot, topup, redeem float64
+	var tot, topup, redeem money.Amount
 	var poin int
 	err := tx.QueryRowContext(ctx, q, userID).Scan(&tot, &topup, &redeem, &poin)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -212,6 +432,28 @@ func (r *walletRepo) GetSaldoForUpdate(ctx context.Context, tx DBTX, userID stri
 	return tot, topup, redeem, poin, err
 }
 
+func (r *walletRepo) getHeld(ctx context.Context, exec DBTX, userID string) (money.Amount, money.Amount, error) {
+	const q = `
+  SELECT held_topup, held_redeem
+  FROM wallet_summary
+  WHERE user_id = $1
+`
+	var topup, redeem money.Amount
+	err := exec.QueryRowContext(ctx, q, userID).Scan(&topup, &redeem)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrNotFound{"wallet not found"}
+	}
+	return topup, redeem, err
+}
+
+func (r *walletRepo) GetHeld(ctx context.Context, userID string) (money.Amount, money.Amount, error) {
+	return r.getHeld(ctx, r.db, userID)
+}
+
+func (r *walletRepo) GetHeldTx(ctx context.Context, tx DBTX, userID string) (money.Amount, money.Amount, error) {
+	return r.getHeld(ctx, tx, userID)
+}
+
 func (r *walletRepo) GetUserProfile(ctx context.Context, userID string) (*UserProfile, error) {
 	const q = `
 		SELECT id, email, COALESCE(phone, '')
@@ -219,7 +461,7 @@ func (r *walletRepo) GetUserProfile(ctx context.Context, userID string) (*UserPr
 		WHERE id = $1
 	`
 	var (
-		prof UserProfile
+		prof  UserProfile
 		phone string
 	)
 	err := r.db.QueryRowContext(ctx, q, userID).Scan(&prof.ID, &prof.Email, &phone)
@@ -236,6 +478,114 @@ func (r *walletRepo) GetUserProfile(ctx context.Context, userID string) (*UserPr
 	return &prof, nil
 }
 
+// FindUserByContact mencari user berdasarkan email (case-insensitive) atau nomor telepon.
+func (r *walletRepo) FindUserByContact(ctx context.Context, emailOrPhone string) (*UserProfile, error) {
+	const q = `
+		SELECT id
+		FROM users
+		WHERE lower(email) = lower($1) OR phone = $1
+		ORDER BY created_at
+		LIMIT 1
+	`
+	var id string
+	err := r.db.QueryRowContext(ctx, q, strings.TrimSpace(emailOrPhone)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound{Message: "user not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetUserProfile(ctx, id)
+}
+
+// SumTransactionsSince menjumlahkan transaksi user dengan tipe tertentu sejak waktu since.
+func (r *walletRepo) SumTransactionsSince(ctx context.Context, tx DBTX, userID string, types []string, since time.Time) (money.Amount, error) {
+	const q = `
+		SELECT COALESCE(SUM(jumlah), 0)
+		FROM transactions
+		WHERE user_id = $1
+		  AND tipe_transaksi::text = ANY($2)
+		  AND created_at >= $3
+	`
+	var total money.Amount
+	err := tx.QueryRowContext(ctx, q, userID, pq.Array(types), since).Scan(&total)
+	return total, err
+}
+
+// --- Status wallet ---
+func (r *walletRepo) getWalletStatus(ctx context.Context, exec DBTX, q, userID string) (WalletStatusRecord, error) {
+	var rec WalletStatusRecord
+	err := exec.QueryRowContext(ctx, q, userID).Scan(&rec.UserID, &rec.Status, &rec.Reason, &rec.ChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "wallet not found"}
+	}
+	return rec, err
+}
+
+func (r *walletRepo) GetWalletStatus(ctx context.Context, userID string) (WalletStatusRecord, error) {
+	const q = `
+		SELECT user_id, status, status_reason, status_changed_at
+		FROM wallet_summary
+		WHERE user_id = $1
+	`
+	return r.getWalletStatus(ctx, r.db, q, userID)
+}
+
+func (r *walletRepo) GetWalletStatusTx(ctx context.Context, tx DBTX, userID string) (WalletStatusRecord, error) {
+	const q = `
+		SELECT user_id, status, status_reason, status_changed_at
+		FROM wallet_summary
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+	return r.getWalletStatus(ctx, tx, q, userID)
+}
+
+func (r *walletRepo) UpdateWalletStatus(ctx context.Context, tx DBTX, p UpdateWalletStatusParams) error {
+	const update = `
+		UPDATE wallet_summary
+		SET status = $2::wallet_status, status_reason = $3, status_changed_at = $4
+		WHERE user_id = $1
+	`
+	if _, err := tx.ExecContext(ctx, update, p.UserID, p.Status, p.Reason, p.ChangedAt); err != nil {
+		return err
+	}
+	const history = `
+		INSERT INTO wallet_status_history (user_id, from_status, to_status, reason, changed_by, created_at)
+		VALUES ($1, $2::wallet_status, $3::wallet_status, $4, $5, $6)
+	`
+	_, err := tx.ExecContext(ctx, history, p.UserID, p.FromStatus, p.Status, p.Reason, p.ChangedBy, p.ChangedAt)
+	return err
+}
+
+func (r *walletRepo) ListWalletStatusHistory(ctx context.Context, userID string, limit int) ([]WalletStatusHistoryRecord, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	const q = `
+		SELECT id, from_status, to_status, reason, changed_by, created_at
+		FROM wallet_status_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, q, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []WalletStatusHistoryRecord
+	for rows.Next() {
+		var rec WalletStatusHistoryRecord
+		if err := rows.Scan(&rec.ID, &rec.FromStatus, &rec.ToStatus, &rec.Reason, &rec.ChangedBy, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
+
 // --- Klaim voucher ---
 func (r *walletRepo) WasVoucherClaimed(ctx context.Context, userID, kode string) (bool, error) {
 	// sesuai skema kamu: user_voucher_claims(voucher_id) + vouchers(kode_voucher)
@@ -267,24 +617,39 @@ func (r *walletRepo) CreateVoucherClaim(ctx context.Context, tx DBTX, userID, vo
 	return n == 1, nil
 }
 
-func (r *walletRepo) GetVoucherByCode(ctx context.Context, kode string) (id string, nilai float64, aktif bool, exp sql.NullTime, err error) {
+func (r *walletRepo) GetVoucherByCode(ctx context.Context, kode string) (id string, nilai money.Amount, aktif bool, exp sql.NullTime, err error) {
 	const q = `SELECT id, nilai, aktif, tanggal_kadaluarsa FROM vouchers WHERE kode_voucher=$1`
 	err = r.db.QueryRowContext(ctx, q, kode).Scan(&id, &nilai, &aktif, &exp)
 	return
 }
 
-func (r *walletRepo) ListTransactions(ctx context.Context, userID string, limit int) ([]TransactionRecord, error) {
-	if limit <= 0 {
-		limit = 50
+func (r *walletRepo) ListTransactions(ctx context.Context, p ListTransactionsParams) ([]TransactionRecord, error) {
+	if p.Limit <= 0 {
+		p.Limit = 50
 	}
+	// filter yang tidak dipakai dikirim NULL supaya query tetap satu statement
+	// dan bisa memakai idx_transactions_user_created_at
 	const q = `
-		SELECT id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at
+		SELECT id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at,
+		       balance_after_total, balance_after_topup, balance_after_redeem
 		FROM transactions
 		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
+		  AND (cardinality($2::text[]) = 0 OR tipe_transaksi::text = ANY($2))
+		  AND ($3::timestamptz IS NULL OR created_at >= $3)
+		  AND ($4::timestamptz IS NULL OR created_at < $4)
+		  AND ($5::numeric IS NULL OR jumlah >= $5)
+		  AND ($6::numeric IS NULL OR jumlah <= $6)
+		  AND ($7::timestamptz IS NULL OR (created_at, id) < ($7, $8::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $9
 	`
-	rows, err := r.db.QueryContext(ctx, q, userID, limit)
+	types := p.Types
+	if types == nil {
+		types = []string{}
+	}
+	rows, err := r.db.QueryContext(ctx, q,
+		p.UserID, pq.Array(types), p.From, p.To, p.MinAmount, p.MaxAmount, p.BeforeCreatedAt, p.BeforeID, p.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +658,18 @@ func (r *walletRepo) ListTransactions(ctx context.Context, userID string, limit
 	var res []TransactionRecord
 	for rows.Next() {
 		var rec TransactionRecord
-		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.Type, &rec.Amount, &rec.Description, &rec.ReferenceID, &rec.CreatedAt); err != nil {
+		if err := rows.Scan(
+			&rec.ID,
+			&rec.UserID,
+			&rec.Type,
+			&rec.Amount,
+			&rec.Description,
+			&rec.ReferenceID,
+			&rec.CreatedAt,
+			&rec.BalanceAfterTotal,
+			&rec.BalanceAfterTopup,
+			&rec.BalanceAfterRedeem,
+		); err != nil {
 			return nil, err
 		}
 		res = append(res, rec)
@@ -347,51 +723,71 @@ func (r *walletRepo) ListAvailableVouchers(ctx context.Context, userID string, l
 }
 
 // --- Top up ---
-func (r *walletRepo) CreateTransaction(ctx context.Context, tx DBTX, p CreateTransactionParams) error {
+func (r *walletRepo) CreateTransaction(ctx context.Context, tx DBTX, p CreateTransactionParams) (string, error) {
+	// saldo dibaca sambil mengunci wallet_summary supaya balance_after urut dengan
+	// transaksi lain milik user yang sama; wallet yang belum ada dianggap nol
 	const q = `
-		INSERT INTO transactions (id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at)
-		VALUES (gen_random_uuid(), $1, $2::transaction_type, $3, $4, $5, $6)
+		WITH w AS (
+			SELECT saldo_topup, saldo_redeem FROM wallet_summary WHERE user_id = $1 FOR UPDATE
+		), b AS (
+			SELECT COALESCE((SELECT saldo_topup FROM w), 0) + $7::numeric AS topup,
+			       COALESCE((SELECT saldo_redeem FROM w), 0) + $8::numeric AS redeem
+		)
+		INSERT INTO transactions (id, user_id, tipe_transaksi, jumlah, deskripsi, referensi_id, created_at,
+		                          balance_after_total, balance_after_topup, balance_after_redeem)
+		SELECT gen_random_uuid(), $1, $2::transaction_type, $3, $4, $5, $6, b.topup + b.redeem, b.topup, b.redeem
+		FROM b
+		RETURNING id
 	`
-	_, err := tx.ExecContext(ctx, q,
-		p.UserID, p.TipeTransaksi, p.Jumlah, p.Deskripsi, p.ReferensiID, p.CreatedAt,
-	)
-	return err
+	var id string
+	err := tx.QueryRowContext(ctx, q,
+		p.UserID, p.TipeTransaksi, p.Jumlah, p.Deskripsi, p.ReferensiID, p.CreatedAt, p.TopupDelta, p.RedeemDelta,
+	).Scan(&id)
+	return id, err
 }
 
-func (r *walletRepo) AddSaldo(ctx context.Context, tx DBTX, p AddSaldoParams) error {
-	// UPSERT: kalau baris belum ada, insert; kalau ada, tambah delta
+func (r *walletRepo) CreatePaymentOrder(ctx context.Context, tx DBTX, p CreatePaymentOrderParams) error {
 	const q = `
-		INSERT INTO wallet_summary (user_id, total_saldo, saldo_topup, saldo_redeem, ev_poin, updated_at)
-  VALUES ($1, $2, $3, $4, 0, $5)
-  ON CONFLICT (user_id) DO UPDATE
-  SET total_saldo   = wallet_summary.total_saldo   + EXCLUDED.total_saldo,
-      saldo_topup   = wallet_summary.saldo_topup   + EXCLUDED.saldo_topup,
-      saldo_redeem  = wallet_summary.saldo_redeem  + EXCLUDED.saldo_redeem,
-      updated_at    = EXCLUDED.updated_at
+		INSERT INTO payment_orders (user_id, order_id, gross_amount, snap_token, redirect_url, expires_at, payment_channel, channel_data, provider)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err := tx.ExecContext(ctx, q, p.UserID, p.DeltaTotal, p.DeltaTopup, p.DeltaRedeem, p.UpdatedAt)
+	// slice nil dikirim sebagai NULL, bukan string kosong yang bukan JSON valid
+	var channelData any
+	if p.ChannelData != nil {
+		channelData = p.ChannelData
+	}
+	_, err := tx.ExecContext(ctx, q,
+		p.UserID, p.OrderID, p.GrossAmount, p.SnapToken, p.RedirectURL, p.ExpiresAt, p.Channel, channelData, p.Provider,
+	)
 	return err
 }
 
-func (r *walletRepo) CreatePaymentOrder(ctx context.Context, p CreatePaymentOrderParams) error {
+func (r *walletRepo) SetPaymentOrderCheckout(ctx context.Context, p PaymentOrderCheckoutParams) error {
 	const q = `
-		INSERT INTO payment_orders (user_id, order_id, gross_amount, snap_token, redirect_url)
-		VALUES ($1, $2, $3, $4, $5)
+		UPDATE payment_orders
+		SET snap_token = $2, redirect_url = $3, channel_data = COALESCE($4, channel_data), updated_at = now()
+		WHERE order_id = $1
 	`
-	_, err := r.db.ExecContext(ctx, q, p.UserID, p.OrderID, p.GrossAmount, p.SnapToken, p.RedirectURL)
+	var channelData any
+	if p.ChannelData != nil {
+		channelData = p.ChannelData
+	}
+	_, err := r.db.ExecContext(ctx, q, p.OrderID, p.SnapToken, p.RedirectURL, channelData)
 	return err
 }
 
-func (r *walletRepo) GetPaymentOrder(ctx context.Context, orderID string) (PaymentOrderRecord, error) {
-	const q = `
-		SELECT id, user_id, order_id, gross_amount, snap_token, redirect_url, status,
-		       midtrans_transaction_id, raw_notification, settled_at, balance_applied,
-		       created_at, updated_at
-		FROM payment_orders
-		WHERE order_id = $1
-	`
+const paymentOrderColumns = `
+	id, user_id, order_id, gross_amount, snap_token, redirect_url, status,
+	provider_transaction_id, raw_notification, settled_at, balance_applied,
+	expires_at, cancelled_at, payment_channel, channel_data, provider, created_at, updated_at,
+	refund_status, refund_key, refund_reason, refund_transaction_id,
+	refund_requested_by, refund_requested_at, refunded_at, refund_error,
+	transaction_id, reversal_transaction_id, reversed_at
+`
+
+func scanPaymentOrder(row rowScanner) (PaymentOrderRecord, error) {
 	var rec PaymentOrderRecord
-	err := r.db.QueryRowContext(ctx, q, orderID).Scan(
+	err := row.Scan(
 		&rec.ID,
 		&rec.UserID,
 		&rec.OrderID,
@@ -399,34 +795,179 @@ func (r *walletRepo) GetPaymentOrder(ctx context.Context, orderID string) (Payme
 		&rec.SnapToken,
 		&rec.RedirectURL,
 		&rec.Status,
-		&rec.MidtransTrxID,
+		&rec.ProviderTrxID,
 		&rec.RawNotification,
 		&rec.SettledAt,
 		&rec.BalanceApplied,
+		&rec.ExpiresAt,
+		&rec.CancelledAt,
+		&rec.PaymentChannel,
+		&rec.ChannelData,
+		&rec.Provider,
 		&rec.CreatedAt,
 		&rec.UpdatedAt,
+		&rec.RefundStatus,
+		&rec.RefundKey,
+		&rec.RefundReason,
+		&rec.RefundTransactionID,
+		&rec.RefundRequestedBy,
+		&rec.RefundRequestedAt,
+		&rec.RefundedAt,
+		&rec.RefundError,
+		&rec.TransactionID,
+		&rec.ReversalTransactionID,
+		&rec.ReversedAt,
 	)
+	return rec, err
+}
+
+func (r *walletRepo) getPaymentOrder(ctx context.Context, exec DBTX, q, orderID string) (PaymentOrderRecord, error) {
+	rec, err := scanPaymentOrder(exec.QueryRowContext(ctx, q, orderID))
 	if errors.Is(err, sql.ErrNoRows) {
 		return rec, ErrNotFound{Message: "payment order not found"}
 	}
 	return rec, err
 }
 
+func (r *walletRepo) GetPaymentOrder(ctx context.Context, orderID string) (PaymentOrderRecord, error) {
+	const q = `SELECT ` + paymentOrderColumns + ` FROM payment_orders WHERE order_id = $1`
+	return r.getPaymentOrder(ctx, r.db, q, orderID)
+}
+
+func (r *walletRepo) GetPaymentOrderForUpdate(ctx context.Context, tx DBTX, orderID string) (PaymentOrderRecord, error) {
+	const q = `SELECT ` + paymentOrderColumns + ` FROM payment_orders WHERE order_id = $1 FOR UPDATE`
+	return r.getPaymentOrder(ctx, tx, q, orderID)
+}
+
+func (r *walletRepo) GetPaymentOrderByTransactionForUpdate(ctx context.Context, tx DBTX, transactionID string) (PaymentOrderRecord, error) {
+	const q = `SELECT ` + paymentOrderColumns + ` FROM payment_orders WHERE transaction_id = $1 FOR UPDATE`
+	return r.getPaymentOrder(ctx, tx, q, transactionID)
+}
+
+func (r *walletRepo) SetPaymentOrderTransaction(ctx context.Context, tx DBTX, orderID, transactionID string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE payment_orders SET transaction_id = $2, updated_at = now() WHERE order_id = $1`, orderID, transactionID)
+	return err
+}
+
+func (r *walletRepo) MarkPaymentOrderReversed(ctx context.Context, tx DBTX, orderID, reversalTransactionID string, at time.Time) error {
+	const q = `
+		UPDATE payment_orders
+		SET reversal_transaction_id = $2, reversed_at = $3, updated_at = now()
+		WHERE order_id = $1
+	`
+	_, err := tx.ExecContext(ctx, q, orderID, reversalTransactionID, at)
+	return err
+}
+
+func (r *walletRepo) ListStalePendingOrders(ctx context.Context, createdAfter, createdBefore time.Time, limit int) ([]PaymentOrderRecord, error) {
+	const q = `
+		SELECT ` + paymentOrderColumns + `
+		FROM payment_orders
+		WHERE status = 'PENDING' AND NOT balance_applied
+		  AND created_at > $1 AND created_at <= $2
+		ORDER BY last_polled_at NULLS FIRST, created_at
+		LIMIT $3
+	`
+	return r.listPaymentOrders(ctx, q, createdAfter, createdBefore, limit)
+}
+
+func (r *walletRepo) MarkPaymentOrderPolled(ctx context.Context, orderID string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE payment_orders SET last_polled_at = $2 WHERE order_id = $1`, orderID, at)
+	return err
+}
+
+func (r *walletRepo) listPaymentOrders(ctx context.Context, q string, args ...any) ([]PaymentOrderRecord, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PaymentOrderRecord
+	for rows.Next() {
+		rec, err := scanPaymentOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
 func (r *walletRepo) updatePaymentOrderStatus(ctx context.Context, exec DBTX, p UpdatePaymentOrderStatusParams) error {
-const q = `
+	const q = `
 	UPDATE payment_orders
 	SET status = $2::payment_order_status,
-	    midtrans_transaction_id = COALESCE($3, midtrans_transaction_id),
+	    provider_transaction_id = COALESCE($3, provider_transaction_id),
 	    raw_notification = COALESCE($4, raw_notification),
 	    settled_at = COALESCE($5, settled_at),
 	    balance_applied = COALESCE($6, balance_applied),
+	    cancelled_at = COALESCE($7, cancelled_at),
 	    updated_at = now()
 	WHERE order_id = $1
 `
-	_, err := exec.ExecContext(ctx, q, p.OrderID, p.Status, p.MidtransTrxID, p.RawNotification, p.SettledAt, p.BalanceAlreadyUsed)
+	_, err := exec.ExecContext(ctx, q, p.OrderID, p.Status, p.ProviderTrxID, p.RawNotification, p.SettledAt, p.BalanceAlreadyUsed, p.CancelledAt)
 	return err
 }
 
+func (r *walletRepo) updatePaymentOrderRefund(ctx context.Context, exec DBTX, p UpdatePaymentOrderRefundParams) (bool, error) {
+	const q = `
+		UPDATE payment_orders
+		SET refund_status = $2,
+		    refund_key = COALESCE($3, refund_key),
+		    refund_reason = COALESCE($4, refund_reason),
+		    refund_transaction_id = COALESCE($5, refund_transaction_id),
+		    refund_requested_by = COALESCE($6, refund_requested_by),
+		    refund_requested_at = COALESCE($7, refund_requested_at),
+		    refunded_at = COALESCE($8, refunded_at),
+		    refund_error = $9,
+		    updated_at = now()
+		WHERE order_id = $1
+		  AND ($10::text = '' OR (refund_status = 'PENDING' AND refund_key = $10))
+	`
+	res, err := exec.ExecContext(ctx, q,
+		p.OrderID, p.Status, p.Key, p.Reason, p.TransactionID, p.RequestedBy, p.RequestedAt, p.RefundedAt, p.Error, p.PendingKey,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *walletRepo) UpdatePaymentOrderRefund(ctx context.Context, p UpdatePaymentOrderRefundParams) (bool, error) {
+	return r.updatePaymentOrderRefund(ctx, r.db, p)
+}
+
+func (r *walletRepo) UpdatePaymentOrderRefundTx(ctx context.Context, tx DBTX, p UpdatePaymentOrderRefundParams) (bool, error) {
+	return r.updatePaymentOrderRefund(ctx, tx, p)
+}
+
+func (r *walletRepo) ListExpiredPendingOrders(ctx context.Context, before time.Time, limit int) ([]PaymentOrderRecord, error) {
+	const q = `
+		SELECT ` + paymentOrderColumns + `
+		FROM payment_orders
+		WHERE status = 'PENDING' AND NOT balance_applied AND expires_at <= $1
+		ORDER BY last_polled_at NULLS FIRST, expires_at
+		LIMIT $2
+	`
+	return r.listPaymentOrders(ctx, q, before, limit)
+}
+
+func (r *walletRepo) ExpirePaymentOrder(ctx context.Context, orderID string) (bool, error) {
+	const q = `
+		UPDATE payment_orders
+		SET status = 'EXPIRED', updated_at = now()
+		WHERE order_id = $1 AND status = 'PENDING' AND NOT balance_applied
+	`
+	res, err := r.db.ExecContext(ctx, q, orderID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 func (r *walletRepo) UpdatePaymentOrderStatus(ctx context.Context, p UpdatePaymentOrderStatusParams) error {
 	return r.updatePaymentOrderStatus(ctx, r.db, p)
 }
@@ -435,30 +976,21 @@ func (r *walletRepo) UpdatePaymentOrderStatusTx(ctx context.Context, tx DBTX, p
 	return r.updatePaymentOrderStatus(ctx, tx, p)
 }
 
-func (r *walletRepo) CreatePayoutRequest(ctx context.Context, p CreatePayoutRequestParams) (PayoutRequestRecord, error) {
-	const q = `
-		INSERT INTO payout_requests (
-			user_id, amount, bank_code, bank_name, account_number, account_holder_name,
-			status, midtrans_payout_id, raw_response, requested_at, completed_at
-		)
-	VALUES ($1, $2, $3, $4, $5, $6, $7::payout_request_status, $8, $9, $10, $11)
-		RETURNING id, user_id, amount, bank_code, bank_name, account_number, account_holder_name,
-		          status, midtrans_payout_id, raw_response, requested_at, completed_at, created_at, updated_at
-	`
+const payoutRequestColumns = `
+	id, user_id, amount, bank_code, bank_name, account_number, account_holder_name,
+	status, provider, provider_reference, raw_response, transaction_id, partner_trx_id,
+	beneficiary_email, notes, attempts, next_attempt_at, last_error, dispatch_in_flight,
+	approval_status, approved_by, approval_note, approval_decided_at, approval_in_flight_at,
+	beneficiary_id, requested_at, completed_at, created_at, updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPayoutRequest(row rowScanner) (PayoutRequestRecord, error) {
 	var rec PayoutRequestRecord
-	err := r.db.QueryRowContext(ctx, q,
-		p.UserID,
-		p.Amount,
-		p.BankCode,
-		p.BankName,
-		p.AccountNumber,
-		p.AccountHolderName,
-		p.Status,
-		p.MidtransPayoutID,
-		p.RawResponse,
-		p.RequestedAt,
-		p.CompletedAt,
-	).Scan(
+	err := row.Scan(
 		&rec.ID,
 		&rec.UserID,
 		&rec.Amount,
@@ -467,8 +999,23 @@ func (r *walletRepo) CreatePayoutRequest(ctx context.Context, p CreatePayoutRequ
 		&rec.AccountNumber,
 		&rec.AccountHolderName,
 		&rec.Status,
-		&rec.MidtransPayoutID,
+		&rec.Provider,
+		&rec.ProviderReference,
 		&rec.RawResponse,
+		&rec.TransactionID,
+		&rec.PartnerTrxID,
+		&rec.BeneficiaryEmail,
+		&rec.Notes,
+		&rec.Attempts,
+		&rec.NextAttemptAt,
+		&rec.LastError,
+		&rec.DispatchInFlight,
+		&rec.ApprovalStatus,
+		&rec.ApprovedBy,
+		&rec.ApprovalNote,
+		&rec.ApprovalDecidedAt,
+		&rec.ApprovalInFlightAt,
+		&rec.BeneficiaryID,
 		&rec.RequestedAt,
 		&rec.CompletedAt,
 		&rec.CreatedAt,
@@ -477,17 +1024,52 @@ func (r *walletRepo) CreatePayoutRequest(ctx context.Context, p CreatePayoutRequ
 	return rec, err
 }
 
+func (r *walletRepo) CreatePayoutRequest(ctx context.Context, tx DBTX, p CreatePayoutRequestParams) (PayoutRequestRecord, error) {
+	const q = `
+		INSERT INTO payout_requests (
+			user_id, amount, bank_code, bank_name, account_number, account_holder_name,
+			status, provider_reference, raw_response, partner_trx_id, beneficiary_id, beneficiary_email, notes,
+			requested_at, completed_at, provider
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::payout_request_status, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14, $15, $16)
+		RETURNING ` + payoutRequestColumns
+	return scanPayoutRequest(tx.QueryRowContext(ctx, q,
+		p.UserID,
+		p.Amount,
+		p.BankCode,
+		p.BankName,
+		p.AccountNumber,
+		p.AccountHolderName,
+		p.Status,
+		p.ProviderReference,
+		p.RawResponse,
+		p.PartnerTrxID,
+		p.BeneficiaryID,
+		p.BeneficiaryEmail,
+		p.Notes,
+		p.RequestedAt,
+		p.CompletedAt,
+		p.Provider,
+	))
+}
+
 func (r *walletRepo) updatePayoutRequestStatus(ctx context.Context, exec DBTX, p UpdatePayoutRequestStatusParams) error {
 	const q = `
 		UPDATE payout_requests
 		SET status = $2,
-		    midtrans_payout_id = COALESCE($3, midtrans_payout_id),
+		    provider_reference = COALESCE($3, provider_reference),
 		    raw_response = COALESCE($4, raw_response),
 		    completed_at = COALESCE($5, completed_at),
+		    transaction_id = COALESCE($6, transaction_id),
+		    last_error = COALESCE($7, last_error),
+		    next_attempt_at = COALESCE($8, next_attempt_at),
+		    approval_status = COALESCE($9, approval_status),
+		    dispatch_in_flight = COALESCE($10, dispatch_in_flight),
 		    updated_at = now()
 		WHERE id = $1
+		  AND status NOT IN ('COMPLETED', 'FAILED') -- status final tidak boleh tertimpa
 	`
-	_, err := exec.ExecContext(ctx, q, p.ID, p.Status, p.MidtransPayoutID, p.RawResponse, p.CompletedAt)
+	_, err := exec.ExecContext(ctx, q, p.ID, p.Status, p.ProviderReference, p.RawResponse, p.CompletedAt, p.TransactionID, p.LastError, p.NextAttemptAt, p.ApprovalStatus, p.DispatchInFlight)
 	return err
 }
 
@@ -499,33 +1081,174 @@ func (r *walletRepo) UpdatePayoutRequestStatusTx(ctx context.Context, tx DBTX, p
 	return r.updatePayoutRequestStatus(ctx, tx, p)
 }
 
+func (r *walletRepo) getPayoutRequest(ctx context.Context, exec DBTX, q string, args ...any) (PayoutRequestRecord, error) {
+	rec, err := scanPayoutRequest(exec.QueryRowContext(ctx, q, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound{Message: "payout request not found"}
+	}
+	return rec, err
+}
+
 func (r *walletRepo) GetPayoutRequestByID(ctx context.Context, id string) (PayoutRequestRecord, error) {
+	const q = `SELECT ` + payoutRequestColumns + ` FROM payout_requests WHERE id = $1`
+	return r.getPayoutRequest(ctx, r.db, q, id)
+}
+
+func (r *walletRepo) GetPayoutRequestByProviderReference(ctx context.Context, provider, reference string) (PayoutRequestRecord, error) {
+	const q = `SELECT ` + payoutRequestColumns + ` FROM payout_requests WHERE provider = $1 AND provider_reference = $2`
+	return r.getPayoutRequest(ctx, r.db, q, provider, reference)
+}
+
+func (r *walletRepo) GetPayoutRequestForUpdate(ctx context.Context, tx DBTX, id string) (PayoutRequestRecord, error) {
+	const q = `SELECT ` + payoutRequestColumns + ` FROM payout_requests WHERE id = $1 FOR UPDATE`
+	return r.getPayoutRequest(ctx, tx, q, id)
+}
+
+func (r *walletRepo) ListDispatchablePayoutIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
 	const q = `
-		SELECT id, user_id, amount, bank_code, bank_name, account_number, account_holder_name,
-		       status, midtrans_payout_id, raw_response, requested_at, completed_at,
-		       created_at, updated_at
+		SELECT id
 		FROM payout_requests
-		WHERE id = $1
+		WHERE status = 'PENDING'
+		  AND (next_attempt_at IS NULL OR next_attempt_at <= $1)
+		ORDER BY requested_at
+		LIMIT $2
 	`
-	var rec PayoutRequestRecord
-	err := r.db.QueryRowContext(ctx, q, id).Scan(
-		&rec.ID,
-		&rec.UserID,
-		&rec.Amount,
-		&rec.BankCode,
-		&rec.BankName,
-		&rec.AccountNumber,
-		&rec.AccountHolderName,
-		&rec.Status,
-		&rec.MidtransPayoutID,
-		&rec.RawResponse,
-		&rec.RequestedAt,
-		&rec.CompletedAt,
-		&rec.CreatedAt,
-		&rec.UpdatedAt,
-	)
+	rows, err := r.db.QueryContext(ctx, q, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *walletRepo) ClaimPayoutDispatch(ctx context.Context, id string, now, leaseUntil time.Time) (PayoutRequestRecord, bool, error) {
+	const q = `
+		UPDATE payout_requests
+		SET attempts = attempts + 1,
+		    next_attempt_at = $3,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = 'PENDING'
+		  AND (next_attempt_at IS NULL OR next_attempt_at <= $2)
+		RETURNING ` + payoutRequestColumns
+	rec, err := scanPayoutRequest(r.db.QueryRowContext(ctx, q, id, now, leaseUntil))
 	if errors.Is(err, sql.ErrNoRows) {
-		return rec, ErrNotFound{Message: "payout request not found"}
+		return rec, false, nil
 	}
-	return rec, err
+	return rec, err == nil, err
+}
+
+func (r *walletRepo) ListPayoutApprovals(ctx context.Context, approvalStatus string, limit int) ([]PayoutRequestRecord, error) {
+	const q = `
+		SELECT ` + payoutRequestColumns + `
+		FROM payout_requests
+		WHERE status = 'REQUESTED' AND approval_status = $1
+		ORDER BY requested_at
+		LIMIT $2
+	`
+	return r.listPayoutRequests(ctx, q, approvalStatus, limit)
+}
+
+func (r *walletRepo) ListPayoutRequestsByStatus(ctx context.Context, status string, limit int) ([]PayoutRequestRecord, error) {
+	const q = `
+		SELECT ` + payoutRequestColumns + `
+		FROM payout_requests
+		WHERE status = $1::payout_request_status
+		ORDER BY requested_at
+		LIMIT $2
+	`
+	return r.listPayoutRequests(ctx, q, status, limit)
+}
+
+func (r *walletRepo) listPayoutRequests(ctx context.Context, q string, args ...any) ([]PayoutRequestRecord, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PayoutRequestRecord
+	for rows.Next() {
+		rec, err := scanPayoutRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (r *walletRepo) DecidePayoutApproval(ctx context.Context, tx DBTX, p DecidePayoutApprovalParams) (bool, error) {
+	const q = `
+		UPDATE payout_requests
+		SET approval_status = $3,
+		    approved_by = $4,
+		    approval_note = NULLIF($5, ''),
+		    approval_decided_at = $6,
+		    approval_in_flight_at = NULL,
+		    updated_at = now()
+		WHERE id = $1 AND approval_status = $2
+	`
+	res, err := tx.ExecContext(ctx, q, p.ID, p.From, p.Status, p.DecidedBy, p.Note, p.DecidedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *walletRepo) setPayoutApprovalInFlight(ctx context.Context, exec DBTX, id string, at *time.Time) error {
+	const q = `UPDATE payout_requests SET approval_in_flight_at = $2, updated_at = now() WHERE id = $1`
+	_, err := exec.ExecContext(ctx, q, id, at)
+	return err
+}
+
+func (r *walletRepo) SetPayoutApprovalInFlight(ctx context.Context, id string, at *time.Time) error {
+	return r.setPayoutApprovalInFlight(ctx, r.db, id, at)
+}
+
+func (r *walletRepo) SetPayoutApprovalInFlightTx(ctx context.Context, tx DBTX, id string, at *time.Time) error {
+	return r.setPayoutApprovalInFlight(ctx, tx, id, at)
+}
+
+func (r *walletRepo) SetPayoutLastError(ctx context.Context, id, msg string) error {
+	const q = `
+		UPDATE payout_requests
+		SET last_error = $2, updated_at = now()
+		WHERE id = $1 AND status NOT IN ('COMPLETED', 'FAILED')
+	`
+	_, err := r.db.ExecContext(ctx, q, id, msg)
+	return err
+}
+
+func (r *walletRepo) ResolvePayoutRequest(ctx context.Context, tx DBTX, p ResolvePayoutRequestParams) (bool, error) {
+	const q = `
+		UPDATE payout_requests
+		SET status = $2::payout_request_status,
+		    provider_reference = COALESCE($3, provider_reference),
+		    approval_status = COALESCE($4, approval_status),
+		    next_attempt_at = COALESCE($5, next_attempt_at),
+		    completed_at = COALESCE($6, completed_at),
+		    dispatch_in_flight = false,
+		    resolved_by = $7,
+		    resolution_note = NULLIF($8, ''),
+		    resolved_at = $9,
+		    updated_at = now()
+		WHERE id = $1 AND status = 'UNKNOWN'
+	`
+	res, err := tx.ExecContext(ctx, q, p.ID, p.Status, p.ProviderReference, p.ApprovalStatus, p.NextAttemptAt, p.CompletedAt, p.ResolvedBy, p.Note, p.ResolvedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+)
+
+// BalanceBackfillService mengisi balance_after untuk transaksi yang dibuat sebelum
+// kolom tersebut ada. Saldo dihitung mundur dari transaksi terbaru yang sudah punya
+// balance_after, atau dari wallet_summary bila belum ada sama sekali, supaya baris
+// terbaru selalu cocok dengan saldo yang dilihat user.
+type BalanceBackfillService struct {
+	repo   repositories.BackfillRepo
+	wallet repositories.WalletRepo
+}
+
+func NewBalanceBackfillService(r repositories.BackfillRepo, wallet repositories.WalletRepo) *BalanceBackfillService {
+	return &BalanceBackfillService{repo: r, wallet: wallet}
+}
+
+type BackfillOptions struct {
+	UserID string // kosong = semua user
+	DryRun bool
+}
+
+type BackfillReport struct {
+	Users int `json:"users"`
+	Rows  int `json:"rows"`
+}
+
+const backfillBatchSize = 200
+
+func (s *BalanceBackfillService) Run(ctx context.Context, opts BackfillOptions) (BackfillReport, error) {
+	var report BackfillReport
+	if opts.UserID != "" {
+		err := s.backfillUser(ctx, opts.UserID, opts.DryRun, &report)
+		return report, err
+	}
+	after := ""
+	for {
+		ids, err := s.repo.ListUsersMissingBalanceAfter(ctx, after, backfillBatchSize)
+		if err != nil {
+			return report, err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := s.backfillUser(ctx, id, opts.DryRun, &report); err != nil {
+				return report, fmt.Errorf("backfill user %s: %w", id, err)
+			}
+		}
+		if len(ids) < backfillBatchSize {
+			return report, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func (s *BalanceBackfillService) backfillUser(ctx context.Context, userID string, dryRun bool, report *BackfillReport) error {
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// kunci wallet supaya tidak ada transaksi baru selama saldo dihitung mundur
+	_, topup, redeem, _, err := s.wallet.GetSaldoForUpdate(ctx, tx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if !errors.As(err, &notFound) {
+			return err
+		}
+	}
+	rows, err := s.repo.ListTransactionsForBackfill(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	filled := 0
+	for _, row := range rows {
+		if row.BalanceAfterTopup != nil && row.BalanceAfterRedeem != nil {
+			// baris yang sudah tercatat menjadi patokan untuk baris sebelumnya
+			topup, redeem = *row.BalanceAfterTopup, *row.BalanceAfterRedeem
+		} else {
+			if !dryRun {
+				if err := s.repo.SetBalanceAfter(ctx, tx, row.ID, topup, redeem); err != nil {
+					return err
+				}
+			}
+			filled++
+		}
+		dTopup, dRedeem, err := transactionDelta(row.Type, row.Amount)
+		if err != nil {
+			return err
+		}
+		topup, redeem = topup.Sub(dTopup), redeem.Sub(dRedeem)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if filled > 0 {
+		report.Users++
+		report.Rows += filled
+	}
+	return nil
+}
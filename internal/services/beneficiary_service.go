@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+)
+
+// BeneficiaryService mengelola rekening tujuan tarik saldo. Rekening baru hanya
+// disimpan setelah provider payout untuk banknya mengonfirmasi rekeningnya ada, dan
+// nama pemilik yang disimpan selalu nama dari bank, bukan ketikan user.
+type BeneficiaryService struct {
+	repo      repositories.BeneficiaryRepo
+	providers *PayoutProviders
+	validate  *validator.Validate
+	now       func() time.Time
+}
+
+func NewBeneficiaryService(r repositories.BeneficiaryRepo, providers *PayoutProviders, v *validator.Validate) *BeneficiaryService {
+	return &BeneficiaryService{repo: r, providers: providers, validate: v, now: time.Now}
+}
+
+type CreateBeneficiaryInput struct {
+	UserID            string `json:"userId"            validate:"required,uuid4"`
+	BankCode          string `json:"bankCode"          validate:"required,max=32"`
+	BankName          string `json:"bankName"          validate:"max=128"`
+	AccountNumber     string `json:"accountNumber"     validate:"required,numeric,max=64"`
+	AccountHolderName string `json:"accountHolderName" validate:"max=128"`
+	Alias             string `json:"alias"             validate:"max=64"`
+}
+
+type BeneficiaryDTO struct {
+	ID                string    `json:"id"`
+	Alias             string    `json:"alias,omitempty"`
+	BankCode          string    `json:"bankCode"`
+	BankName          string    `json:"bankName,omitempty"`
+	AccountNumber     string    `json:"accountNumber"`
+	AccountHolderName string    `json:"accountHolderName"`
+	ValidatedAt       time.Time `json:"validatedAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+func toBeneficiaryDTO(rec repositories.BeneficiaryRecord) BeneficiaryDTO {
+	return BeneficiaryDTO{
+		ID:                rec.ID,
+		Alias:             rec.Alias.String,
+		BankCode:          rec.BankCode,
+		BankName:          rec.BankName.String,
+		AccountNumber:     rec.AccountNumber,
+		AccountHolderName: rec.AccountHolderName,
+		ValidatedAt:       rec.ValidatedAt,
+		CreatedAt:         rec.CreatedAt,
+	}
+}
+
+// sameHolderName membandingkan nama tanpa peduli huruf besar/kecil dan spasi ganda.
+func sameHolderName(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// Create memvalidasi rekening ke provider payout lalu menyimpannya. Bila user mengisi nama
+// pemilik, nama itu harus sama dengan nama dari bank.
+func (s *BeneficiaryService) Create(ctx context.Context, in CreateBeneficiaryInput) (BeneficiaryDTO, error) {
+	in.BankCode = strings.ToLower(strings.TrimSpace(in.BankCode))
+	in.AccountNumber = strings.TrimSpace(in.AccountNumber)
+	if err := s.validate.Struct(in); err != nil {
+		return BeneficiaryDTO{}, ErrBadRequest{Err: err}
+	}
+	provider, err := s.providers.ForBank(in.BankCode)
+	if err != nil {
+		return BeneficiaryDTO{}, ErrConflict{Msg: "validasi rekening sedang tidak tersedia"}
+	}
+
+	acc, err := provider.ValidateAccount(ctx, in.BankCode, in.AccountNumber)
+	if err != nil {
+		if isPermanent(err) {
+			return BeneficiaryDTO{}, ErrBadRequest{Err: errors.New("rekening tidak ditemukan di bank tujuan")}
+		}
+		return BeneficiaryDTO{}, err
+	}
+	if strings.TrimSpace(acc.AccountName) == "" {
+		return BeneficiaryDTO{}, ErrBadRequest{Err: errors.New("rekening tidak ditemukan di bank tujuan")}
+	}
+	if in.AccountHolderName != "" && !sameHolderName(in.AccountHolderName, acc.AccountName) {
+		return BeneficiaryDTO{}, ErrBadRequest{Err: errors.New("nama pemilik rekening tidak sesuai dengan data bank")}
+	}
+
+	rec, ok, err := s.repo.CreateBeneficiary(ctx, repositories.CreateBeneficiaryParams{
+		UserID:            in.UserID,
+		Alias:             strings.TrimSpace(in.Alias),
+		BankCode:          in.BankCode,
+		BankName:          firstNonEmpty(strings.TrimSpace(in.BankName), acc.BankName),
+		AccountNumber:     in.AccountNumber,
+		AccountHolderName: strings.TrimSpace(acc.AccountName),
+		ValidatedAt:       s.now(),
+	})
+	if err != nil {
+		return BeneficiaryDTO{}, err
+	}
+	if !ok {
+		return BeneficiaryDTO{}, ErrConflict{Msg: "rekening sudah tersimpan"}
+	}
+	return toBeneficiaryDTO(rec), nil
+}
+
+func (s *BeneficiaryService) List(ctx context.Context, userID string) ([]BeneficiaryDTO, error) {
+	if err := s.validate.Var(userID, "required,uuid4"); err != nil {
+		return nil, ErrBadRequest{Err: err}
+	}
+	recs, err := s.repo.ListBeneficiaries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BeneficiaryDTO, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, toBeneficiaryDTO(rec))
+	}
+	return out, nil
+}
+
+func (s *BeneficiaryService) Delete(ctx context.Context, userID, id string) error {
+	if err := s.validate.Var(userID, "required,uuid4"); err != nil {
+		return ErrBadRequest{Err: err}
+	}
+	if err := s.validate.Var(id, "required,uuid4"); err != nil {
+		return ErrBadRequest{Err: err}
+	}
+	if err := s.repo.DeleteBeneficiary(ctx, userID, id, s.now()); err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return ErrNotFoundResource{Msg: "rekening tidak ditemukan"}
+		}
+		return err
+	}
+	return nil
+}
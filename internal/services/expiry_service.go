@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// ExpiryService menghanguskan lot saldo redeem dan ev_poin yang sudah lewat
+// masa berlaku. Saldo redeem yang hangus dicatat sebagai transaksi
+// KEDALUWARSA_REDEEM, poin yang hangus dicatat di points_ledger.
+type ExpiryService struct {
+	lots   repositories.LotRepo
+	wallet repositories.WalletRepo
+	ledger *LedgerService
+	points *PointsService
+	now    func() time.Time
+}
+
+func NewExpiryService(lots repositories.LotRepo, wallet repositories.WalletRepo, ledger *LedgerService, points *PointsService) *ExpiryService {
+	return &ExpiryService{lots: lots, wallet: wallet, ledger: ledger, points: points, now: time.Now}
+}
+
+type ExpirySweepReport struct {
+	Users         int          `json:"users"`
+	RedeemExpired money.Amount `json:"saldoRedeemExpired"`
+	PointsExpired int          `json:"poinExpired"`
+}
+
+const expiryBatchSize = 200
+
+func (s *ExpiryService) Sweep(ctx context.Context) (ExpirySweepReport, error) {
+	var report ExpirySweepReport
+	now := s.now()
+	after := ""
+	for {
+		ids, err := s.lots.ListDueUserIDs(ctx, now, after, expiryBatchSize)
+		if err != nil {
+			return report, err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := s.expireUser(ctx, id, now, &report); err != nil {
+				return report, fmt.Errorf("kedaluwarsa user %s: %w", id, err)
+			}
+		}
+		if len(ids) < expiryBatchSize {
+			return report, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func (s *ExpiryService) expireUser(ctx context.Context, userID string, now time.Time, report *ExpirySweepReport) error {
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, _, redeem, poin, err := s.wallet.GetSaldoForUpdate(ctx, tx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+	_, heldRedeem, err := s.wallet.GetHeldTx(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	lots, err := s.lots.ListDueLotsForUpdate(ctx, tx, userID, now)
+	if err != nil {
+		return err
+	}
+
+	// Saldo yang sedang di-hold tidak ikut hangus; sisa lotnya diproses lagi
+	// pada sweep berikutnya setelah hold dilepas.
+	availRedeem := redeem.Sub(heldRedeem)
+	if availRedeem.IsNegative() {
+		availRedeem = 0
+	}
+	availPoints := pointsQuantity(poin)
+
+	var redeemExpired, pointsExpired money.Amount
+	for _, lot := range lots {
+		avail := &availRedeem
+		if lot.Kind == repositories.LotKindPoints {
+			avail = &availPoints
+		}
+		take := lot.Remaining
+		if take.Cmp(*avail) > 0 {
+			take = *avail
+		}
+		*avail = avail.Sub(take)
+
+		left := lot.Remaining.Sub(take)
+		if lot.Kind == repositories.LotKindPoints || heldRedeem.IsZero() {
+			// saldo sudah terpakai di luar pencatatan lot; tutup lotnya
+			left = 0
+		}
+		var expiredAt *time.Time
+		if left.IsZero() {
+			expiredAt = &now
+		}
+		if err := s.lots.SetRemaining(ctx, tx, lot.ID, left, expiredAt); err != nil {
+			return err
+		}
+
+		if lot.Kind == repositories.LotKindPoints {
+			pointsExpired = pointsExpired.Add(take)
+		} else {
+			redeemExpired = redeemExpired.Add(take)
+		}
+	}
+
+	ref := fmt.Sprintf("EXP-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	if redeemExpired.IsPositive() {
+		txnID, err := createTransaction(ctx, s.wallet, tx, repositories.CreateTransactionParams{
+			UserID:        userID,
+			TipeTransaksi: "KEDALUWARSA_REDEEM",
+			Jumlah:        redeemExpired,
+			Deskripsi:     "Saldo redeem kedaluwarsa",
+			ReferensiID:   &ref,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+			EntryType:     "KEDALUWARSA_REDEEM",
+			TransactionID: &txnID,
+			ReferensiID:   &ref,
+			Deskripsi:     "Saldo redeem kedaluwarsa",
+			Postings: []LedgerPosting{
+				ledgerDebit(userID, repositories.LedgerAccountUserRedeem, redeemExpired),
+				ledgerCredit("", repositories.LedgerAccountExpiredBalance, redeemExpired),
+			},
+			CreatedAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+	if pointsExpired.IsPositive() {
+		if err := s.points.Expire(ctx, tx, int(pointsExpired.Rupiah()), PointsEvent{
+			UserID:     userID,
+			EventType:  PointsEventExpire,
+			SourceID:   ref,
+			Deskripsi:  "ev_poin kedaluwarsa",
+			OccurredAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	report.Users++
+	report.RedeemExpired = report.RedeemExpired.Add(redeemExpired)
+	report.PointsExpired += int(pointsExpired.Rupiah())
+	return nil
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// HoldService mengelola hold saldo untuk alur bayar-belakangan (mis. sesi charging
+// EV): dana ditahan di awal, lalu di-capture sesuai tagihan akhir atau dilepas.
+// Hold hanya mengurangi saldo tersedia; ledger baru berubah saat capture.
+type HoldService struct {
+	repo     repositories.HoldRepo
+	wallet   repositories.WalletRepo
+	ledger   *LedgerService
+	points   *PointsService
+	lots     *LotService
+	validate *validator.Validate
+	cfg      HoldConfig
+	now      func() time.Time
+}
+
+type HoldConfig struct {
+	DefaultTTL time.Duration // masa berlaku hold bila klien tidak mengirim expiresInSeconds
+	MaxTTL     time.Duration
+}
+
+func NewHoldService(r repositories.HoldRepo, wallet repositories.WalletRepo, ledger *LedgerService, points *PointsService, lots *LotService, v *validator.Validate, cfg HoldConfig) *HoldService {
+	return &HoldService{repo: r, wallet: wallet, ledger: ledger, points: points, lots: lots, validate: v, cfg: cfg, now: time.Now}
+}
+
+type CreateHoldInput struct {
+	UserID      string       `json:"userId"           validate:"required,uuid4"`
+	Jumlah      money.Amount `json:"jumlah"           validate:"required,gt=0"`
+	BalanceType string       `json:"balanceType"      validate:"required"`
+	ReferensiID string       `json:"referensiId"      validate:"max=128"` // mis. ID sesi charging
+	Deskripsi   string       `json:"deskripsi"        validate:"max=255"`
+	ExpiresIn   int          `json:"expiresInSeconds" validate:"gte=0"`
+}
+
+type CaptureHoldInput struct {
+	Jumlah money.Amount `json:"jumlah" validate:"required,gt=0"`
+}
+
+type HoldDTO struct {
+	ID             string        `json:"id"`
+	UserID         string        `json:"userId"`
+	BalanceType    string        `json:"balanceType"`
+	Amount         money.Amount  `json:"amount"`
+	CapturedAmount *money.Amount `json:"capturedAmount,omitempty"`
+	Status         string        `json:"status"`
+	ReferensiID    *string       `json:"referensiId,omitempty"`
+	Deskripsi      string        `json:"deskripsi,omitempty"`
+	TransactionID  *string       `json:"transactionId,omitempty"`
+	ExpiresAt      time.Time     `json:"expiresAt"`
+	ReleasedAt     *time.Time    `json:"releasedAt,omitempty"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+func toHoldDTO(rec repositories.HoldRecord) HoldDTO {
+	dto := HoldDTO{
+		ID:          rec.ID,
+		UserID:      rec.UserID,
+		BalanceType: rec.BalanceType,
+		Amount:      rec.Amount,
+		Status:      rec.Status,
+		Deskripsi:   rec.Deskripsi.String,
+		ExpiresAt:   rec.ExpiresAt,
+		CreatedAt:   rec.CreatedAt,
+	}
+	if rec.Status == repositories.HoldStatusCaptured {
+		captured := rec.CapturedAmount.Amount
+		dto.CapturedAmount = &captured
+	}
+	if rec.ReferensiID.Valid {
+		ref := rec.ReferensiID.String
+		dto.ReferensiID = &ref
+	}
+	if rec.TransactionID.Valid {
+		id := rec.TransactionID.String
+		dto.TransactionID = &id
+	}
+	if rec.ReleasedAt.Valid {
+		t := rec.ReleasedAt.Time
+		dto.ReleasedAt = &t
+	}
+	return dto
+}
+
+// Get, Capture dan Void hanya melayani hold milik ownerID; hold user lain diperlakukan
+// seperti tidak ada.
+func (s *HoldService) Get(ctx context.Context, ownerID, holdID string) (HoldDTO, error) {
+	rec, err := s.getHold(ctx, ownerID, holdID)
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	return toHoldDTO(rec), nil
+}
+
+// Create menahan dana dari sub-saldo pilihan user selama masa berlaku hold.
+func (s *HoldService) Create(ctx context.Context, in CreateHoldInput) (HoldDTO, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return HoldDTO{}, ErrBadRequest{Err: err}
+	}
+	target, err := parseBalanceTarget(in.BalanceType)
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	ttl := s.cfg.DefaultTTL
+	if in.ExpiresIn > 0 {
+		ttl = time.Duration(in.ExpiresIn) * time.Second
+	}
+	if s.cfg.MaxTTL > 0 && ttl > s.cfg.MaxTTL {
+		return HoldDTO{}, ErrBadRequest{Err: fmt.Errorf("masa berlaku hold maksimal %s", s.cfg.MaxTTL)}
+	}
+
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	defer tx.Rollback()
+
+	_, topup, redeem, _, err := s.wallet.GetSaldoForUpdate(ctx, tx, in.UserID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return HoldDTO{}, ErrNotFoundResource{Msg: "wallet tidak ditemukan"}
+		}
+		return HoldDTO{}, err
+	}
+	if err := ensureWalletAllows(ctx, s.wallet, tx, in.UserID, walletDebit); err != nil {
+		return HoldDTO{}, err
+	}
+	heldTopup, heldRedeem, err := s.wallet.GetHeldTx(ctx, tx, in.UserID)
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	available := topup.Sub(heldTopup)
+	if target == "redeem" {
+		available = redeem.Sub(heldRedeem)
+	}
+	if available.Cmp(in.Jumlah) < 0 {
+		return HoldDTO{}, ErrInsufficientBalance{Msg: fmt.Sprintf("saldo %s tersedia tidak mencukupi", target)}
+	}
+
+	now := s.now()
+	var ref *string
+	if v := strings.TrimSpace(in.ReferensiID); v != "" {
+		ref = &v
+	}
+	rec, err := s.repo.CreateHold(ctx, tx, repositories.CreateHoldParams{
+		UserID:      in.UserID,
+		BalanceType: target,
+		Amount:      in.Jumlah,
+		ReferensiID: ref,
+		Deskripsi:   strings.TrimSpace(in.Deskripsi),
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	if err := s.repo.AdjustHeld(ctx, tx, in.UserID, target, in.Jumlah); err != nil {
+		return HoldDTO{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return HoldDTO{}, err
+	}
+	return toHoldDTO(rec), nil
+}
+
+// Capture menagih jumlah akhir dari hold. Capture sebagian otomatis melepas sisanya.
+func (s *HoldService) Capture(ctx context.Context, ownerID, holdID string, in CaptureHoldInput) (HoldDTO, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return HoldDTO{}, ErrBadRequest{Err: err}
+	}
+
+	var captured repositories.HoldRecord
+	err := s.withActiveHold(ctx, ownerID, holdID, func(tx repositories.DBTX, hold repositories.HoldRecord, now time.Time) error {
+		if in.Jumlah.Cmp(hold.Amount) > 0 {
+			return ErrBadRequest{Err: fmt.Errorf("jumlah capture melebihi hold Rp%s", hold.Amount)}
+		}
+		// void dan expire tetap boleh karena hanya melepas dana
+		if err := ensureWalletAllows(ctx, s.wallet, tx, hold.UserID, walletDebit); err != nil {
+			return err
+		}
+
+		account, txnType := repositories.LedgerAccountUserTopup, "PEMBAYARAN_TOPUP"
+		if hold.BalanceType == "redeem" {
+			account, txnType = repositories.LedgerAccountUserRedeem, "PEMBAYARAN_REDEEM"
+		}
+		desc := "Pembayaran"
+		if hold.Deskripsi.Valid && hold.Deskripsi.String != "" {
+			desc = hold.Deskripsi.String
+		}
+		ref := hold.ID
+		if hold.ReferensiID.Valid {
+			ref = hold.ReferensiID.String
+		}
+
+		// lepas seluruh hold dulu, lalu debit saldo sebesar jumlah akhir
+		if err := s.repo.AdjustHeld(ctx, tx, hold.UserID, hold.BalanceType, hold.Amount.Neg()); err != nil {
+			return err
+		}
+		txnID, err := createTransaction(ctx, s.wallet, tx, repositories.CreateTransactionParams{
+			UserID:        hold.UserID,
+			TipeTransaksi: txnType,
+			Jumlah:        in.Jumlah,
+			Deskripsi:     desc,
+			ReferensiID:   &ref,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+			EntryType:     txnType,
+			TransactionID: &txnID,
+			ReferensiID:   &hold.ID,
+			Deskripsi:     "Capture hold " + hold.ID,
+			Postings: []LedgerPosting{
+				ledgerDebit(hold.UserID, account, in.Jumlah),
+				ledgerCredit("", repositories.LedgerAccountMerchant, in.Jumlah),
+			},
+			CreatedAt: now,
+		}); err != nil {
+			return err
+		}
+		if hold.BalanceType == "redeem" {
+			if _, err := s.lots.Consume(ctx, tx, hold.UserID, repositories.LotKindRedeem, in.Jumlah); err != nil {
+				return err
+			}
+		}
+		if _, err := s.points.Award(ctx, tx, PointsEvent{
+			UserID:        hold.UserID,
+			EventType:     PointsEventSpend,
+			Amount:        in.Jumlah,
+			SourceID:      hold.ID,
+			TransactionID: &txnID,
+			Deskripsi:     "Poin " + strings.ToLower(desc),
+			OccurredAt:    now,
+		}); err != nil {
+			return err
+		}
+
+		amount := in.Jumlah
+		if err := s.repo.FinishHold(ctx, tx, repositories.FinishHoldParams{
+			ID:             hold.ID,
+			Status:         repositories.HoldStatusCaptured,
+			CapturedAmount: &amount,
+			TransactionID:  &txnID,
+			ReleasedAt:     now,
+		}); err != nil {
+			return err
+		}
+
+		captured = hold
+		captured.Status = repositories.HoldStatusCaptured
+		captured.CapturedAmount = money.NullAmount{Amount: amount, Valid: true}
+		captured.TransactionID.String, captured.TransactionID.Valid = txnID, true
+		captured.ReleasedAt.Time, captured.ReleasedAt.Valid = now, true
+		return nil
+	})
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	return toHoldDTO(captured), nil
+}
+
+// Void melepas seluruh hold tanpa menagih apa pun.
+func (s *HoldService) Void(ctx context.Context, ownerID, holdID string) (HoldDTO, error) {
+	var voided repositories.HoldRecord
+	err := s.withActiveHold(ctx, ownerID, holdID, func(tx repositories.DBTX, hold repositories.HoldRecord, now time.Time) error {
+		if err := s.release(ctx, tx, hold, repositories.HoldStatusVoided, now); err != nil {
+			return err
+		}
+		voided = hold
+		voided.Status = repositories.HoldStatusVoided
+		voided.ReleasedAt.Time, voided.ReleasedAt.Valid = now, true
+		return nil
+	})
+	if err != nil {
+		return HoldDTO{}, err
+	}
+	return toHoldDTO(voided), nil
+}
+
+const holdExpiryBatchSize = 200
+
+// ExpireStale melepas hold ACTIVE yang sudah lewat masa berlaku. Dipanggil oleh job terjadwal.
+func (s *HoldService) ExpireStale(ctx context.Context) (int, error) {
+	expired := 0
+	for {
+		ids, err := s.repo.ListExpiredHoldIDs(ctx, s.now(), holdExpiryBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return expired, err
+			}
+			ok, err := s.expireHold(ctx, id)
+			if err != nil {
+				return expired, fmt.Errorf("expire hold %s: %w", id, err)
+			}
+			if ok {
+				expired++
+			}
+		}
+		if len(ids) < holdExpiryBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+func (s *HoldService) expireHold(ctx context.Context, holdID string) (bool, error) {
+	hold, err := s.repo.GetHold(ctx, holdID)
+	if err != nil {
+		return false, err
+	}
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, _, _, _, err := s.wallet.GetSaldoForUpdate(ctx, tx, hold.UserID); err != nil {
+		return false, err
+	}
+	hold, err = s.repo.GetHoldForUpdate(ctx, tx, holdID)
+	if err != nil {
+		return false, err
+	}
+	// bisa saja sudah di-capture/void di antara list dan lock
+	now := s.now()
+	if hold.Status != repositories.HoldStatusActive || hold.ExpiresAt.After(now) {
+		return false, nil
+	}
+	if err := s.release(ctx, tx, hold, repositories.HoldStatusExpired, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// withActiveHold mengunci wallet lalu hold (urutan yang sama dengan alur saldo lain)
+// dan menjalankan fn hanya bila hold masih ACTIVE. Hold yang ternyata sudah lewat
+// masa berlaku langsung di-expire.
+func (s *HoldService) withActiveHold(ctx context.Context, ownerID, holdID string, fn func(tx repositories.DBTX, hold repositories.HoldRecord, now time.Time) error) error {
+	hold, err := s.getHold(ctx, ownerID, holdID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, _, _, err := s.wallet.GetSaldoForUpdate(ctx, tx, hold.UserID); err != nil {
+		return err
+	}
+	hold, err = s.repo.GetHoldForUpdate(ctx, tx, holdID)
+	if err != nil {
+		return err
+	}
+	if hold.Status != repositories.HoldStatusActive {
+		return ErrConflict{Msg: fmt.Sprintf("hold sudah berstatus %s", hold.Status)}
+	}
+
+	now := s.now()
+	if !hold.ExpiresAt.After(now) {
+		if err := s.release(ctx, tx, hold, repositories.HoldStatusExpired, now); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrConflict{Msg: "hold sudah kedaluwarsa"}
+	}
+
+	if err := fn(tx, hold, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *HoldService) release(ctx context.Context, tx repositories.DBTX, hold repositories.HoldRecord, status string, now time.Time) error {
+	if err := s.repo.AdjustHeld(ctx, tx, hold.UserID, hold.BalanceType, hold.Amount.Neg()); err != nil {
+		return err
+	}
+	return s.repo.FinishHold(ctx, tx, repositories.FinishHoldParams{
+		ID:         hold.ID,
+		Status:     status,
+		ReleasedAt: now,
+	})
+}
+
+func (s *HoldService) getHold(ctx context.Context, ownerID, holdID string) (repositories.HoldRecord, error) {
+	if err := s.validate.Var(holdID, "required,uuid"); err != nil {
+		return repositories.HoldRecord{}, ErrBadRequest{Err: errors.New("holdId tidak valid")}
+	}
+	rec, err := s.repo.GetHold(ctx, holdID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return rec, ErrNotFoundResource{Msg: "hold tidak ditemukan"}
+		}
+		return rec, err
+	}
+	// tidak membedakan "bukan milikmu" dari "tidak ada" agar id hold tidak bisa ditebak
+	if ownerID == "" || rec.UserID != ownerID {
+		return repositories.HoldRecord{}, ErrNotFoundResource{Msg: "hold tidak ditemukan"}
+	}
+	return rec, nil
+}
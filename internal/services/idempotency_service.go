@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+)
+
+// IdempotencyService menyimpan hasil pertama request yang membawa Idempotency-Key
+// supaya retry dari klien tidak memindahkan uang dua kali.
+// Key dipisah per pemilik dan scope endpoint. Key yang IN_PROGRESS dipegang selama
+// lease; bila prosesnya mati sebelum selesai, retry dengan body sama boleh mengambil
+// alih setelah lease habis tanpa menunggu ttl.
+type IdempotencyService struct {
+	repo  repositories.IdempotencyRepo
+	ttl   time.Duration
+	lease time.Duration
+	now   func() time.Time
+}
+
+func NewIdempotencyService(r repositories.IdempotencyRepo, ttl, lease time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if lease <= 0 {
+		lease = 2 * time.Minute
+	}
+	return &IdempotencyService{repo: r, ttl: ttl, lease: lease, now: time.Now}
+}
+
+// StoredResponse adalah respons awal yang diputar ulang untuk retry.
+type StoredResponse struct {
+	Code int
+	Body []byte
+}
+
+// IdempotencyTicket dikembalikan Begin. Replay terisi bila key sudah selesai
+// diproses sebelumnya; selain itu pemanggil wajib memanggil Finish atau Abort.
+type IdempotencyTicket struct {
+	id     string
+	Replay *StoredResponse
+}
+
+// RequestFingerprint menghasilkan hash SHA-256 dari scope dan body request.
+func RequestFingerprint(scope string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(scope))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin memesan key milik owner untuk scope. owner kosong berarti pemilik request
+// tidak diketahui.
+func (s *IdempotencyService) Begin(ctx context.Context, owner, scope, key, fingerprint string) (IdempotencyTicket, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || len(key) > 255 {
+		return IdempotencyTicket{}, ErrBadRequest{Err: errors.New("Idempotency-Key harus 1-255 karakter")}
+	}
+
+	now := s.now()
+	rec, reserved, err := s.repo.Reserve(ctx, repositories.ReserveIdempotencyKeyParams{
+		OwnerID:     owner,
+		Scope:       scope,
+		Key:         key,
+		RequestHash: fingerprint,
+		ExpiresAt:   now.Add(s.ttl),
+		LockedUntil: now.Add(s.lease),
+	})
+	if err != nil {
+		return IdempotencyTicket{}, err
+	}
+	if reserved {
+		return IdempotencyTicket{id: rec.ID}, nil
+	}
+
+	if rec.RequestHash != fingerprint {
+		return IdempotencyTicket{}, ErrIdempotencyMismatch{Msg: "Idempotency-Key sudah dipakai untuk request yang berbeda"}
+	}
+	if rec.Status != repositories.IdempotencyCompleted || !rec.ResponseCode.Valid {
+		return IdempotencyTicket{}, ErrConflict{Msg: "request dengan Idempotency-Key yang sama masih diproses"}
+	}
+	return IdempotencyTicket{
+		id:     rec.ID,
+		Replay: &StoredResponse{Code: int(rec.ResponseCode.Int64), Body: rec.ResponseBody},
+	}, nil
+}
+
+// Finish menyimpan respons pertama. Respons 5xx tidak disimpan agar klien boleh mencoba lagi.
+func (s *IdempotencyService) Finish(ctx context.Context, t IdempotencyTicket, res StoredResponse) error {
+	if res.Code >= 500 {
+		return s.repo.Release(ctx, t.id)
+	}
+	return s.repo.Complete(ctx, t.id, res.Code, res.Body)
+}
+
+// Abort melepas key yang gagal diproses sebelum ada respons.
+func (s *IdempotencyService) Abort(ctx context.Context, t IdempotencyTicket) error {
+	return s.repo.Release(ctx, t.id)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// LedgerService mencatat setiap perpindahan uang sebagai jurnal double-entry.
+// Saldo di wallet_summary tidak pernah diubah langsung, melainkan diturunkan
+// dari saldo akun ledger milik user setelah jurnal diposting.
+type LedgerService struct {
+	repo repositories.LedgerRepo
+}
+
+func NewLedgerService(r repositories.LedgerRepo) *LedgerService {
+	return &LedgerService{repo: r}
+}
+
+// LedgerPosting adalah satu kaki jurnal. UserID kosong berarti akun sistem.
+type LedgerPosting struct {
+	UserID string
+	Kind   string
+	Side   string
+	Amount money.Amount
+}
+
+type JournalEntry struct {
+	EntryType     string
+	TransactionID *string
+	ReferensiID   *string
+	Deskripsi     string
+	Postings      []LedgerPosting
+	CreatedAt     time.Time
+}
+
+func ledgerDebit(userID, kind string, amount money.Amount) LedgerPosting {
+	return LedgerPosting{UserID: userID, Kind: kind, Side: repositories.LedgerDebit, Amount: amount}
+}
+
+func ledgerCredit(userID, kind string, amount money.Amount) LedgerPosting {
+	return LedgerPosting{UserID: userID, Kind: kind, Side: repositories.LedgerCredit, Amount: amount}
+}
+
+// Post menulis jurnal seimbang di dalam tx milik pemanggil dan mengembalikan ID
+// jurnalnya. Wallet user yang tersentuh dikunci dulu (urut user_id) supaya urutan
+// lock selalu sama, lalu saldo akun user diperbarui dan wallet_summary diturunkan ulang.
+func (l *LedgerService) Post(ctx context.Context, tx repositories.DBTX, e JournalEntry) (string, error) {
+	if len(e.Postings) < 2 {
+		return "", fmt.Errorf("jurnal %s minimal memiliki dua posting", e.EntryType)
+	}
+
+	var debit, credit money.Amount
+	userSet := make(map[string]struct{})
+	for _, p := range e.Postings {
+		if !p.Amount.IsPositive() {
+			return "", fmt.Errorf("jurnal %s: nominal posting harus positif", e.EntryType)
+		}
+		switch p.Side {
+		case repositories.LedgerDebit:
+			debit += p.Amount
+		case repositories.LedgerCredit:
+			credit += p.Amount
+		default:
+			return "", fmt.Errorf("jurnal %s: sisi posting %q tidak dikenal", e.EntryType, p.Side)
+		}
+		if p.UserID != "" {
+			userSet[p.UserID] = struct{}{}
+		}
+	}
+	if debit != credit {
+		return "", fmt.Errorf("jurnal %s tidak seimbang: debit=%s kredit=%s", e.EntryType, debit, credit)
+	}
+
+	users := make([]string, 0, len(userSet))
+	for id := range userSet {
+		users = append(users, id)
+	}
+	sort.Strings(users)
+	for _, id := range users {
+		if err := l.repo.LockWallet(ctx, tx, id); err != nil {
+			return "", err
+		}
+	}
+
+	entryID, err := l.repo.CreateEntry(ctx, tx, repositories.CreateLedgerEntryParams{
+		EntryType:     e.EntryType,
+		TransactionID: e.TransactionID,
+		ReferensiID:   e.ReferensiID,
+		Deskripsi:     e.Deskripsi,
+		CreatedAt:     e.CreatedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range e.Postings {
+		var owner *string
+		if p.UserID != "" {
+			id := p.UserID
+			owner = &id
+		}
+		acc, err := l.repo.GetOrCreateAccount(ctx, tx, owner, p.Kind)
+		if err != nil {
+			return "", err
+		}
+		if err := l.repo.CreatePosting(ctx, tx, repositories.CreateLedgerPostingParams{
+			EntryID:   entryID,
+			AccountID: acc.ID,
+			Side:      p.Side,
+			Amount:    p.Amount,
+			CreatedAt: e.CreatedAt,
+		}); err != nil {
+			return "", err
+		}
+
+		// saldo akun sistem dihitung dari posting, hanya akun user yang punya saldo berjalan
+		if owner == nil {
+			continue
+		}
+		delta := p.Amount
+		if p.Side != acc.NormalSide {
+			delta = -delta
+		}
+		balance, err := l.repo.ApplyToBalance(ctx, tx, acc.ID, delta)
+		if err != nil {
+			return "", err
+		}
+		if balance.IsNegative() {
+			return "", ErrInsufficientBalance{Msg: "saldo tidak mencukupi"}
+		}
+	}
+
+	for _, id := range users {
+		if err := l.repo.SyncWalletSummary(ctx, tx, id, e.CreatedAt); err != nil {
+			return "", err
+		}
+	}
+	return entryID, nil
+}
+
+// Rebuild menghitung ulang saldo berjalan akun user dari posting lalu
+// menurunkan ulang wallet_summary. Pemanggil wajib sudah mengunci wallet.
+func (l *LedgerService) Rebuild(ctx context.Context, tx repositories.DBTX, userID string, now time.Time) error {
+	if err := l.repo.RebuildUserBalances(ctx, tx, userID); err != nil {
+		return err
+	}
+	return l.repo.SyncWalletSummary(ctx, tx, userID, now)
+}
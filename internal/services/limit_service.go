@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Operasi yang dibatasi per tier (kolom limit_rules.operation).
+const (
+	LimitOpTopUp    = "TOP_UP"
+	LimitOpWithdraw = "WITHDRAW"
+)
+
+// Jendela limit bergulir: "harian" = 24 jam terakhir, "bulanan" = 30 hari terakhir.
+const (
+	limitDayWindow   = 24 * time.Hour
+	limitMonthWindow = 30 * 24 * time.Hour
+)
+
+// LimitService menegakkan limit top up dan tarik saldo berdasarkan tier user.
+// Pemakaian dijumlahkan dalam jendela bergulir yang berakhir pada saat transaksi;
+// pemakaian tepat 24 jam (atau 30 hari) sebelumnya sudah tidak dihitung.
+type LimitService struct {
+	repo repositories.LimitRepo
+}
+
+func NewLimitService(r repositories.LimitRepo) *LimitService {
+	return &LimitService{repo: r}
+}
+
+// limitWindows mengembalikan batas bawah (eksklusif) jendela harian dan bulanan.
+func limitWindows(at time.Time) (daySince, monthSince time.Time) {
+	return at.Add(-limitDayWindow), at.Add(-limitMonthWindow)
+}
+
+func limitOpName(op string) string {
+	if op == LimitOpWithdraw {
+		return "tarik saldo"
+	}
+	return "top up"
+}
+
+// rule mengambil limit tier user. Tier tanpa aturan dianggap tidak dibatasi.
+func (l *LimitService) rule(ctx context.Context, userID, op string) (repositories.LimitRuleRecord, error) {
+	tier, err := l.repo.GetUserTier(ctx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return repositories.LimitRuleRecord{}, ErrNotFoundResource{Msg: "user tidak ditemukan"}
+		}
+		return repositories.LimitRuleRecord{}, err
+	}
+	rule, err := l.repo.GetRule(ctx, tier, op)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return repositories.LimitRuleRecord{Tier: tier, Operation: op}, nil
+		}
+		return repositories.LimitRuleRecord{}, err
+	}
+	return rule, nil
+}
+
+func checkLimit(rule repositories.LimitRuleRecord, amount, daily, monthly money.Amount) error {
+	name := limitOpName(rule.Operation)
+	if !rule.PerTxnMax.IsZero() && amount.Cmp(rule.PerTxnMax) > 0 {
+		return ErrLimitExceeded{Msg: fmt.Sprintf("maksimal %s Rp%s per transaksi untuk tier %s", name, rule.PerTxnMax, rule.Tier)}
+	}
+	if !rule.DailyMax.IsZero() && daily.Cmp(rule.DailyMax) > 0 {
+		return ErrLimitExceeded{Msg: fmt.Sprintf("limit %s harian Rp%s untuk tier %s terlampaui", name, rule.DailyMax, rule.Tier)}
+	}
+	if !rule.MonthlyMax.IsZero() && monthly.Cmp(rule.MonthlyMax) > 0 {
+		return ErrLimitExceeded{Msg: fmt.Sprintf("limit %s bulanan Rp%s untuk tier %s terlampaui", name, rule.MonthlyMax, rule.Tier)}
+	}
+	return nil
+}
+
+// Check memastikan amount masih muat di limit tanpa mencatat pemakaian.
+func (l *LimitService) Check(ctx context.Context, userID, op string, amount money.Amount, at time.Time) error {
+	rule, err := l.rule(ctx, userID, op)
+	if err != nil {
+		return err
+	}
+	daySince, monthSince := limitWindows(at)
+	daily, monthly, err := l.repo.GetUsage(ctx, userID, op, daySince, monthSince)
+	if err != nil {
+		return err
+	}
+	return checkLimit(rule, amount, daily.Add(amount), monthly.Add(amount))
+}
+
+// CheckTx sama dengan Check di dalam transaksi. Caller memegang kunci wallet user,
+// jadi request paralel tidak bisa bersama-sama lolos sebelum pemakaiannya tercatat
+// (mis. order top up PENDING yang dibuat di transaksi yang sama).
+func (l *LimitService) CheckTx(ctx context.Context, tx repositories.DBTX, userID, op string, amount money.Amount, at time.Time) error {
+	rule, err := l.rule(ctx, userID, op)
+	if err != nil {
+		return err
+	}
+	daySince, monthSince := limitWindows(at)
+	daily, monthly, err := l.repo.GetUsageTx(ctx, tx, userID, op, daySince, monthSince)
+	if err != nil {
+		return err
+	}
+	return checkLimit(rule, amount, daily.Add(amount), monthly.Add(amount))
+}
+
+// Consume memeriksa limit lalu mencatat pemakaian atas reference. Caller memegang
+// kunci wallet user seperti pada CheckTx.
+func (l *LimitService) Consume(ctx context.Context, tx repositories.DBTX, userID, op, reference string, amount money.Amount, at time.Time) error {
+	if err := l.CheckTx(ctx, tx, userID, op, amount, at); err != nil {
+		return err
+	}
+	return l.Record(ctx, tx, userID, op, reference, amount, at)
+}
+
+// Record mencatat pemakaian tanpa menolak, untuk dana yang sudah terlanjur diterima
+// (mis. settlement top up yang limitnya sudah dicek saat order dibuat). Reference
+// yang sudah tercatat diabaikan.
+func (l *LimitService) Record(ctx context.Context, tx repositories.DBTX, userID, op, reference string, amount money.Amount, at time.Time) error {
+	_, err := l.repo.AddUsage(ctx, tx, repositories.LimitUsageEntry{
+		UserID:     userID,
+		Operation:  op,
+		Reference:  reference,
+		Amount:     amount,
+		OccurredAt: at,
+	})
+	return err
+}
+
+// Release melepas pemakaian reference (payout gagal, top up di-refund) sehingga
+// tidak lagi dihitung ke limit.
+func (l *LimitService) Release(ctx context.Context, tx repositories.DBTX, userID, op, reference string, at time.Time) error {
+	return l.repo.SetUsageReleased(ctx, tx, userID, op, reference, &at)
+}
+
+// Restore menghitung kembali pemakaian yang sebelumnya dilepas, mis. saat refund
+// top up dibatalkan.
+func (l *LimitService) Restore(ctx context.Context, tx repositories.DBTX, userID, op, reference string) error {
+	return l.repo.SetUsageReleased(ctx, tx, userID, op, reference, nil)
+}
+
+type LimitUsageDTO struct {
+	Operation        string        `json:"operation"`
+	PerTxnMax        money.Amount  `json:"perTransactionMax,omitempty"`
+	DailyMax         money.Amount  `json:"dailyMax,omitempty"`
+	DailyUsed        money.Amount  `json:"dailyUsed"`
+	DailyRemaining   *money.Amount `json:"dailyRemaining,omitempty"`
+	MonthlyMax       money.Amount  `json:"monthlyMax,omitempty"`
+	MonthlyUsed      money.Amount  `json:"monthlyUsed"`
+	MonthlyRemaining *money.Amount `json:"monthlyRemaining,omitempty"`
+}
+
+type LimitsDTO struct {
+	Tier   string          `json:"tier"`
+	Limits []LimitUsageDTO `json:"limits"`
+}
+
+func remaining(max, used money.Amount) *money.Amount {
+	if max.IsZero() {
+		return nil
+	}
+	left := max.Sub(used)
+	if left.IsNegative() {
+		left = 0
+	}
+	return &left
+}
+
+// Usage menampilkan limit tier user beserta pemakaian dalam jendela 24 jam dan 30 hari
+// yang berakhir pada at.
+func (l *LimitService) Usage(ctx context.Context, userID string, at time.Time) (LimitsDTO, error) {
+	out := LimitsDTO{Limits: make([]LimitUsageDTO, 0, 2)}
+	for _, op := range []string{LimitOpTopUp, LimitOpWithdraw} {
+		rule, err := l.rule(ctx, userID, op)
+		if err != nil {
+			return LimitsDTO{}, err
+		}
+		daySince, monthSince := limitWindows(at)
+		daily, monthly, err := l.repo.GetUsage(ctx, userID, op, daySince, monthSince)
+		if err != nil {
+			return LimitsDTO{}, err
+		}
+		out.Tier = rule.Tier
+		out.Limits = append(out.Limits, LimitUsageDTO{
+			Operation:        op,
+			PerTxnMax:        rule.PerTxnMax,
+			DailyMax:         rule.DailyMax,
+			DailyUsed:        daily,
+			DailyRemaining:   remaining(rule.DailyMax, daily),
+			MonthlyMax:       rule.MonthlyMax,
+			MonthlyUsed:      monthly,
+			MonthlyRemaining: remaining(rule.MonthlyMax, monthly),
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// fakeLimitRepo menyimpan entri di memori dengan aturan jendela yang sama seperti
+// query: occurred_at harus setelah batas bawah, entri yang dilepas tidak dihitung.
+type fakeLimitRepo struct {
+	rule     repositories.LimitRuleRecord
+	entries  []repositories.LimitUsageEntry
+	released map[string]bool
+}
+
+func (f *fakeLimitRepo) GetUserTier(ctx context.Context, userID string) (string, error) {
+	return f.rule.Tier, nil
+}
+
+func (f *fakeLimitRepo) GetRule(ctx context.Context, tier, operation string) (repositories.LimitRuleRecord, error) {
+	rule := f.rule
+	rule.Operation = operation
+	return rule, nil
+}
+
+func (f *fakeLimitRepo) GetUsage(ctx context.Context, userID, operation string, daySince, monthSince time.Time) (money.Amount, money.Amount, error) {
+	var daily, monthly money.Amount
+	for _, e := range f.entries {
+		if e.UserID != userID || e.Operation != operation || f.released[e.Reference] {
+			continue
+		}
+		if e.OccurredAt.After(daySince) {
+			daily = daily.Add(e.Amount)
+		}
+		if e.OccurredAt.After(monthSince) {
+			monthly = monthly.Add(e.Amount)
+		}
+	}
+	return daily, monthly, nil
+}
+
+func (f *fakeLimitRepo) GetUsageTx(ctx context.Context, tx repositories.DBTX, userID, operation string, daySince, monthSince time.Time) (money.Amount, money.Amount, error) {
+	return f.GetUsage(ctx, userID, operation, daySince, monthSince)
+}
+
+func (f *fakeLimitRepo) AddUsage(ctx context.Context, tx repositories.DBTX, e repositories.LimitUsageEntry) (bool, error) {
+	for _, cur := range f.entries {
+		if cur.UserID == e.UserID && cur.Operation == e.Operation && cur.Reference == e.Reference {
+			return false, nil
+		}
+	}
+	f.entries = append(f.entries, e)
+	return true, nil
+}
+
+func (f *fakeLimitRepo) SetUsageReleased(ctx context.Context, tx repositories.DBTX, userID, operation, reference string, releasedAt *time.Time) error {
+	f.released[reference] = releasedAt != nil
+	return nil
+}
+
+const limitTestUser = "7d0b5d9e-7f0c-4a4e-9c55-1a2b3c4d5e6f"
+
+func newTestLimitService(daily, monthly money.Amount) (*LimitService, *fakeLimitRepo) {
+	repo := &fakeLimitRepo{
+		rule:     repositories.LimitRuleRecord{Tier: "BASIC", DailyMax: daily, MonthlyMax: monthly},
+		released: map[string]bool{},
+	}
+	return NewLimitService(repo), repo
+}
+
+func TestLimitDailyWindowBoundary(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 3, 10, 23, 30, 0, 0, wib)
+	tests := []struct {
+		name    string
+		at      time.Time
+		wantErr bool
+	}{
+		{"satu menit kemudian", t0.Add(time.Minute), true},
+		{"lewat tengah malam WIB", t0.Add(time.Hour), true},
+		{"1 ns sebelum 24 jam", t0.Add(limitDayWindow - time.Nanosecond), true},
+		{"tepat 24 jam", t0.Add(limitDayWindow), false},
+		{"lebih dari 24 jam", t0.Add(limitDayWindow + time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestLimitService(money.FromRupiah(1_000_000), 0)
+			if err := svc.Record(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-1", money.FromRupiah(800_000), t0); err != nil {
+				t.Fatal(err)
+			}
+			err := svc.Check(ctx, limitTestUser, LimitOpWithdraw, money.FromRupiah(300_000), tt.at)
+			if got := err != nil; got != tt.wantErr {
+				t.Fatalf("Check error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var exceeded ErrLimitExceeded
+			if err != nil && !errors.As(err, &exceeded) {
+				t.Fatalf("error = %T, want ErrLimitExceeded", err)
+			}
+		})
+	}
+}
+
+func TestLimitMonthlyWindowBoundary(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 1, 31, 12, 0, 0, 0, wib)
+	tests := []struct {
+		name    string
+		at      time.Time
+		wantErr bool
+	}{
+		{"hari berikutnya, ganti bulan kalender", t0.Add(limitDayWindow), true},
+		{"1 ns sebelum 30 hari", t0.Add(limitMonthWindow - time.Nanosecond), true},
+		{"tepat 30 hari", t0.Add(limitMonthWindow), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestLimitService(0, money.FromRupiah(5_000_000))
+			if err := svc.Record(ctx, nil, limitTestUser, LimitOpTopUp, "TOPUP-1", money.FromRupiah(4_500_000), t0); err != nil {
+				t.Fatal(err)
+			}
+			err := svc.Check(ctx, limitTestUser, LimitOpTopUp, money.FromRupiah(1_000_000), tt.at)
+			if got := err != nil; got != tt.wantErr {
+				t.Fatalf("Check error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimitConsumeReleaseRestore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, wib)
+	svc, repo := newTestLimitService(money.FromRupiah(1_000_000), 0)
+
+	if err := svc.Consume(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-1", money.FromRupiah(700_000), now); err != nil {
+		t.Fatalf("Consume pertama: %v", err)
+	}
+	// reference yang sama tidak dihitung dua kali
+	if err := svc.Record(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-1", money.FromRupiah(700_000), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.entries) != 1 {
+		t.Fatalf("entri = %d, want 1", len(repo.entries))
+	}
+
+	err := svc.Consume(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-2", money.FromRupiah(400_000), now.Add(time.Minute))
+	var exceeded ErrLimitExceeded
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("Consume kedua error = %v, want ErrLimitExceeded", err)
+	}
+	if len(repo.entries) != 1 {
+		t.Fatalf("Consume yang ditolak tetap mencatat entri")
+	}
+
+	// payout WD-1 gagal: pemakaiannya dilepas
+	if err := svc.Release(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-1", now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Check(ctx, limitTestUser, LimitOpWithdraw, money.FromRupiah(1_000_000), now.Add(3*time.Minute)); err != nil {
+		t.Fatalf("Check setelah Release: %v", err)
+	}
+
+	if err := svc.Restore(ctx, nil, limitTestUser, LimitOpWithdraw, "WD-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Check(ctx, limitTestUser, LimitOpWithdraw, money.FromRupiah(400_000), now.Add(4*time.Minute)); !errors.As(err, &exceeded) {
+		t.Fatalf("Check setelah Restore error = %v, want ErrLimitExceeded", err)
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// LotService mencatat lot saldo redeem dan ev_poin beserta tanggal kedaluwarsanya.
+// Semua method dipanggil di dalam transaksi yang sudah mengunci wallet_summary user.
+type LotService struct {
+	repo repositories.LotRepo
+	cfg  LotConfig
+}
+
+type LotConfig struct {
+	RedeemTTL time.Duration // masa berlaku saldo redeem promosi; nol = tidak kedaluwarsa
+	PointsTTL time.Duration // masa berlaku ev_poin; nol = tidak kedaluwarsa
+}
+
+func NewLotService(r repositories.LotRepo, cfg LotConfig) *LotService {
+	return &LotService{repo: r, cfg: cfg}
+}
+
+// LotCredit adalah penambahan saldo yang membuka lot baru.
+type LotCredit struct {
+	UserID     string
+	Kind       string
+	Quantity   money.Amount
+	SourceType string
+	SourceID   string
+	CreatedAt  time.Time
+}
+
+// ConsumedLot adalah bagian lot yang terpakai oleh satu pengeluaran.
+type ConsumedLot struct {
+	Quantity  money.Amount
+	ExpiresAt *time.Time
+}
+
+func pointsQuantity(points int) money.Amount { return money.FromRupiah(int64(points)) }
+
+func (l *LotService) ttl(kind string) time.Duration {
+	if kind == repositories.LotKindPoints {
+		return l.cfg.PointsTTL
+	}
+	return l.cfg.RedeemTTL
+}
+
+// Credit membuka lot baru dengan masa berlaku sesuai konfigurasi jenis lot.
+func (l *LotService) Credit(ctx context.Context, tx repositories.DBTX, c LotCredit) error {
+	if !c.Quantity.IsPositive() {
+		return nil
+	}
+	var expiresAt *time.Time
+	if ttl := l.ttl(c.Kind); ttl > 0 {
+		t := c.CreatedAt.Add(ttl)
+		expiresAt = &t
+	}
+	return l.repo.CreateLot(ctx, tx, repositories.CreateLotParams{
+		UserID:     c.UserID,
+		Kind:       c.Kind,
+		SourceType: c.SourceType,
+		SourceID:   c.SourceID,
+		Quantity:   c.Quantity,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  c.CreatedAt,
+	})
+}
+
+// CreditMirrored membuka lot untuk penerima dengan tanggal kedaluwarsa yang sama
+// seperti lot pengirim, supaya transfer tidak memperpanjang masa berlaku saldo.
+func (l *LotService) CreditMirrored(ctx context.Context, tx repositories.DBTX, c LotCredit, consumed []ConsumedLot) error {
+	var mirrored money.Amount
+	for _, part := range consumed {
+		if err := l.repo.CreateLot(ctx, tx, repositories.CreateLotParams{
+			UserID:     c.UserID,
+			Kind:       c.Kind,
+			SourceType: c.SourceType,
+			SourceID:   c.SourceID,
+			Quantity:   part.Quantity,
+			ExpiresAt:  part.ExpiresAt,
+			CreatedAt:  c.CreatedAt,
+		}); err != nil {
+			return err
+		}
+		mirrored = mirrored.Add(part.Quantity)
+	}
+	// saldo pengirim yang tidak tercatat di lot manapun dianggap tanpa kedaluwarsa
+	if rest := c.Quantity.Sub(mirrored); rest.IsPositive() {
+		return l.repo.CreateLot(ctx, tx, repositories.CreateLotParams{
+			UserID:     c.UserID,
+			Kind:       c.Kind,
+			SourceType: c.SourceType,
+			SourceID:   c.SourceID,
+			Quantity:   rest,
+			CreatedAt:  c.CreatedAt,
+		})
+	}
+	return nil
+}
+
+// Consume memakai lot user secara FIFO (yang paling cepat kedaluwarsa lebih dulu).
+// Bila lot tidak cukup (saldo lama di luar lot), sisanya diabaikan.
+func (l *LotService) Consume(ctx context.Context, tx repositories.DBTX, userID, kind string, quantity money.Amount) ([]ConsumedLot, error) {
+	lots, err := l.repo.ListOpenLotsForUpdate(ctx, tx, userID, kind)
+	if err != nil {
+		return nil, err
+	}
+	var consumed []ConsumedLot
+	need := quantity
+	for _, lot := range lots {
+		if !need.IsPositive() {
+			break
+		}
+		take := lot.Remaining
+		if take.Cmp(need) > 0 {
+			take = need
+		}
+		if err := l.repo.SetRemaining(ctx, tx, lot.ID, lot.Remaining.Sub(take), nil); err != nil {
+			return nil, err
+		}
+		part := ConsumedLot{Quantity: take}
+		if lot.ExpiresAt.Valid {
+			t := lot.ExpiresAt.Time
+			part.ExpiresAt = &t
+		}
+		consumed = append(consumed, part)
+		need = need.Sub(take)
+	}
+	return consumed, nil
+}
+
+type ExpiringLotDTO struct {
+	Amount    money.Amount `json:"amount"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	Source    string       `json:"source"`
+}
+
+type ExpiringPointsDTO struct {
+	Poin      int       `json:"poin"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Source    string    `json:"source"`
+}
+
+type ExpiringSoonDTO struct {
+	Until       time.Time           `json:"until"`
+	RedeemTotal money.Amount        `json:"saldoRedeemTotal"`
+	PointsTotal int                 `json:"poinTotal"`
+	Redeem      []ExpiringLotDTO    `json:"saldoRedeem"`
+	Points      []ExpiringPointsDTO `json:"poin"`
+}
+
+func (l *LotService) ExpiringSoon(ctx context.Context, userID string, until time.Time) (ExpiringSoonDTO, error) {
+	lots, err := l.repo.ListExpiring(ctx, userID, until)
+	if err != nil {
+		return ExpiringSoonDTO{}, err
+	}
+	out := ExpiringSoonDTO{
+		Until:  until,
+		Redeem: make([]ExpiringLotDTO, 0),
+		Points: make([]ExpiringPointsDTO, 0),
+	}
+	for _, lot := range lots {
+		switch lot.Kind {
+		case repositories.LotKindRedeem:
+			out.RedeemTotal = out.RedeemTotal.Add(lot.Remaining)
+			out.Redeem = append(out.Redeem, ExpiringLotDTO{Amount: lot.Remaining, ExpiresAt: lot.ExpiresAt.Time, Source: lot.SourceType})
+		case repositories.LotKindPoints:
+			poin := int(lot.Remaining.Rupiah())
+			out.PointsTotal += poin
+			out.Points = append(out.Points, ExpiringPointsDTO{Poin: poin, ExpiresAt: lot.ExpiresAt.Time, Source: lot.SourceType})
+		}
+	}
+	return out, nil
+}
@@ -5,13 +5,20 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"crypto/sha512"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
 )
 
 // SnapClient dipakai untuk membuat transaksi Midtrans Snap.
@@ -26,11 +33,43 @@ type SnapRequest struct {
 	CustomerDetails    *SnapCustomerDetails   `json:"customer_details,omitempty"`
 	ItemDetails        []SnapItemDetail       `json:"item_details,omitempty"`
 	EnabledPayments    []string               `json:"enabled_payments,omitempty"`
+	Expiry             *SnapExpiry            `json:"expiry,omitempty"`
+}
+
+// SnapExpiry membatasi berapa lama transaksi Snap bisa dibayar sejak StartTime.
+type SnapExpiry struct {
+	StartTime string `json:"start_time,omitempty"` // format "2006-01-02 15:04:05 -0700"
+	Unit      string `json:"unit"`                 // minute, hour, atau day
+	Duration  int    `json:"duration"`
+}
+
+const snapExpiryTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// NewSnapExpiry membulatkan d ke atas dalam menit, satuan terkecil yang diterima Snap.
+func NewSnapExpiry(start time.Time, d time.Duration) *SnapExpiry {
+	return &SnapExpiry{
+		StartTime: start.Format(snapExpiryTimeLayout),
+		Unit:      "minute",
+		Duration:  expiryMinutes(d),
+	}
+}
+
+// NewMidtransCustomExpiry adalah padanan NewSnapExpiry untuk charge Core API.
+func NewMidtransCustomExpiry(start time.Time, d time.Duration) *MidtransCustomExpiry {
+	return &MidtransCustomExpiry{
+		OrderTime:      start.Format(snapExpiryTimeLayout),
+		ExpiryDuration: expiryMinutes(d),
+		Unit:           "minute",
+	}
+}
+
+func expiryMinutes(d time.Duration) int {
+	return int((d + time.Minute - 1) / time.Minute)
 }
 
 type SnapTransactionDetails struct {
-	OrderID     string  `json:"order_id"`
-	GrossAmount float64 `json:"gross_amount"`
+	OrderID     string       `json:"order_id"`
+	GrossAmount money.Amount `json:"gross_amount"`
 }
 
 type SnapCustomerDetails struct {
@@ -40,10 +79,10 @@ type SnapCustomerDetails struct {
 }
 
 type SnapItemDetail struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Price    money.Amount `json:"price"`
+	Quantity int          `json:"quantity"`
 }
 
 type SnapResponse struct {
@@ -110,6 +149,225 @@ type NotificationPayload struct {
 	SettlementTime    string `json:"settlement_time"`
 }
 
+// MidtransCoreClient memanggil Core API Midtrans: charge langsung (VA, QRIS, GoPay)
+// serta status, pembatalan dan refund transaksi, termasuk yang dibuat lewat Snap.
+type MidtransCoreClient struct {
+	ServerKey string
+	BaseURL   string
+	Client    *http.Client
+}
+
+func NewMidtransCoreClient(serverKey, baseURL string) *MidtransCoreClient {
+	if baseURL == "" {
+		baseURL = "https://api.sandbox.midtrans.com/v2"
+	}
+	return &MidtransCoreClient{
+		ServerKey: serverKey,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		Client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// MidtransError adalah penolakan dari Core API. StatusCode diambil dari status_code
+// di body bila ada, karena Midtrans sering membalas HTTP 200 untuk request yang gagal.
+type MidtransError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *MidtransError) Error() string {
+	return fmt.Sprintf("midtrans error: status=%d message=%s", e.StatusCode, e.Message)
+}
+
+// Permanent bernilai true bila Midtrans menolak isi request (mis. transaksi tidak
+// bisa direfund), sehingga mengirim ulang request yang sama tidak akan berhasil.
+func (e *MidtransError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// GetStatus mengembalikan status order dalam bentuk yang sama dengan notifikasi,
+// termasuk signature_key, sehingga bisa diproses lewat jalur yang sama.
+func (c *MidtransCoreClient) GetStatus(ctx context.Context, orderID string) (NotificationPayload, error) {
+	var res NotificationPayload
+	err := c.transactionCall(ctx, http.MethodGet, orderID, "status", nil, &res)
+	return res, err
+}
+
+// Cancel membatalkan transaksi yang belum dibayar. Respons pembatalan tidak membawa
+// signature_key, jadi hasilnya hanya dipakai untuk transaction_id.
+func (c *MidtransCoreClient) Cancel(ctx context.Context, orderID string) (NotificationPayload, error) {
+	var res NotificationPayload
+	err := c.transactionCall(ctx, http.MethodPost, orderID, "cancel", nil, &res)
+	var mErr *MidtransError
+	if errors.As(err, &mErr) && mErr.StatusCode == http.StatusPreconditionFailed {
+		return NotificationPayload{}, ErrPaymentNotCancellable
+	}
+	return res, err
+}
+
+type MidtransRefundRequest struct {
+	RefundKey string       `json:"refund_key"`
+	Amount    money.Amount `json:"amount"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+type MidtransRefundResponse struct {
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	RefundKey         string `json:"refund_key"`
+	RefundAmount      string `json:"refund_amount"`
+}
+
+// Refund mengembalikan dana transaksi yang sudah settlement ke pembayar. refund_key
+// membuat request ini aman diulang: Midtrans tidak memproses key yang sama dua kali.
+func (c *MidtransCoreClient) Refund(ctx context.Context, orderID string, req MidtransRefundRequest) (MidtransRefundResponse, error) {
+	var res MidtransRefundResponse
+	err := c.transactionCall(ctx, http.MethodPost, orderID, "refund", req, &res)
+	return res, err
+}
+
+// MidtransChargeRequest adalah body POST /charge. Hanya satu blok channel yang diisi,
+// sesuai PaymentType.
+type MidtransChargeRequest struct {
+	PaymentType        string                 `json:"payment_type"`
+	TransactionDetails SnapTransactionDetails `json:"transaction_details"`
+	CustomerDetails    *SnapCustomerDetails   `json:"customer_details,omitempty"`
+	BankTransfer       *MidtransBankTransfer  `json:"bank_transfer,omitempty"`
+	EChannel           *MidtransEChannel      `json:"echannel,omitempty"`
+	QRIS               *MidtransQRIS          `json:"qris,omitempty"`
+	GoPay              *MidtransGoPay         `json:"gopay,omitempty"`
+	CustomExpiry       *MidtransCustomExpiry  `json:"custom_expiry,omitempty"`
+}
+
+type MidtransBankTransfer struct {
+	Bank string `json:"bank"`
+}
+
+// MidtransEChannel adalah virtual account Mandiri (Mandiri Bill Payment).
+type MidtransEChannel struct {
+	BillInfo1 string `json:"bill_info1"`
+	BillInfo2 string `json:"bill_info2"`
+}
+
+type MidtransQRIS struct {
+	Acquirer string `json:"acquirer,omitempty"`
+}
+
+type MidtransGoPay struct {
+	EnableCallback bool   `json:"enable_callback,omitempty"`
+	CallbackURL    string `json:"callback_url,omitempty"`
+}
+
+type MidtransCustomExpiry struct {
+	OrderTime      string `json:"order_time"` // format "2006-01-02 15:04:05 -0700"
+	ExpiryDuration int    `json:"expiry_duration"`
+	Unit           string `json:"unit"`
+}
+
+type MidtransChargeResponse struct {
+	TransactionID     string `json:"transaction_id"`
+	OrderID           string `json:"order_id"`
+	PaymentType       string `json:"payment_type"`
+	TransactionStatus string `json:"transaction_status"`
+	VANumbers         []struct {
+		Bank     string `json:"bank"`
+		VANumber string `json:"va_number"`
+	} `json:"va_numbers"`
+	PermataVANumber string `json:"permata_va_number"`
+	BillKey         string `json:"bill_key"`
+	BillerCode      string `json:"biller_code"`
+	QRString        string `json:"qr_string"`
+	Actions         []struct {
+		Name   string `json:"name"`
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"actions"`
+}
+
+// ActionURL mengembalikan url action dengan nama tersebut (mis. generate-qr-code).
+func (r MidtransChargeResponse) ActionURL(name string) string {
+	for _, a := range r.Actions {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// Charge membuat transaksi Core API. Status awalnya pending; settlement tetap datang
+// lewat notifikasi yang sama dengan Snap.
+func (c *MidtransCoreClient) Charge(ctx context.Context, req MidtransChargeRequest) (MidtransChargeResponse, error) {
+	var res MidtransChargeResponse
+	err := c.call(ctx, http.MethodPost, "/charge", req, &res)
+	return res, err
+}
+
+func (c *MidtransCoreClient) transactionCall(ctx context.Context, method, orderID, action string, body, out any) error {
+	return c.call(ctx, method, "/"+url.PathEscape(orderID)+"/"+action, body, out)
+}
+
+func (c *MidtransCoreClient) call(ctx context.Context, method, path string, body, out any) error {
+	if c == nil {
+		return fmt.Errorf("midtrans core client is nil")
+	}
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.SetBasicAuth(c.ServerKey, "")
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		StatusCode    string `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+	}
+	decodeErr := json.Unmarshal(raw, &envelope)
+	code, _ := strconv.Atoi(envelope.StatusCode)
+	// Midtrans bisa membalas HTTP 200 dengan status_code 404 di body
+	if resp.StatusCode == http.StatusNotFound || code == http.StatusNotFound {
+		return ErrPaymentNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return &MidtransError{StatusCode: resp.StatusCode, Message: envelope.StatusMessage}
+	}
+	// status API memakai status_code 407 dsb. untuk transaksi yang valid (mis. expire),
+	// jadi status_code di body hanya dianggap error untuk request yang mengubah transaksi
+	if method != http.MethodGet && code >= 300 {
+		return &MidtransError{StatusCode: code, Message: envelope.StatusMessage}
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	return json.Unmarshal(raw, out)
+}
+
 func VerifyNotificationSignature(serverKey string, payload NotificationPayload) bool {
 	raw := payload.OrderID + payload.StatusCode + payload.GrossAmount + serverKey
 	expected := computeSHA512(raw)
@@ -137,19 +395,33 @@ type IrisClient struct {
 	BaseURL      string
 	ClientKey    string
 	ClientSecret string
-	Client       *http.Client
+	// ApproverKey adalah API key akun approver Iris; kosong = akun Iris tidak
+	// memakai langkah persetujuan.
+	ApproverKey string
+	Client      *http.Client
 }
 
 type IrisPayoutRequest struct {
-	Payouts []struct {
-		Amount             string `json:"amount"`
-		BeneficiaryName    string `json:"beneficiary_name"`
-		BeneficiaryAccount string `json:"beneficiary_account"`
-		BeneficiaryBank    string `json:"beneficiary_bank"`
-		BeneficiaryEmail   string `json:"beneficiary_email,omitempty"`
-		Notes              string `json:"notes,omitempty"`
-		PartnerTrxID       string `json:"partner_trx_id"`
-	} `json:"payouts"`
+	Payouts []IrisPayout `json:"payouts"`
+}
+
+type IrisPayout struct {
+	Amount             money.Amount `json:"-"`
+	BeneficiaryName    string       `json:"beneficiary_name"`
+	BeneficiaryAccount string       `json:"beneficiary_account"`
+	BeneficiaryBank    string       `json:"beneficiary_bank"`
+	BeneficiaryEmail   string       `json:"beneficiary_email,omitempty"`
+	Notes              string       `json:"notes,omitempty"`
+	PartnerTrxID       string       `json:"partner_trx_id"`
+}
+
+// MarshalJSON mengirim amount sebagai string rupiah bulat sesuai format Iris.
+func (p IrisPayout) MarshalJSON() ([]byte, error) {
+	type payout IrisPayout
+	return json.Marshal(struct {
+		Amount string `json:"amount"`
+		payout
+	}{Amount: p.Amount.RupiahString(), payout: payout(p)})
 }
 
 type IrisPayoutResponse struct {
@@ -158,6 +430,37 @@ type IrisPayoutResponse struct {
 	Status   string `json:"status"`
 }
 
+// IrisError adalah respons non-2xx dari Iris.
+type IrisError struct {
+	StatusCode int
+	Response   map[string]any
+}
+
+func (e *IrisError) Error() string {
+	return fmt.Sprintf("midtrans iris error: status=%d response=%v", e.StatusCode, e.Response)
+}
+
+// Permanent bernilai true bila Iris menolak isi request (mis. rekening tidak valid),
+// sehingga mengirim ulang request yang sama tidak akan berhasil.
+func (e *IrisError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// NotProcessed bernilai true bila Iris menolak request sebelum memproses isinya
+// (kredensial ditolak atau kena rate limit), sehingga aman dikirim ulang. 408 dan
+// 5xx tidak termasuk: payout-nya mungkin sudah dibuat.
+func (e *IrisError) NotProcessed() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
 func NewIrisClient(clientKey, clientSecret, baseURL string) *IrisClient {
 	if baseURL == "" {
 		baseURL = "https://app.sandbox.midtrans.com/iris/api/v1/payouts"
@@ -198,14 +501,15 @@ func (c *IrisClient) CreatePayout(ctx context.Context, req IrisPayoutRequest) (I
 	if resp.StatusCode >= 300 {
 		var payload map[string]any
 		_ = json.NewDecoder(resp.Body).Decode(&payload)
-		return IrisPayoutResponse{}, fmt.Errorf("midtrans iris error: status=%d response=%v", resp.StatusCode, payload)
+		return IrisPayoutResponse{}, &IrisError{StatusCode: resp.StatusCode, Response: payload}
 	}
 
 	var res struct {
-		Result   string `json:"result"`
-		Payouts  []struct {
-			ID     string `json:"payout_id"`
-			Status string `json:"status"`
+		Result  string `json:"result"`
+		Payouts []struct {
+			ID          string `json:"payout_id"`
+			ReferenceNo string `json:"reference_no"`
+			Status      string `json:"status"`
 		} `json:"payouts"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
@@ -215,8 +519,125 @@ func (c *IrisClient) CreatePayout(ctx context.Context, req IrisPayoutRequest) (I
 		Result: res.Result,
 	}
 	if len(res.Payouts) > 0 {
-		out.PayoutID = res.Payouts[0].ID
+		// Iris mengenali payout lewat reference_no, termasuk di notifikasinya
+		out.PayoutID = firstNonEmpty(res.Payouts[0].ReferenceNo, res.Payouts[0].ID)
 		out.Status = res.Payouts[0].Status
 	}
 	return out, nil
 }
+
+// IrisNotificationPayload adalah body notifikasi status payout dari Iris.
+type IrisNotificationPayload struct {
+	ReferenceNo  string `json:"reference_no"`
+	Amount       string `json:"amount"`
+	Status       string `json:"status"`
+	UpdatedAt    string `json:"updated_at"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// VerifyIrisSignature mencocokkan header Iris-Signature, yaitu SHA512 dari body
+// mentah notifikasi disambung merchant key.
+func VerifyIrisSignature(merchantKey string, body []byte, signature string) bool {
+	expected := computeSHA512(string(body) + merchantKey)
+	return expected == strings.ToLower(strings.TrimSpace(signature))
+}
+
+// irisPayoutStatus memetakan status Iris ke payout_request_status. ok=false bila
+// statusnya tidak dikenal.
+func irisPayoutStatus(status string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "queued", "approved", "processed":
+		return "REQUESTED", true
+	case "completed":
+		return "COMPLETED", true
+	case "failed", "rejected":
+		return "FAILED", true
+	}
+	return "", false
+}
+
+// IrisAccountValidation adalah hasil validasi rekening dari Iris.
+type IrisAccountValidation struct {
+	AccountName string `json:"account_name"`
+	AccountNo   string `json:"account_no"`
+	BankName    string `json:"bank_name"`
+}
+
+// ValidateAccount mengecek rekening ke bank lewat Iris dan mengembalikan nama
+// pemilik rekening yang terdaftar.
+func (c *IrisClient) ValidateAccount(ctx context.Context, bankCode, accountNumber string) (IrisAccountValidation, error) {
+	if c == nil {
+		return IrisAccountValidation{}, fmt.Errorf("iris client is nil")
+	}
+	// BaseURL menunjuk endpoint payouts; account_validation ada di level yang sama
+	base := strings.TrimSuffix(strings.TrimRight(c.BaseURL, "/"), "/payouts")
+	q := url.Values{"bank": {bankCode}, "account": {accountNumber}}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/account_validation?"+q.Encode(), nil)
+	if err != nil {
+		return IrisAccountValidation{}, err
+	}
+	httpReq.SetBasicAuth(c.ClientKey, c.ClientSecret)
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return IrisAccountValidation{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var payload map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		return IrisAccountValidation{}, &IrisError{StatusCode: resp.StatusCode, Response: payload}
+	}
+	var res IrisAccountValidation
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return IrisAccountValidation{}, err
+	}
+	return res, nil
+}
+
+// ApprovePayouts menyetujui payout berstatus queued dengan kredensial approver.
+func (c *IrisClient) ApprovePayouts(ctx context.Context, referenceNos []string, otp string) error {
+	return c.approverCall(ctx, "/approve", map[string]any{
+		"reference_nos": referenceNos,
+		"otp":           otp,
+	})
+}
+
+// RejectPayouts menolak payout berstatus queued dengan kredensial approver.
+func (c *IrisClient) RejectPayouts(ctx context.Context, referenceNos []string, reason string) error {
+	return c.approverCall(ctx, "/reject", map[string]any{
+		"reference_nos": referenceNos,
+		"reject_reason": reason,
+	})
+}
+
+func (c *IrisClient) approverCall(ctx context.Context, path string, payload map[string]any) error {
+	if c == nil || c.ApproverKey == "" {
+		return fmt.Errorf("iris approver belum dikonfigurasi")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.SetBasicAuth(c.ApproverKey, "")
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var res map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		return &IrisError{StatusCode: resp.StatusCode, Response: res}
+	}
+	return nil
+}
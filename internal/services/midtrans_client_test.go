@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestIrisErrorClassification(t *testing.T) {
+	tests := []struct {
+		status       int
+		permanent    bool
+		notProcessed bool
+	}{
+		{http.StatusBadRequest, true, false},
+		{http.StatusUnauthorized, false, true},
+		{http.StatusForbidden, false, true},
+		{http.StatusNotFound, true, false},
+		{http.StatusRequestTimeout, false, false},
+		{http.StatusConflict, true, false},
+		{http.StatusUnprocessableEntity, true, false},
+		{http.StatusTooManyRequests, false, true},
+		{http.StatusInternalServerError, false, false},
+		{http.StatusBadGateway, false, false},
+		{http.StatusServiceUnavailable, false, false},
+		{http.StatusGatewayTimeout, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("kirim payout: %w", &IrisError{StatusCode: tt.status})
+			if got := isPermanent(err); got != tt.permanent {
+				t.Errorf("isPermanent = %v, want %v", got, tt.permanent)
+			}
+			if got := isNotProcessed(err); got != tt.notProcessed {
+				t.Errorf("isNotProcessed = %v, want %v", got, tt.notProcessed)
+			}
+			if tt.permanent && tt.notProcessed {
+				t.Errorf("status %d tidak boleh permanen sekaligus belum diproses", tt.status)
+			}
+		})
+	}
+}
+
+func TestIsNotProcessedTransportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial gagal", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"koneksi putus saat membaca", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"error lain", errors.New("unexpected EOF"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotProcessed(fmt.Errorf("kirim payout: %w", tt.err)); got != tt.want {
+				t.Errorf("isNotProcessed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
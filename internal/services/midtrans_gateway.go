@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+type MidtransGatewayConfig struct {
+	ServerKey        string
+	CallbackToken    string // dicocokkan dengan header X-Callback-Token bila diisi
+	GoPayCallbackURL string // deeplink kembali ke aplikasi setelah bayar di GoPay
+}
+
+// MidtransGateway adalah PaymentGateway lewat Snap (checkout) dan Core API (charge,
+// status, pembatalan dan refund).
+type MidtransGateway struct {
+	snap *SnapClient
+	core *MidtransCoreClient
+	cfg  MidtransGatewayConfig
+}
+
+func NewMidtransGateway(snap *SnapClient, core *MidtransCoreClient, cfg MidtransGatewayConfig) *MidtransGateway {
+	return &MidtransGateway{snap: snap, core: core, cfg: cfg}
+}
+
+func (g *MidtransGateway) Name() string { return ProviderMidtrans }
+
+func snapCustomer(c *PaymentCustomer) *SnapCustomerDetails {
+	if c == nil {
+		return nil
+	}
+	return &SnapCustomerDetails{FirstName: c.Name, Email: c.Email, Phone: c.Phone}
+}
+
+func (g *MidtransGateway) CreateCheckout(ctx context.Context, req CheckoutRequest) (CheckoutResult, error) {
+	res, err := g.snap.CreateTransaction(ctx, SnapRequest{
+		TransactionDetails: SnapTransactionDetails{
+			OrderID:     req.OrderID,
+			GrossAmount: req.Amount,
+		},
+		CustomerDetails: snapCustomer(req.Customer),
+		Expiry:          NewSnapExpiry(req.StartAt, req.ExpiresIn),
+	})
+	if err != nil {
+		return CheckoutResult{}, err
+	}
+	return CheckoutResult{Token: res.Token, RedirectURL: res.RedirectURL}, nil
+}
+
+func (g *MidtransGateway) Charge(ctx context.Context, req ChargeRequest) (PaymentInstructions, error) {
+	charge := MidtransChargeRequest{
+		TransactionDetails: SnapTransactionDetails{
+			OrderID:     req.OrderID,
+			GrossAmount: req.Amount,
+		},
+		CustomerDetails: snapCustomer(req.Customer),
+		CustomExpiry:    NewMidtransCustomExpiry(req.StartAt, req.ExpiresIn),
+	}
+	switch {
+	case req.Channel == repositories.PaymentChannelBankTransfer && req.Bank == "mandiri":
+		charge.PaymentType = "echannel"
+		charge.EChannel = &MidtransEChannel{BillInfo1: "Top up", BillInfo2: "Saldo wallet"}
+	case req.Channel == repositories.PaymentChannelBankTransfer:
+		charge.PaymentType = "bank_transfer"
+		charge.BankTransfer = &MidtransBankTransfer{Bank: req.Bank}
+	case req.Channel == repositories.PaymentChannelQRIS:
+		charge.PaymentType = "qris"
+		charge.QRIS = &MidtransQRIS{}
+	case req.Channel == repositories.PaymentChannelGoPay:
+		charge.PaymentType = "gopay"
+		if g.cfg.GoPayCallbackURL != "" {
+			charge.GoPay = &MidtransGoPay{EnableCallback: true, CallbackURL: g.cfg.GoPayCallbackURL}
+		}
+	default:
+		return PaymentInstructions{}, ErrConflict{Msg: fmt.Sprintf("channel %s tidak didukung Midtrans", req.Channel)}
+	}
+
+	res, err := g.core.Charge(ctx, charge)
+	if err != nil {
+		return PaymentInstructions{}, err
+	}
+	return chargeInstructions(req.Bank, res), nil
+}
+
+func chargeInstructions(bank string, res MidtransChargeResponse) PaymentInstructions {
+	out := PaymentInstructions{
+		Bank:        bank,
+		BillerCode:  res.BillerCode,
+		BillKey:     res.BillKey,
+		QRString:    res.QRString,
+		QRImageURL:  res.ActionURL("generate-qr-code"),
+		DeeplinkURL: res.ActionURL("deeplink-redirect"),
+	}
+	switch {
+	case res.PermataVANumber != "":
+		out.VANumber = res.PermataVANumber
+	case len(res.VANumbers) > 0:
+		out.Bank = res.VANumbers[0].Bank
+		out.VANumber = res.VANumbers[0].VANumber
+	}
+	return out
+}
+
+// GetStatus memverifikasi signature_key di respons status sama seperti notifikasi.
+func (g *MidtransGateway) GetStatus(ctx context.Context, orderID string) (PaymentUpdate, error) {
+	payload, err := g.core.GetStatus(ctx, orderID)
+	if err != nil {
+		return PaymentUpdate{}, err
+	}
+	if !VerifyNotificationSignature(g.cfg.ServerKey, payload) {
+		return PaymentUpdate{}, errors.New("signature midtrans tidak valid")
+	}
+	return midtransPaymentUpdate(payload)
+}
+
+func (g *MidtransGateway) Cancel(ctx context.Context, orderID string) (string, error) {
+	res, err := g.core.Cancel(ctx, orderID)
+	return res.TransactionID, err
+}
+
+func (g *MidtransGateway) Refund(ctx context.Context, orderID string, req PaymentRefund) error {
+	_, err := g.core.Refund(ctx, orderID, MidtransRefundRequest{
+		RefundKey: req.Key,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+	})
+	return err
+}
+
+func (g *MidtransGateway) ParseNotification(_ context.Context, body []byte, header func(string) string) (PaymentUpdate, error) {
+	if g.cfg.CallbackToken != "" && header("X-Callback-Token") != g.cfg.CallbackToken {
+		return PaymentUpdate{}, ErrForbidden{Msg: "invalid callback token"}
+	}
+	var payload NotificationPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return PaymentUpdate{}, ErrBadRequest{Err: err}
+	}
+	if !VerifyNotificationSignature(g.cfg.ServerKey, payload) {
+		return PaymentUpdate{}, ErrBadRequest{Err: errors.New("signature midtrans tidak valid")}
+	}
+	return midtransPaymentUpdate(payload)
+}
+
+// midtransPaymentUpdate memetakan transaction_status Midtrans ke status payment_orders.
+// capture baru dianggap lunas bila fraud_status accept.
+func midtransPaymentUpdate(payload NotificationPayload) (PaymentUpdate, error) {
+	gross, err := money.Parse(payload.GrossAmount)
+	if err != nil {
+		return PaymentUpdate{}, ErrBadRequest{Err: fmt.Errorf("gross_amount %q tidak valid", payload.GrossAmount)}
+	}
+
+	status := strings.ToUpper(payload.TransactionStatus)
+	switch strings.ToLower(payload.TransactionStatus) {
+	case "capture":
+		status = "PENDING"
+		if strings.EqualFold(payload.FraudStatus, "accept") {
+			status = "SETTLEMENT"
+		}
+	case "settlement":
+		status = "SETTLEMENT"
+	case "cancel":
+		status = "CANCELLED"
+	case "expire":
+		status = "EXPIRED"
+	case "deny":
+		status = "DENY"
+	}
+
+	var settledAt *time.Time
+	if payload.SettlementTime != "" {
+		if t, err := time.Parse(time.RFC3339, payload.SettlementTime); err == nil {
+			settledAt = &t
+		} else if t2, err2 := time.Parse("2006-01-02 15:04:05", payload.SettlementTime); err2 == nil {
+			settledAt = &t2
+		}
+	}
+
+	raw, _ := json.Marshal(payload)
+	return PaymentUpdate{
+		OrderID:       payload.OrderID,
+		Status:        status,
+		GrossAmount:   gross,
+		ProviderTrxID: payload.TransactionID,
+		SettledAt:     settledAt,
+		Raw:           raw,
+	}, nil
+}
+
+// IrisPayoutProvider adalah PayoutProvider lewat Midtrans Iris.
+type IrisPayoutProvider struct {
+	client      *IrisClient
+	merchantKey string // untuk memverifikasi header Iris-Signature
+}
+
+func NewIrisPayoutProvider(client *IrisClient, merchantKey string) *IrisPayoutProvider {
+	return &IrisPayoutProvider{client: client, merchantKey: merchantKey}
+}
+
+func (p *IrisPayoutProvider) Name() string { return ProviderMidtrans }
+
+func (p *IrisPayoutProvider) CreatePayout(ctx context.Context, o PayoutOrder) (PayoutSubmission, error) {
+	res, err := p.client.CreatePayout(ctx, IrisPayoutRequest{
+		Payouts: []IrisPayout{
+			{
+				Amount:             o.Amount,
+				BeneficiaryName:    o.AccountHolderName,
+				BeneficiaryAccount: o.AccountNumber,
+				BeneficiaryBank:    o.BankCode,
+				BeneficiaryEmail:   o.Email,
+				Notes:              o.Notes,
+				PartnerTrxID:       o.PartnerTrxID,
+			},
+		},
+	})
+	if err != nil {
+		return PayoutSubmission{}, err
+	}
+	status, ok := irisPayoutStatus(res.Status)
+	if !ok {
+		status = "REQUESTED"
+	}
+	raw, _ := json.Marshal(res)
+	return PayoutSubmission{Reference: res.PayoutID, Status: status, Raw: raw}, nil
+}
+
+// RequiresApproval bernilai true bila akun Iris memakai maker-checker.
+func (p *IrisPayoutProvider) RequiresApproval() bool {
+	return p.client.ApproverKey != ""
+}
+
+func (p *IrisPayoutProvider) ApprovePayout(ctx context.Context, reference, otp string) error {
+	return p.client.ApprovePayouts(ctx, []string{reference}, otp)
+}
+
+func (p *IrisPayoutProvider) RejectPayout(ctx context.Context, reference, reason string) error {
+	return p.client.RejectPayouts(ctx, []string{reference}, reason)
+}
+
+func (p *IrisPayoutProvider) ValidateAccount(ctx context.Context, bankCode, accountNumber string) (BankAccount, error) {
+	res, err := p.client.ValidateAccount(ctx, bankCode, accountNumber)
+	if err != nil {
+		return BankAccount{}, err
+	}
+	return BankAccount{AccountName: res.AccountName, AccountNo: res.AccountNo, BankName: res.BankName}, nil
+}
+
+// ParseNotification memverifikasi signature dari body mentah sebelum body di-parse.
+func (p *IrisPayoutProvider) ParseNotification(_ context.Context, body []byte, header func(string) string) (PayoutUpdate, error) {
+	if p.merchantKey == "" {
+		return PayoutUpdate{}, fmt.Errorf("iris merchant key belum dikonfigurasi")
+	}
+	if !VerifyIrisSignature(p.merchantKey, body, header("Iris-Signature")) {
+		return PayoutUpdate{}, ErrBadRequest{Err: errors.New("signature iris tidak valid")}
+	}
+
+	var payload IrisNotificationPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return PayoutUpdate{}, ErrBadRequest{Err: err}
+	}
+	if strings.TrimSpace(payload.ReferenceNo) == "" {
+		return PayoutUpdate{}, ErrBadRequest{Err: errors.New("reference_no wajib diisi")}
+	}
+	status, ok := irisPayoutStatus(payload.Status)
+	if !ok {
+		return PayoutUpdate{}, ErrBadRequest{Err: fmt.Errorf("status payout %q tidak dikenal", payload.Status)}
+	}
+	amount, err := money.Parse(payload.Amount)
+	if err != nil {
+		return PayoutUpdate{}, ErrBadRequest{Err: fmt.Errorf("amount %q tidak valid", payload.Amount)}
+	}
+
+	// payout bisa juga diputuskan langsung di dashboard Iris
+	decision := ""
+	switch strings.ToLower(payload.Status) {
+	case "approved", "processed", "completed":
+		decision = repositories.PayoutApprovalApproved
+	case "rejected":
+		decision = repositories.PayoutApprovalRejected
+	}
+
+	var updatedAt *time.Time
+	if t, err := time.Parse(time.RFC3339, payload.UpdatedAt); err == nil {
+		updatedAt = &t
+	}
+	return PayoutUpdate{
+		Reference: payload.ReferenceNo,
+		Amount:    amount,
+		Status:    status,
+		Decision:  decision,
+		Reason:    strings.TrimSpace(firstNonEmpty(payload.ErrorMessage, payload.ErrorCode)),
+		UpdatedAt: updatedAt,
+		Raw:       body,
+	}, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// ProviderMidtrans adalah nama Midtrans (Snap/Core API dan Iris) di kolom provider.
+const ProviderMidtrans = "midtrans"
+
+// providerLabel mengubah nama provider di kolom provider menjadi nama yang
+// ditampilkan ke user, mis. di deskripsi transaksi.
+func providerLabel(provider string) string {
+	switch provider {
+	case ProviderMidtrans:
+		return "Midtrans"
+	case "":
+		return "payment gateway"
+	}
+	return strings.ToUpper(provider[:1]) + provider[1:]
+}
+
+var (
+	// ErrPaymentNotFound: provider belum punya transaksi untuk order tersebut
+	// (mis. halaman Snap ditutup sebelum memilih metode pembayaran).
+	ErrPaymentNotFound = errors.New("transaksi tidak ditemukan di payment gateway")
+	// ErrPaymentNotCancellable: provider menolak pembatalan, biasanya karena sudah dibayar.
+	ErrPaymentNotCancellable = errors.New("transaksi tidak bisa dibatalkan")
+)
+
+// isPermanent bernilai true untuk error provider yang tidak akan berhasil bila
+// request yang sama dikirim ulang (lihat MidtransError dan IrisError).
+func isPermanent(err error) bool {
+	var p interface{ Permanent() bool }
+	return errors.As(err, &p) && p.Permanent()
+}
+
+// isNotProcessed bernilai true bila request pasti belum diproses provider: koneksi
+// gagal dibuka, atau provider menolaknya sebelum memproses isinya (lihat
+// IrisError.NotProcessed). Error lain seperti timeout dan 5xx tidak pasti; provider
+// mungkin sudah menjalankan request-nya.
+func isNotProcessed(err error) bool {
+	var np interface{ NotProcessed() bool }
+	if errors.As(err, &np) && np.NotProcessed() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// PaymentCustomer adalah data pembayar yang diteruskan ke gateway.
+type PaymentCustomer struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+type CheckoutRequest struct {
+	OrderID   string
+	Amount    money.Amount
+	Customer  *PaymentCustomer
+	StartAt   time.Time
+	ExpiresIn time.Duration
+}
+
+// CheckoutResult adalah halaman bayar yang di-host provider (mis. Snap).
+type CheckoutResult struct {
+	Token       string
+	RedirectURL string
+}
+
+type ChargeRequest struct {
+	OrderID   string
+	Channel   string // repositories.PaymentChannel*
+	Bank      string // hanya untuk BANK_TRANSFER
+	Amount    money.Amount
+	Customer  *PaymentCustomer
+	StartAt   time.Time
+	ExpiresIn time.Duration
+}
+
+type PaymentRefund struct {
+	Key    string // idempotency key di sisi provider
+	Amount money.Amount
+	Reason string
+}
+
+// PaymentUpdate adalah status transaksi dari provider, dari notifikasi maupun polling,
+// yang sudah diterjemahkan ke status payment_orders. Saldo hanya dikreditkan bila
+// Status SETTLEMENT.
+type PaymentUpdate struct {
+	OrderID       string
+	Status        string
+	GrossAmount   money.Amount
+	ProviderTrxID string
+	SettledAt     *time.Time
+	Raw           []byte
+}
+
+// PaymentGateway adalah provider penerima dana top up. Channel atau operasi yang
+// tidak didukung dikembalikan sebagai ErrConflict.
+type PaymentGateway interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (CheckoutResult, error)
+	Charge(ctx context.Context, req ChargeRequest) (PaymentInstructions, error)
+	// GetStatus mengembalikan ErrPaymentNotFound bila transaksinya belum ada.
+	GetStatus(ctx context.Context, orderID string) (PaymentUpdate, error)
+	// Cancel mengembalikan id transaksi di provider bila ada.
+	Cancel(ctx context.Context, orderID string) (providerTrxID string, err error)
+	Refund(ctx context.Context, orderID string, req PaymentRefund) error
+	// ParseNotification memverifikasi lalu menerjemahkan notifikasi mentah.
+	ParseNotification(ctx context.Context, body []byte, header func(string) string) (PaymentUpdate, error)
+}
+
+// PayoutOrder adalah satu payout tarik saldo yang dikirim ke provider.
+type PayoutOrder struct {
+	PartnerTrxID      string
+	Amount            money.Amount
+	BankCode          string
+	AccountNumber     string
+	AccountHolderName string
+	Email             string
+	Notes             string
+}
+
+// PayoutSubmission adalah hasil pengiriman payout. Status memakai
+// payout_request_status (REQUESTED, COMPLETED, FAILED).
+type PayoutSubmission struct {
+	Reference string
+	Status    string
+	Raw       []byte
+}
+
+// PayoutLookup diimplementasikan provider yang bisa mencari payout lewat
+// partner_trx_id kita. Dipakai untuk memastikan hasil pengiriman yang tidak pasti
+// sebelum payout dikirim ulang; tanpa ini payout seperti itu menunggu rekonsiliasi
+// manual. found=false berarti provider pasti belum menerima payout tersebut.
+type PayoutLookup interface {
+	FindPayout(ctx context.Context, partnerTrxID string) (res PayoutSubmission, found bool, err error)
+}
+
+// PayoutUpdate adalah status payout dari notifikasi provider. Decision berisi
+// PayoutApprovalApproved/Rejected bila provider melaporkan keputusan approval.
+type PayoutUpdate struct {
+	Reference string
+	Amount    money.Amount
+	Status    string
+	Decision  string
+	Reason    string
+	UpdatedAt *time.Time
+	Raw       []byte
+}
+
+type BankAccount struct {
+	AccountName string
+	AccountNo   string
+	BankName    string
+}
+
+// PayoutProvider mengirim dana tarik saldo ke rekening user.
+type PayoutProvider interface {
+	Name() string
+	CreatePayout(ctx context.Context, p PayoutOrder) (PayoutSubmission, error)
+	// RequiresApproval bernilai true bila payout yang diterima provider masih harus
+	// di-approve atau di-reject sebelum dikirim.
+	RequiresApproval() bool
+	ApprovePayout(ctx context.Context, reference, otp string) error
+	RejectPayout(ctx context.Context, reference, reason string) error
+	ValidateAccount(ctx context.Context, bankCode, accountNumber string) (BankAccount, error)
+	ParseNotification(ctx context.Context, body []byte, header func(string) string) (PayoutUpdate, error)
+}
+
+// PaymentRouting memilih gateway untuk order baru per channel. Order yang sudah ada
+// selalu diproses oleh provider yang tercatat di barisnya.
+type PaymentRouting struct {
+	Default  string
+	Channels map[string]string // channel (SNAP, QRIS, ...) ke nama provider
+}
+
+func (r PaymentRouting) ProviderFor(channel string) string {
+	if name := r.Channels[strings.ToUpper(channel)]; name != "" {
+		return name
+	}
+	return r.Default
+}
+
+// PayoutRouting memilih provider payout per kode bank tujuan.
+type PayoutRouting struct {
+	Default string
+	Banks   map[string]string // kode bank (bca, bni, ...) ke nama provider
+}
+
+func (r PayoutRouting) ProviderFor(bankCode string) string {
+	if name := r.Banks[strings.ToLower(bankCode)]; name != "" {
+		return name
+	}
+	return r.Default
+}
+
+// PaymentGateways adalah daftar gateway yang dikonfigurasi beserta routing-nya.
+type PaymentGateways struct {
+	routing PaymentRouting
+	byName  map[string]PaymentGateway
+}
+
+func NewPaymentGateways(routing PaymentRouting, gateways ...PaymentGateway) *PaymentGateways {
+	g := &PaymentGateways{routing: routing, byName: make(map[string]PaymentGateway, len(gateways))}
+	for _, gw := range gateways {
+		g.byName[gw.Name()] = gw
+	}
+	return g
+}
+
+func (g *PaymentGateways) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.byName)
+}
+
+func (g *PaymentGateways) Get(name string) (PaymentGateway, error) {
+	if g != nil {
+		if gw, ok := g.byName[name]; ok {
+			return gw, nil
+		}
+	}
+	return nil, fmt.Errorf("payment gateway %q belum dikonfigurasi", name)
+}
+
+func (g *PaymentGateways) ForChannel(channel string) (PaymentGateway, error) {
+	if g == nil {
+		return nil, fmt.Errorf("payment gateway belum dikonfigurasi")
+	}
+	return g.Get(g.routing.ProviderFor(channel))
+}
+
+// PayoutProviders adalah daftar provider payout yang dikonfigurasi beserta routing-nya.
+type PayoutProviders struct {
+	routing PayoutRouting
+	byName  map[string]PayoutProvider
+}
+
+func NewPayoutProviders(routing PayoutRouting, providers ...PayoutProvider) *PayoutProviders {
+	p := &PayoutProviders{routing: routing, byName: make(map[string]PayoutProvider, len(providers))}
+	for _, pr := range providers {
+		p.byName[pr.Name()] = pr
+	}
+	return p
+}
+
+func (p *PayoutProviders) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.byName)
+}
+
+func (p *PayoutProviders) Get(name string) (PayoutProvider, error) {
+	if p != nil {
+		if pr, ok := p.byName[name]; ok {
+			return pr, nil
+		}
+	}
+	return nil, fmt.Errorf("payout provider %q belum dikonfigurasi", name)
+}
+
+func (p *PayoutProviders) ForBank(bankCode string) (PayoutProvider, error) {
+	if p == nil {
+		return nil, fmt.Errorf("payout provider belum dikonfigurasi")
+	}
+	return p.Get(p.routing.ProviderFor(bankCode))
+}
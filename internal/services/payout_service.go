@@ -0,0 +1,760 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+const payoutDispatchBatchSize = 50
+
+// payoutApprovalLease adalah lama tanda approval in-flight menahan keputusan lain
+// atas payout yang sama; setelahnya percobaan sebelumnya dianggap mati di tengah jalan.
+const payoutApprovalLease = 2 * time.Minute
+
+// errPayoutApprovalParked menandai payout yang diparkir sebagai UNKNOWN karena hasil
+// keputusan approval sebelumnya di provider tidak pasti.
+var errPayoutApprovalParked = errors.New("status approval di provider tidak pasti")
+
+// PayoutConfig mengatur retry pengiriman dan persetujuan payout.
+type PayoutConfig struct {
+	MaxAttempts int           // setelah percobaan ke-N gagal, payout dianggap FAILED
+	BaseBackoff time.Duration // jeda sebelum percobaan kedua, berlipat dua tiap gagal
+	MaxBackoff  time.Duration
+	// ApprovalThreshold: payout sebesar ini ke atas menunggu review finance, di bawahnya
+	// disetujui otomatis. Nol = semua disetujui otomatis. Hanya berlaku untuk provider
+	// yang RequiresApproval.
+	ApprovalThreshold money.Amount
+}
+
+// PayoutService mengirim payout tarik saldo ke provider yang tercatat di payout dan
+// memproses status yang dikirim balik. Payout yang gagal atau ditolak dikembalikan ke sub-saldo asal sebagai
+// pembalikan transaksi tarik saldonya, sehingga tidak bisa dikembalikan dua kali.
+type PayoutService struct {
+	repo      repositories.WalletRepo
+	reversals *ReversalService
+	limits    *LimitService
+	providers *PayoutProviders
+	validate  *validator.Validate
+	cfg       PayoutConfig
+	now       func() time.Time
+}
+
+func NewPayoutService(r repositories.WalletRepo, reversals *ReversalService, limits *LimitService, providers *PayoutProviders, v *validator.Validate, cfg PayoutConfig) *PayoutService {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	return &PayoutService{repo: r, reversals: reversals, limits: limits, providers: providers, validate: v, cfg: cfg, now: time.Now}
+}
+
+// approvalFor menentukan approval_status payout yang baru diterima provider.
+func (s *PayoutService) approvalFor(provider PayoutProvider, amount money.Amount) *string {
+	if !provider.RequiresApproval() {
+		return nil
+	}
+	status := repositories.PayoutApprovalAuto
+	if !s.cfg.ApprovalThreshold.IsZero() && amount.Cmp(s.cfg.ApprovalThreshold) >= 0 {
+		status = repositories.PayoutApprovalPending
+	}
+	return &status
+}
+
+// backoff adalah jeda sebelum percobaan berikutnya setelah attempt kali gagal.
+func (s *PayoutService) backoff(attempt int) time.Duration {
+	d := s.cfg.BaseBackoff
+	for i := 1; i < attempt && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if s.cfg.MaxBackoff > 0 && d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
+
+// DispatchReport merangkum satu putaran DispatchPending.
+type DispatchReport struct {
+	Requested int // diterima provider
+	Retrying  int // gagal sementara, dicoba lagi nanti
+	Failed    int // gagal permanen dan saldonya dikembalikan
+	Unknown   int // hasilnya tidak pasti, menunggu rekonsiliasi manual
+	Approved  int // disetujui otomatis
+}
+
+// DispatchPending mengirim payout PENDING yang sudah waktunya ke provider-nya. Dipanggil oleh
+// job terjadwal; aman dijalankan paralel di beberapa instance karena tiap payout
+// diklaim dulu sebelum dikirim.
+func (s *PayoutService) DispatchPending(ctx context.Context) (DispatchReport, error) {
+	var report DispatchReport
+	if s.providers.Len() == 0 {
+		return report, fmt.Errorf("payout provider belum dikonfigurasi")
+	}
+	for {
+		ids, err := s.repo.ListDispatchablePayoutIDs(ctx, s.now(), payoutDispatchBatchSize)
+		if err != nil {
+			return report, err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if err := s.dispatch(ctx, id, &report); err != nil {
+				return report, fmt.Errorf("dispatch payout %s: %w", id, err)
+			}
+		}
+		if len(ids) < payoutDispatchBatchSize {
+			break
+		}
+	}
+	return report, s.autoApprove(ctx, &report)
+}
+
+// autoApprove menyetujui payout di bawah ambang review. Penolakan permanen dari
+// provider menggagalkan payout dan mengembalikan saldonya, sama seperti dispatch,
+// kecuali approval-nya pernah terkirim tanpa hasil tercatat: payout itu diparkir
+// sebagai UNKNOWN oleh decide. Gagal sementara tetap AUTO dan dicoba lagi di
+// putaran berikutnya.
+func (s *PayoutService) autoApprove(ctx context.Context, report *DispatchReport) error {
+	payouts, err := s.repo.ListPayoutApprovals(ctx, repositories.PayoutApprovalAuto, payoutDispatchBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, p := range payouts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := s.decide(ctx, p.ID, repositories.PayoutApprovalAuto, repositories.PayoutApprovalApproved, nil, "", func(provider PayoutProvider, ref string) error {
+			return provider.ApprovePayout(ctx, ref, "")
+		})
+		switch {
+		case err == nil:
+			report.Approved++
+		case errors.Is(err, errPayoutApprovalParked):
+			report.Unknown++
+		case isPermanent(err):
+			// provider menolak approval-nya; payout tidak akan dikirim, saldo dikembalikan
+			if err := s.fail(ctx, p.ID, err.Error()); err != nil {
+				return err
+			}
+			report.Failed++
+		default:
+			// status dan approval_status dibiarkan; bisa saja sudah berubah sejak dibaca
+			if err := s.repo.SetPayoutLastError(ctx, p.ID, err.Error()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dispatch mengirim satu payout. Payout hanya dikirim ulang bila percobaan
+// sebelumnya pasti belum sampai di provider; hasil yang tidak pasti (timeout, 5xx,
+// proses mati sebelum hasilnya tercatat) dicek lewat PayoutLookup atau diparkir
+// sebagai UNKNOWN, dan saldo hanya dikembalikan bila provider menolak payout-nya.
+func (s *PayoutService) dispatch(ctx context.Context, id string, report *DispatchReport) error {
+	now := s.now()
+	// next_attempt_at langsung dimundurkan sebesar backoff; bila proses mati di tengah
+	// jalan, payout baru disentuh lagi setelah jeda itu
+	payout, ok, err := s.repo.ClaimPayoutDispatch(ctx, id, now, now.Add(s.backoff(1)))
+	if err != nil || !ok {
+		return err
+	}
+
+	provider, err := s.providers.Get(payout.Provider)
+	if err != nil {
+		return err
+	}
+	if payout.DispatchInFlight {
+		handled, err := s.checkPrevious(ctx, provider, payout, report)
+		if err != nil || handled {
+			return err
+		}
+	}
+
+	inFlight := true
+	if err := s.repo.UpdatePayoutRequestStatus(ctx, repositories.UpdatePayoutRequestStatusParams{
+		ID:               payout.ID,
+		Status:           "PENDING",
+		DispatchInFlight: &inFlight,
+	}); err != nil {
+		return err
+	}
+	res, sendErr := provider.CreatePayout(ctx, PayoutOrder{
+		PartnerTrxID:      payout.PartnerTrxID,
+		Amount:            payout.Amount,
+		BankCode:          payout.BankCode,
+		AccountNumber:     payout.AccountNumber,
+		AccountHolderName: payout.AccountHolderName,
+		Email:             payout.BeneficiaryEmail.String,
+		Notes:             payout.Notes.String,
+	})
+	if sendErr == nil {
+		report.Requested++
+		return s.markRequested(ctx, provider, payout, res)
+	}
+
+	msg := sendErr.Error()
+	switch {
+	case isPermanent(sendErr):
+		report.Failed++
+		return s.fail(ctx, payout.ID, msg)
+	case !isNotProcessed(sendErr):
+		return s.uncertain(ctx, provider, payout, msg, report)
+	case payout.Attempts >= s.cfg.MaxAttempts:
+		// semua percobaan pasti belum sampai di provider, jadi saldo aman dikembalikan
+		report.Failed++
+		return s.fail(ctx, payout.ID, msg)
+	}
+	report.Retrying++
+	return s.retryLater(ctx, payout, msg, false)
+}
+
+// checkPrevious memastikan hasil percobaan sebelumnya yang terputus. handled=false
+// berarti provider pasti belum menerima payout ini dan payout boleh dikirim ulang.
+func (s *PayoutService) checkPrevious(ctx context.Context, provider PayoutProvider, payout repositories.PayoutRequestRecord, report *DispatchReport) (handled bool, err error) {
+	lookup, ok := provider.(PayoutLookup)
+	if !ok {
+		report.Unknown++
+		return true, s.park(ctx, payout, "percobaan sebelumnya terputus sebelum hasilnya tercatat")
+	}
+	res, found, err := lookup.FindPayout(ctx, payout.PartnerTrxID)
+	if err != nil {
+		return true, s.uncertain(ctx, provider, payout, "cek payout gagal: "+err.Error(), report)
+	}
+	if !found {
+		return false, nil
+	}
+	report.Requested++
+	return true, s.markRequested(ctx, provider, payout, res)
+}
+
+// uncertain menangani payout yang mungkin sudah diterima provider: dicek ulang di
+// putaran berikutnya bila provider mendukung PayoutLookup, selain itu diparkir.
+func (s *PayoutService) uncertain(ctx context.Context, provider PayoutProvider, payout repositories.PayoutRequestRecord, msg string, report *DispatchReport) error {
+	if _, ok := provider.(PayoutLookup); ok && payout.Attempts < s.cfg.MaxAttempts {
+		report.Retrying++
+		return s.retryLater(ctx, payout, msg, true)
+	}
+	report.Unknown++
+	return s.park(ctx, payout, msg)
+}
+
+func (s *PayoutService) retryLater(ctx context.Context, payout repositories.PayoutRequestRecord, msg string, inFlight bool) error {
+	next := s.now().Add(s.backoff(payout.Attempts))
+	return s.repo.UpdatePayoutRequestStatus(ctx, repositories.UpdatePayoutRequestStatusParams{
+		ID:               payout.ID,
+		Status:           "PENDING",
+		LastError:        &msg,
+		NextAttemptAt:    &next,
+		DispatchInFlight: &inFlight,
+	})
+}
+
+// park menandai payout UNKNOWN: tidak dikirim ulang dan saldonya tidak dikembalikan
+// sampai admin merekonsiliasinya lewat Resolve.
+func (s *PayoutService) park(ctx context.Context, payout repositories.PayoutRequestRecord, msg string) error {
+	return s.repo.UpdatePayoutRequestStatus(ctx, repositories.UpdatePayoutRequestStatusParams{
+		ID:        payout.ID,
+		Status:    "UNKNOWN",
+		LastError: &msg,
+	})
+}
+
+func (s *PayoutService) markRequested(ctx context.Context, provider PayoutProvider, payout repositories.PayoutRequestRecord, res PayoutSubmission) error {
+	inFlight := false
+	return s.repo.UpdatePayoutRequestStatus(ctx, repositories.UpdatePayoutRequestStatusParams{
+		ID:                payout.ID,
+		Status:            res.Status,
+		ProviderReference: &res.Reference,
+		RawResponse:       res.Raw,
+		DispatchInFlight:  &inFlight,
+		ApprovalStatus:    s.approvalFor(provider, payout.Amount),
+	})
+}
+
+// fail menandai payout yang belum final sebagai FAILED lalu mengembalikan saldonya.
+func (s *PayoutService) fail(ctx context.Context, payoutID, reason string) error {
+	payout, err := s.repo.GetPayoutRequestByID(ctx, payoutID)
+	if err != nil {
+		return err
+	}
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, payout.UserID); err != nil {
+		return err
+	}
+	payout, err = s.repo.GetPayoutRequestForUpdate(ctx, tx, payoutID)
+	if err != nil {
+		return err
+	}
+	if payout.Status == "COMPLETED" || payout.Status == "FAILED" {
+		return nil
+	}
+	now := s.now()
+	if err := s.repo.UpdatePayoutRequestStatusTx(ctx, tx, repositories.UpdatePayoutRequestStatusParams{
+		ID:          payout.ID,
+		Status:      "FAILED",
+		LastError:   &reason,
+		CompletedAt: &now,
+	}); err != nil {
+		return err
+	}
+	if err := s.refund(ctx, tx, payout, "Payout gagal dikirim: "+reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HandleNotification memverifikasi notifikasi lewat provider bernama providerName lalu
+// menerapkannya. Notifikasi ulang untuk payout yang sudah final diabaikan.
+func (s *PayoutService) HandleNotification(ctx context.Context, providerName string, body []byte, header func(string) string) error {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return ErrBadRequest{Err: err}
+	}
+	update, err := provider.ParseNotification(ctx, body, header)
+	if err != nil {
+		return err
+	}
+	status := update.Status
+
+	payout, err := s.repo.GetPayoutRequestByProviderReference(ctx, providerName, update.Reference)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return ErrNotFoundResource{Msg: notFound.Message}
+		}
+		return err
+	}
+	if update.Amount != payout.Amount {
+		return ErrBadRequest{Err: fmt.Errorf("amount %s tidak sesuai payout %s", update.Amount, payout.Amount)}
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// wallet dikunci lebih dulu, sama seperti saat tarik saldo membuat payout ini
+	if status == "FAILED" {
+		if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, payout.UserID); err != nil {
+			return err
+		}
+	}
+	payout, err = s.repo.GetPayoutRequestForUpdate(ctx, tx, payout.ID)
+	if err != nil {
+		return err
+	}
+	if payout.Status == "COMPLETED" || payout.Status == "FAILED" {
+		return nil
+	}
+
+	var completedAt *time.Time
+	if status == "COMPLETED" || status == "FAILED" {
+		t := s.now()
+		if update.UpdatedAt != nil {
+			t = *update.UpdatedAt
+		}
+		completedAt = &t
+	}
+	if err := s.repo.UpdatePayoutRequestStatusTx(ctx, tx, repositories.UpdatePayoutRequestStatusParams{
+		ID:          payout.ID,
+		Status:      status,
+		RawResponse: update.Raw,
+		CompletedAt: completedAt,
+	}); err != nil {
+		return err
+	}
+	// payout bisa juga diputuskan langsung di dashboard provider
+	if approval := payout.ApprovalStatus.String; approval == repositories.PayoutApprovalAuto || approval == repositories.PayoutApprovalPending {
+		if update.Decision != "" {
+			if _, err := s.repo.DecidePayoutApproval(ctx, tx, repositories.DecidePayoutApprovalParams{
+				ID:        payout.ID,
+				From:      approval,
+				Status:    update.Decision,
+				Note:      "diputuskan lewat " + providerName,
+				DecidedAt: s.now(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if status == "FAILED" {
+		reason := "Payout gagal"
+		if update.Reason != "" {
+			reason += ": " + update.Reason
+		}
+		if err := s.refund(ctx, tx, payout, reason); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// refund mengembalikan dana payout yang gagal ke sub-saldo yang didebit saat tarik
+// saldo, dan melepas pemakaian limit tarik saldonya.
+func (s *PayoutService) refund(ctx context.Context, tx *sql.Tx, payout repositories.PayoutRequestRecord, reason string) error {
+	if !payout.TransactionID.Valid {
+		return fmt.Errorf("payout %s tidak terhubung ke transaksi tarik saldo", payout.ID)
+	}
+	orig, err := s.reversals.repo.GetTransactionForUpdate(ctx, tx, payout.TransactionID.String)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.reversals.reverse(ctx, tx, orig, reason, nil); err != nil {
+		// sudah dibalik manual oleh admin; dana tidak boleh dikembalikan lagi
+		var conflict ErrConflict
+		if errors.As(err, &conflict) {
+			return nil
+		}
+		return err
+	}
+
+	return s.limits.Release(ctx, tx, payout.UserID, LimitOpWithdraw, payout.PartnerTrxID, s.now())
+}
+
+type PayoutDTO struct {
+	ID                string       `json:"id"`
+	UserID            string       `json:"userId"`
+	Amount            money.Amount `json:"amount"`
+	BankCode          string       `json:"bankCode"`
+	BankName          string       `json:"bankName,omitempty"`
+	AccountNumber     string       `json:"accountNumber"`
+	AccountHolderName string       `json:"accountHolderName"`
+	Status            string       `json:"status"`
+	ApprovalStatus    string       `json:"approvalStatus,omitempty"`
+	ApprovedBy        *string      `json:"approvedBy,omitempty"`
+	ApprovalNote      string       `json:"approvalNote,omitempty"`
+	LastError         string       `json:"lastError,omitempty"`
+	RequestedAt       time.Time    `json:"requestedAt"`
+	ApprovalDecidedAt *time.Time   `json:"approvalDecidedAt,omitempty"`
+}
+
+func toPayoutDTO(rec repositories.PayoutRequestRecord) PayoutDTO {
+	dto := PayoutDTO{
+		ID:                rec.ID,
+		UserID:            rec.UserID,
+		Amount:            rec.Amount,
+		BankCode:          rec.BankCode,
+		BankName:          rec.BankName.String,
+		AccountNumber:     rec.AccountNumber,
+		AccountHolderName: rec.AccountHolderName,
+		Status:            rec.Status,
+		ApprovalStatus:    rec.ApprovalStatus.String,
+		ApprovalNote:      rec.ApprovalNote.String,
+		LastError:         rec.LastError.String,
+		RequestedAt:       rec.RequestedAt,
+	}
+	if rec.ApprovedBy.Valid {
+		dto.ApprovedBy = &rec.ApprovedBy.String
+	}
+	if rec.ApprovalDecidedAt.Valid {
+		dto.ApprovalDecidedAt = &rec.ApprovalDecidedAt.Time
+	}
+	return dto
+}
+
+// ListApprovals mengembalikan antrean payout yang menunggu review finance, terlama dulu.
+func (s *PayoutService) ListApprovals(ctx context.Context, limit int) ([]PayoutDTO, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	recs, err := s.repo.ListPayoutApprovals(ctx, repositories.PayoutApprovalPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PayoutDTO, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, toPayoutDTO(rec))
+	}
+	return out, nil
+}
+
+type ApprovePayoutInput struct {
+	PayoutID   string `json:"-"   validate:"required,uuid4"`
+	OTP        string `json:"otp" validate:"omitempty,max=16"`
+	ApprovedBy string `json:"-"   validate:"required"`
+}
+
+type RejectPayoutInput struct {
+	PayoutID   string `json:"-"      validate:"required,uuid4"`
+	Reason     string `json:"reason" validate:"required,max=255"`
+	RejectedBy string `json:"-"      validate:"required"`
+}
+
+// Approve meneruskan persetujuan finance ke provider payout. Approver tidak boleh pemilik
+// payout itu sendiri.
+func (s *PayoutService) Approve(ctx context.Context, in ApprovePayoutInput) (PayoutDTO, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return PayoutDTO{}, ErrBadRequest{Err: err}
+	}
+	if err := s.decide(ctx, in.PayoutID, repositories.PayoutApprovalPending, repositories.PayoutApprovalApproved, &in.ApprovedBy, "", func(provider PayoutProvider, ref string) error {
+		return provider.ApprovePayout(ctx, ref, in.OTP)
+	}); err != nil {
+		return PayoutDTO{}, err
+	}
+	return s.get(ctx, in.PayoutID)
+}
+
+// Reject menolak payout di provider lalu mengembalikan dananya ke wallet user.
+func (s *PayoutService) Reject(ctx context.Context, in RejectPayoutInput) (PayoutDTO, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if err := s.validate.Struct(in); err != nil {
+		return PayoutDTO{}, ErrBadRequest{Err: err}
+	}
+	if err := s.decide(ctx, in.PayoutID, repositories.PayoutApprovalPending, repositories.PayoutApprovalRejected, &in.RejectedBy, in.Reason, func(provider PayoutProvider, ref string) error {
+		return provider.RejectPayout(ctx, ref, in.Reason)
+	}); err != nil {
+		return PayoutDTO{}, err
+	}
+	// notifikasi "rejected" dari provider juga memicu refund; mana pun yang duluan, refund
+	// hanya terjadi sekali
+	if err := s.fail(ctx, in.PayoutID, "Ditolak approver: "+in.Reason); err != nil {
+		return PayoutDTO{}, err
+	}
+	return s.get(ctx, in.PayoutID)
+}
+
+// Hasil rekonsiliasi manual payout UNKNOWN.
+const (
+	PayoutOutcomeNotSent   = "NOT_SENT"  // provider pasti belum menerima; dikirim ulang dispatcher
+	PayoutOutcomeSent      = "SENT"      // sudah diterima provider dengan reference tertentu
+	PayoutOutcomeCompleted = "COMPLETED" // dana sudah sampai di rekening tujuan
+	PayoutOutcomeFailed    = "FAILED"    // ditolak atau gagal di provider; saldo dikembalikan
+)
+
+type ResolvePayoutInput struct {
+	PayoutID   string `json:"-"         validate:"required,uuid4"`
+	Outcome    string `json:"outcome"   validate:"required,oneof=NOT_SENT SENT COMPLETED FAILED"`
+	Reference  string `json:"reference" validate:"required_if=Outcome SENT,max=128"`
+	Note       string `json:"note"      validate:"required,max=255"`
+	ResolvedBy string `json:"-"         validate:"required"`
+}
+
+// ListUnknown mengembalikan payout yang hasil pengirimannya tidak pasti, terlama dulu.
+func (s *PayoutService) ListUnknown(ctx context.Context, limit int) ([]PayoutDTO, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	recs, err := s.repo.ListPayoutRequestsByStatus(ctx, "UNKNOWN", limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PayoutDTO, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, toPayoutDTO(rec))
+	}
+	return out, nil
+}
+
+// Resolve menerapkan hasil pengecekan admin di dashboard provider untuk payout UNKNOWN.
+// Saldo hanya dikembalikan untuk outcome FAILED.
+func (s *PayoutService) Resolve(ctx context.Context, in ResolvePayoutInput) (PayoutDTO, error) {
+	in.Outcome = strings.ToUpper(strings.TrimSpace(in.Outcome))
+	in.Reference = strings.TrimSpace(in.Reference)
+	in.Note = strings.TrimSpace(in.Note)
+	if err := s.validate.Struct(in); err != nil {
+		return PayoutDTO{}, ErrBadRequest{Err: err}
+	}
+	payout, err := s.repo.GetPayoutRequestByID(ctx, in.PayoutID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return PayoutDTO{}, ErrNotFoundResource{Msg: "payout tidak ditemukan"}
+		}
+		return PayoutDTO{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return PayoutDTO{}, err
+	}
+	defer tx.Rollback()
+
+	// wallet dikunci lebih dulu, sama seperti fail
+	if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, payout.UserID); err != nil {
+		return PayoutDTO{}, err
+	}
+	payout, err = s.repo.GetPayoutRequestForUpdate(ctx, tx, payout.ID)
+	if err != nil {
+		return PayoutDTO{}, err
+	}
+	if payout.Status != "UNKNOWN" {
+		return PayoutDTO{}, ErrConflict{Msg: "payout tidak sedang menunggu rekonsiliasi"}
+	}
+	// payout lama bisa tidak terhubung ke transaksi tarik saldo; dananya tidak bisa
+	// dikembalikan otomatis bila gagal
+	if !payout.TransactionID.Valid && (in.Outcome == PayoutOutcomeFailed || in.Outcome == PayoutOutcomeNotSent) {
+		return PayoutDTO{}, ErrConflict{Msg: "payout tidak terhubung ke transaksi tarik saldo; kembalikan dana lewat koreksi manual"}
+	}
+
+	now := s.now()
+	params := repositories.ResolvePayoutRequestParams{
+		ID:         payout.ID,
+		ResolvedBy: in.ResolvedBy,
+		Note:       in.Note,
+		ResolvedAt: now,
+	}
+	if in.Reference != "" {
+		params.ProviderReference = &in.Reference
+	}
+	switch in.Outcome {
+	case PayoutOutcomeNotSent:
+		params.Status = "PENDING"
+		params.NextAttemptAt = &now
+	case PayoutOutcomeSent:
+		provider, err := s.providers.Get(payout.Provider)
+		if err != nil {
+			return PayoutDTO{}, ErrConflict{Msg: err.Error()}
+		}
+		params.Status = "REQUESTED"
+		params.ApprovalStatus = s.approvalFor(provider, payout.Amount)
+	case PayoutOutcomeCompleted:
+		params.Status = "COMPLETED"
+		params.CompletedAt = &now
+	case PayoutOutcomeFailed:
+		params.Status = "FAILED"
+		params.CompletedAt = &now
+	}
+	ok, err := s.repo.ResolvePayoutRequest(ctx, tx, params)
+	if err != nil {
+		return PayoutDTO{}, err
+	}
+	if !ok {
+		return PayoutDTO{}, ErrConflict{Msg: "payout sudah direkonsiliasi"}
+	}
+	if in.Outcome == PayoutOutcomeFailed {
+		if err := s.refund(ctx, tx, payout, "Payout gagal: "+in.Note); err != nil {
+			return PayoutDTO{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return PayoutDTO{}, err
+	}
+	return s.get(ctx, payout.ID)
+}
+
+func (s *PayoutService) get(ctx context.Context, id string) (PayoutDTO, error) {
+	rec, err := s.repo.GetPayoutRequestByID(ctx, id)
+	if err != nil {
+		return PayoutDTO{}, err
+	}
+	return toPayoutDTO(rec), nil
+}
+
+// decide memanggil provider lewat call lalu mencatat keputusan approval. Sebelum
+// call, payout ditandai in-flight di transaksi sendiri agar row tidak terkunci
+// selama request ke provider. Bila tanda itu masih ada dari percobaan sebelumnya,
+// penolakan permanen dari provider (mis. payout sudah disetujui) tidak bisa
+// dipercaya; payout diparkir sebagai UNKNOWN untuk dicek manual.
+func (s *PayoutService) decide(ctx context.Context, payoutID, from, to string, by *string, note string, call func(provider PayoutProvider, reference string) error) error {
+	payout, provider, retry, err := s.beginDecision(ctx, payoutID, from, by)
+	if err != nil {
+		return err
+	}
+
+	if err := call(provider, payout.ProviderReference.String); err != nil {
+		if retry {
+			if !isPermanent(err) {
+				// tanda in-flight dibiarkan; percobaan sebelumnya masih belum pasti
+				return err
+			}
+			msg := "approval diulang setelah percobaan sebelumnya terputus, ditolak provider: " + err.Error()
+			if pErr := s.park(ctx, payout, msg); pErr != nil {
+				return errors.Join(err, pErr)
+			}
+			return ErrConflict{Msg: "status approval payout di provider tidak pasti; payout diparkir sebagai UNKNOWN", Err: errPayoutApprovalParked}
+		}
+		if isPermanent(err) || isNotProcessed(err) {
+			// provider pasti tidak menjalankan keputusan ini
+			if cErr := s.repo.SetPayoutApprovalInFlight(ctx, payout.ID, nil); cErr != nil {
+				return errors.Join(err, cErr)
+			}
+		}
+		if isPermanent(err) {
+			return ErrConflict{Msg: err.Error(), Err: err}
+		}
+		return err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ok, err := s.repo.DecidePayoutApproval(ctx, tx, repositories.DecidePayoutApprovalParams{
+		ID:        payout.ID,
+		From:      from,
+		Status:    to,
+		DecidedBy: by,
+		Note:      note,
+		DecidedAt: s.now(),
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict{Msg: "payout sudah diputuskan"}
+	}
+	return tx.Commit()
+}
+
+// beginDecision memvalidasi payout lalu menandainya in-flight. retry=true bila tanda
+// dari percobaan sebelumnya masih ada (lease-nya sudah lewat).
+func (s *PayoutService) beginDecision(ctx context.Context, payoutID, from string, by *string) (payout repositories.PayoutRequestRecord, provider PayoutProvider, retry bool, err error) {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return payout, nil, false, err
+	}
+	defer tx.Rollback()
+
+	payout, err = s.repo.GetPayoutRequestForUpdate(ctx, tx, payoutID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return payout, nil, false, ErrNotFoundResource{Msg: "payout tidak ditemukan"}
+		}
+		return payout, nil, false, err
+	}
+	if payout.Status != "REQUESTED" || payout.ApprovalStatus.String != from || !payout.ProviderReference.Valid {
+		return payout, nil, false, ErrConflict{Msg: "payout tidak sedang menunggu persetujuan"}
+	}
+	provider, err = s.providers.Get(payout.Provider)
+	if err != nil {
+		return payout, nil, false, err
+	}
+	if !provider.RequiresApproval() {
+		return payout, nil, false, ErrConflict{Msg: "persetujuan payout " + payout.Provider + " tidak aktif"}
+	}
+	if by != nil && *by == payout.UserID {
+		return payout, nil, false, ErrConflict{Msg: "approver tidak boleh menyetujui payout miliknya sendiri"}
+	}
+
+	now := s.now()
+	if payout.ApprovalInFlightAt.Valid && now.Sub(payout.ApprovalInFlightAt.Time) < payoutApprovalLease {
+		return payout, nil, false, ErrConflict{Msg: "payout sedang diputuskan, coba lagi nanti"}
+	}
+	if err := s.repo.SetPayoutApprovalInFlightTx(ctx, tx, payout.ID, &now); err != nil {
+		return payout, nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return payout, nil, false, err
+	}
+	return payout, provider, payout.ApprovalInFlightAt.Valid, nil
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Jenis event yang bisa menghasilkan ev_poin (points_rules.event_type).
+const (
+	PointsEventTopUp        = "TOP_UP"
+	PointsEventVoucherClaim = "KLAIM_VOUCHER"
+	PointsEventSpend        = "PEMBAYARAN"
+
+	// PointsEventRedeem mencatat poin yang ditukar menjadi saldo redeem.
+	PointsEventRedeem = "TUKAR_POIN"
+	// PointsEventExpire mencatat poin yang hangus oleh sweeper kedaluwarsa.
+	PointsEventExpire = "KEDALUWARSA_POIN"
+)
+
+// PointsService menghitung dan mencatat ev_poin. Semua perubahan poin lewat
+// points_ledger sehingga wallet_summary.ev_poin selalu bisa ditelusuri.
+type PointsService struct {
+	repo repositories.PointsRepo
+	lots *LotService
+}
+
+func NewPointsService(r repositories.PointsRepo, lots *LotService) *PointsService {
+	return &PointsService{repo: r, lots: lots}
+}
+
+// PointsEvent adalah kejadian yang berpotensi memberi poin. SourceID harus unik
+// per event (order ID, voucher ID, hold ID) agar poin tidak diberikan dua kali.
+type PointsEvent struct {
+	UserID        string
+	EventType     string
+	Amount        money.Amount
+	SourceID      string
+	TransactionID *string
+	Deskripsi     string
+	OccurredAt    time.Time
+}
+
+type PointsEntryDTO struct {
+	ID            string    `json:"id"`
+	EventType     string    `json:"eventType"`
+	Delta         int       `json:"delta"`
+	BalanceAfter  int       `json:"balanceAfter"`
+	SourceID      string    `json:"sourceId"`
+	TransactionID *string   `json:"transactionId,omitempty"`
+	Deskripsi     string    `json:"deskripsi,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Award memberi poin sesuai aturan event di dalam transaksi pemanggil dan
+// mengembalikan jumlah poin yang diberikan (0 bila tidak memenuhi syarat).
+func (p *PointsService) Award(ctx context.Context, tx repositories.DBTX, ev PointsEvent) (int, error) {
+	rule, err := p.repo.GetRule(ctx, tx, ev.EventType)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if !rule.Active || ev.Amount.Cmp(rule.MinAmount) < 0 {
+		return 0, nil
+	}
+
+	points := rule.FlatPoints
+	if rule.PointsPerUnit > 0 && rule.UnitAmount.IsPositive() {
+		points += int(int64(ev.Amount)/int64(rule.UnitAmount)) * rule.PointsPerUnit
+	}
+	if rule.MaxPointsPerEvent.Valid && points > int(rule.MaxPointsPerEvent.Int64) {
+		points = int(rule.MaxPointsPerEvent.Int64)
+	}
+	if points <= 0 {
+		return 0, nil
+	}
+
+	balance, err := p.repo.LockPoints(ctx, tx, ev.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if rule.DailyCap.Valid {
+		awarded, err := p.repo.SumAwardedSince(ctx, tx, ev.UserID, ev.EventType, startOfDay(ev.OccurredAt))
+		if err != nil {
+			return 0, err
+		}
+		if left := int(rule.DailyCap.Int64) - awarded; points > left {
+			points = left
+		}
+		if points <= 0 {
+			return 0, nil
+		}
+	}
+
+	awarded, err := p.record(ctx, tx, ev.UserID, balance, points, ev)
+	if err != nil || awarded == 0 {
+		return 0, err
+	}
+	if err := p.lots.Credit(ctx, tx, LotCredit{
+		UserID:     ev.UserID,
+		Kind:       repositories.LotKindPoints,
+		Quantity:   pointsQuantity(awarded),
+		SourceType: ev.EventType,
+		SourceID:   ev.SourceID,
+		CreatedAt:  ev.OccurredAt,
+	}); err != nil {
+		return 0, err
+	}
+	return awarded, nil
+}
+
+// Spend mengurangi poin user di dalam transaksi pemanggil. ev.SourceID harus unik
+// per penukaran agar pengurangan tidak tercatat dua kali.
+func (p *PointsService) Spend(ctx context.Context, tx repositories.DBTX, points int, ev PointsEvent) error {
+	balance, err := p.repo.LockPoints(ctx, tx, ev.UserID)
+	if err != nil {
+		return err
+	}
+	if balance < points {
+		return ErrInsufficientBalance{Msg: "ev_poin tidak mencukupi"}
+	}
+	n, err := p.record(ctx, tx, ev.UserID, balance, -points, ev)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict{Msg: "penukaran poin sudah diproses"}
+	}
+	_, err = p.lots.Consume(ctx, tx, ev.UserID, repositories.LotKindPoints, pointsQuantity(points))
+	return err
+}
+
+// Expire mencatat poin yang hangus. Lot sudah dikurangi oleh pemanggil (sweeper).
+func (p *PointsService) Expire(ctx context.Context, tx repositories.DBTX, points int, ev PointsEvent) error {
+	balance, err := p.repo.LockPoints(ctx, tx, ev.UserID)
+	if err != nil {
+		return err
+	}
+	if points > balance {
+		points = balance
+	}
+	if points <= 0 {
+		return nil
+	}
+	_, err = p.record(ctx, tx, ev.UserID, balance, -points, ev)
+	return err
+}
+
+func (p *PointsService) record(ctx context.Context, tx repositories.DBTX, userID string, balance, delta int, ev PointsEvent) (int, error) {
+	inserted, err := p.repo.CreateEntry(ctx, tx, repositories.CreatePointsEntryParams{
+		UserID:        userID,
+		EventType:     ev.EventType,
+		Delta:         delta,
+		BalanceAfter:  balance + delta,
+		SourceID:      ev.SourceID,
+		TransactionID: ev.TransactionID,
+		Deskripsi:     ev.Deskripsi,
+		CreatedAt:     ev.OccurredAt,
+	})
+	if err != nil || !inserted {
+		return 0, err
+	}
+	if err := p.repo.SetPoints(ctx, tx, userID, balance+delta); err != nil {
+		return 0, err
+	}
+	return delta, nil
+}
+
+// LedgerBalance menghitung ev_poin user langsung dari points_ledger.
+func (p *PointsService) LedgerBalance(ctx context.Context, tx repositories.DBTX, userID string) (int, error) {
+	return p.repo.SumEntries(ctx, tx, userID)
+}
+
+// Resync menyamakan wallet_summary.ev_poin dengan points_ledger.
+func (p *PointsService) Resync(ctx context.Context, tx repositories.DBTX, userID string) error {
+	sum, err := p.repo.SumEntries(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	return p.repo.SetPoints(ctx, tx, userID, sum)
+}
+
+func (p *PointsService) History(ctx context.Context, userID string, limit int) ([]PointsEntryDTO, error) {
+	rows, err := p.repo.ListEntries(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PointsEntryDTO, 0, len(rows))
+	for _, row := range rows {
+		var txnID *string
+		if row.TransactionID.Valid {
+			id := row.TransactionID.String
+			txnID = &id
+		}
+		result = append(result, PointsEntryDTO{
+			ID:            row.ID,
+			EventType:     row.EventType,
+			Delta:         row.Delta,
+			BalanceAfter:  row.BalanceAfter,
+			SourceID:      row.SourceID,
+			TransactionID: txnID,
+			Deskripsi:     row.Deskripsi.String,
+			CreatedAt:     row.CreatedAt,
+		})
+	}
+	return result, nil
+}
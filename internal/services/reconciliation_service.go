@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Field dan sumber pembanding pada hasil rekonsiliasi.
+const (
+	ReconFieldTopup  = "saldo_topup"
+	ReconFieldRedeem = "saldo_redeem"
+	ReconFieldTotal  = "total_saldo"
+	ReconFieldOrders = "top_up"
+	ReconFieldPoints = "ev_poin"
+
+	ReconSourceLedger        = "ledger"
+	ReconSourceWalletSummary = "wallet_summary"
+	ReconSourcePaymentOrders = "payment_orders"
+	ReconSourcePointsLedger  = "points_ledger"
+)
+
+// balanceEffect adalah arah perubahan sub-saldo untuk satu tipe transaksi.
+type balanceEffect struct {
+	Topup  int64
+	Redeem int64
+}
+
+// transactionEffects memetakan tipe_transaksi ke efeknya pada saldo_topup/saldo_redeem.
+// Tipe transaksi baru wajib didaftarkan di sini agar bisa direkonsiliasi.
+var transactionEffects = map[string]balanceEffect{
+	"TOP_UP":                 {Topup: 1},
+	"KLAIM_VOUCHER":          {Redeem: 1},
+	"TARIK_SALDO_PENDAPATAN": {Topup: -1},
+	"TARIK_SALDO_REFUND":     {Redeem: -1},
+	"TRANSFER_KELUAR_TOPUP":  {Topup: -1},
+	"TRANSFER_KELUAR_REDEEM": {Redeem: -1},
+	"TRANSFER_MASUK_TOPUP":   {Topup: 1},
+	"TRANSFER_MASUK_REDEEM":  {Redeem: 1},
+	"PEMBAYARAN_TOPUP":       {Topup: -1},
+	"PEMBAYARAN_REDEEM":      {Redeem: -1},
+	"TUKAR_POIN":             {Redeem: 1},
+	"KEDALUWARSA_REDEEM":     {Redeem: -1},
+	"REFUND_TOPUP":           {Topup: -1},
+
+	"PEMBALIKAN_DEBIT_TOPUP":   {Topup: -1},
+	"PEMBALIKAN_DEBIT_REDEEM":  {Redeem: -1},
+	"PEMBALIKAN_KREDIT_TOPUP":  {Topup: 1},
+	"PEMBALIKAN_KREDIT_REDEEM": {Redeem: 1},
+}
+
+// createTransaction mencatat transaksi beserta balance_after-nya. Efek saldo diambil
+// dari transactionEffects, jadi tipe yang belum terdaftar langsung ditolak.
+func createTransaction(ctx context.Context, repo repositories.WalletRepo, tx repositories.DBTX, p repositories.CreateTransactionParams) (string, error) {
+	topup, redeem, err := transactionDelta(p.TipeTransaksi, p.Jumlah)
+	if err != nil {
+		return "", err
+	}
+	p.TopupDelta, p.RedeemDelta = topup, redeem
+	return repo.CreateTransaction(ctx, tx, p)
+}
+
+// transactionDelta mengembalikan perubahan saldo_topup/saldo_redeem akibat satu transaksi.
+func transactionDelta(tipe string, amount money.Amount) (topup, redeem money.Amount, err error) {
+	eff, ok := transactionEffects[tipe]
+	if !ok {
+		return 0, 0, fmt.Errorf("tipe transaksi %s belum punya aturan rekonsiliasi", tipe)
+	}
+	return amount.MulInt(eff.Topup), amount.MulInt(eff.Redeem), nil
+}
+
+// ReconciliationService menghitung ulang saldo wallet dari histori transactions
+// lalu membandingkannya dengan ledger, wallet_summary dan payment_orders.
+type ReconciliationService struct {
+	repo   repositories.ReconciliationRepo
+	wallet repositories.WalletRepo
+	ledger *LedgerService
+	points *PointsService
+	now    func() time.Time
+}
+
+func NewReconciliationService(r repositories.ReconciliationRepo, wallet repositories.WalletRepo, ledger *LedgerService, points *PointsService) *ReconciliationService {
+	return &ReconciliationService{repo: r, wallet: wallet, ledger: ledger, points: points, now: time.Now}
+}
+
+type ReconcileOptions struct {
+	UserID      string // kosong = semua wallet
+	Apply       bool   // tulis jurnal penyesuaian untuk selisih ledger
+	TriggeredBy string
+}
+
+type ReconciliationMismatch struct {
+	UserID   string       `json:"userId"`
+	Field    string       `json:"field"`
+	Source   string       `json:"source"`
+	Expected money.Amount `json:"expected"`
+	Actual   money.Amount `json:"actual"`
+	Adjusted bool         `json:"adjusted"`
+}
+
+type ReconciliationReport struct {
+	RunID        string                   `json:"runId"`
+	Apply        bool                     `json:"apply"`
+	UsersChecked int                      `json:"usersChecked"`
+	Adjustments  int                      `json:"adjustments"`
+	Mismatches   []ReconciliationMismatch `json:"mismatches"`
+	StartedAt    time.Time                `json:"startedAt"`
+	FinishedAt   time.Time                `json:"finishedAt"`
+}
+
+// Unresolved menghitung selisih yang belum dikoreksi.
+func (r ReconciliationReport) Unresolved() int {
+	n := 0
+	for _, m := range r.Mismatches {
+		if !m.Adjusted {
+			n++
+		}
+	}
+	return n
+}
+
+const reconBatchSize = 500
+
+func (s *ReconciliationService) Run(ctx context.Context, opts ReconcileOptions) (ReconciliationReport, error) {
+	if opts.TriggeredBy == "" {
+		opts.TriggeredBy = "unknown"
+	}
+	report := ReconciliationReport{Apply: opts.Apply, StartedAt: s.now()}
+
+	runID, err := s.repo.CreateRun(ctx, repositories.CreateReconciliationRunParams{
+		TriggeredBy: opts.TriggeredBy,
+		Apply:       opts.Apply,
+		StartedAt:   report.StartedAt,
+	})
+	if err != nil {
+		return report, err
+	}
+	report.RunID = runID
+
+	runErr := s.forEachUser(ctx, opts.UserID, func(userID string) error {
+		return s.reconcileUser(ctx, userID, opts.Apply, &report)
+	})
+
+	report.FinishedAt = s.now()
+	finish := repositories.FinishReconciliationRunParams{
+		ID:           runID,
+		UsersChecked: report.UsersChecked,
+		Mismatches:   len(report.Mismatches),
+		Adjustments:  report.Adjustments,
+		FinishedAt:   report.FinishedAt,
+	}
+	if runErr != nil {
+		msg := runErr.Error()
+		finish.Error = &msg
+	}
+	if err := s.repo.FinishRun(ctx, finish); err != nil && runErr == nil {
+		runErr = err
+	}
+	return report, runErr
+}
+
+func (s *ReconciliationService) forEachUser(ctx context.Context, userID string, fn func(string) error) error {
+	if userID != "" {
+		return fn(userID)
+	}
+	after := ""
+	for {
+		ids, err := s.repo.ListWalletUserIDs(ctx, after, reconBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(id); err != nil {
+				return fmt.Errorf("rekonsiliasi user %s: %w", id, err)
+			}
+		}
+		if len(ids) < reconBatchSize {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// reconcileUser mengunci wallet user supaya histori, ledger dan wallet_summary
+// dibaca pada kondisi yang sama, lalu mencatat setiap selisih.
+func (s *ReconciliationService) reconcileUser(ctx context.Context, userID string, apply bool, report *ReconciliationReport) error {
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	total, topup, redeem, evPoin, err := s.wallet.GetSaldoForUpdate(ctx, tx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+	report.UsersChecked++
+
+	byType, err := s.repo.SumTransactionsByType(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	var histTopup, histRedeem money.Amount
+	for tipe, sum := range byType {
+		eff, ok := transactionEffects[tipe]
+		if !ok {
+			return fmt.Errorf("tipe transaksi %s belum punya aturan rekonsiliasi", tipe)
+		}
+		histTopup += sum.MulInt(eff.Topup)
+		histRedeem += sum.MulInt(eff.Redeem)
+	}
+
+	postings, err := s.repo.SumPostingsByAccount(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	ledgerTopup := postings[repositories.LedgerAccountUserTopup]
+	ledgerRedeem := postings[repositories.LedgerAccountUserRedeem]
+
+	settled, err := s.repo.SumSettledTopUps(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	pointsLedger, err := s.points.LedgerBalance(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var found []ReconciliationMismatch
+	check := func(field, source string, expected, actual money.Amount) {
+		if expected != actual {
+			found = append(found, ReconciliationMismatch{
+				UserID:   userID,
+				Field:    field,
+				Source:   source,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+	check(ReconFieldTopup, ReconSourceLedger, histTopup, ledgerTopup)
+	check(ReconFieldRedeem, ReconSourceLedger, histRedeem, ledgerRedeem)
+	check(ReconFieldTopup, ReconSourceWalletSummary, histTopup, topup)
+	check(ReconFieldRedeem, ReconSourceWalletSummary, histRedeem, redeem)
+	check(ReconFieldTotal, ReconSourceWalletSummary, histTopup+histRedeem, total)
+	check(ReconFieldOrders, ReconSourcePaymentOrders, byType["TOP_UP"], settled)
+	check(ReconFieldPoints, ReconSourcePointsLedger, money.FromRupiah(int64(pointsLedger)), money.FromRupiah(int64(evPoin)))
+	if len(found) == 0 {
+		return nil
+	}
+
+	now := s.now()
+	entryIDs := make(map[string]string)
+	fixed := map[string]bool{
+		ReconFieldTopup:  histTopup == ledgerTopup,
+		ReconFieldRedeem: histRedeem == ledgerRedeem,
+		ReconFieldPoints: pointsLedger == evPoin,
+	}
+	if apply {
+		// points_ledger adalah sumber kebenaran ev_poin
+		if !fixed[ReconFieldPoints] && pointsLedger >= 0 {
+			if err := s.points.Resync(ctx, tx, userID); err != nil {
+				return err
+			}
+			fixed[ReconFieldPoints] = true
+		}
+		// samakan dulu saldo berjalan akun dengan posting agar jurnal penyesuaian
+		// dihitung dari angka yang benar; ini sekaligus memperbaiki wallet_summary
+		if err := s.ledger.Rebuild(ctx, tx, userID, now); err != nil {
+			return err
+		}
+		adjust := []struct {
+			field    string
+			kind     string
+			expected money.Amount
+			actual   money.Amount
+		}{
+			{ReconFieldTopup, repositories.LedgerAccountUserTopup, histTopup, ledgerTopup},
+			{ReconFieldRedeem, repositories.LedgerAccountUserRedeem, histRedeem, ledgerRedeem},
+		}
+		for _, a := range adjust {
+			// saldo negatif menurut histori butuh investigasi manual, jangan dipaksakan
+			if a.expected == a.actual || a.expected.IsNegative() {
+				continue
+			}
+			ref := report.RunID
+			entryID, err := s.ledger.Post(ctx, tx, JournalEntry{
+				EntryType:   "RECONCILIATION_ADJUSTMENT",
+				ReferensiID: &ref,
+				Deskripsi:   "Penyesuaian rekonsiliasi " + a.field,
+				Postings:    reconAdjustmentPostings(userID, a.kind, a.expected-a.actual),
+				CreatedAt:   now,
+			})
+			if err != nil {
+				return err
+			}
+			entryIDs[a.field] = entryID
+			fixed[a.field] = true
+		}
+	}
+
+	for i := range found {
+		m := &found[i]
+		if apply {
+			switch {
+			case m.Source == ReconSourceLedger, m.Source == ReconSourcePointsLedger:
+				m.Adjusted = fixed[m.Field]
+			case m.Source == ReconSourceWalletSummary && m.Field == ReconFieldTotal:
+				m.Adjusted = fixed[ReconFieldTopup] && fixed[ReconFieldRedeem]
+			case m.Source == ReconSourceWalletSummary:
+				m.Adjusted = fixed[m.Field]
+			}
+		}
+		var entryID *string
+		if id, ok := entryIDs[m.Field]; ok && m.Adjusted {
+			entryID = &id
+		}
+		if err := s.repo.CreateMismatch(ctx, tx, repositories.CreateReconciliationMismatchParams{
+			RunID:         report.RunID,
+			UserID:        userID,
+			Field:         m.Field,
+			Source:        m.Source,
+			Expected:      m.Expected,
+			Actual:        m.Actual,
+			Adjusted:      m.Adjusted,
+			LedgerEntryID: entryID,
+			CreatedAt:     now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	report.Adjustments += len(entryIDs)
+	report.Mismatches = append(report.Mismatches, found...)
+	return nil
+}
+
+// reconAdjustmentPostings membuat jurnal penyesuaian sebesar delta (positif = saldo user ditambah).
+func reconAdjustmentPostings(userID, kind string, delta money.Amount) []LedgerPosting {
+	if delta.IsPositive() {
+		return []LedgerPosting{
+			ledgerDebit("", repositories.LedgerAccountReconAdjustment, delta),
+			ledgerCredit(userID, kind, delta),
+		}
+	}
+	return []LedgerPosting{
+		ledgerDebit(userID, kind, delta.Neg()),
+		ledgerCredit("", repositories.LedgerAccountReconAdjustment, delta.Neg()),
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// reversalCounterparts adalah akun sistem lawan saat transaksi dibalik. Transfer
+// antar user sengaja tidak ada di sini: koreksinya berupa transfer balik karena
+// menyentuh saldo dua user.
+var reversalCounterparts = map[string]string{
+	"TOP_UP":                 repositories.LedgerAccountMidtransClearing,
+	"KLAIM_VOUCHER":          repositories.LedgerAccountVoucherFunding,
+	"TUKAR_POIN":             repositories.LedgerAccountPointsFunding,
+	"TARIK_SALDO_PENDAPATAN": repositories.LedgerAccountPayoutClearing,
+	"TARIK_SALDO_REFUND":     repositories.LedgerAccountPayoutClearing,
+	"PEMBAYARAN_TOPUP":       repositories.LedgerAccountMerchant,
+	"PEMBAYARAN_REDEEM":      repositories.LedgerAccountMerchant,
+	"KEDALUWARSA_REDEEM":     repositories.LedgerAccountExpiredBalance,
+	"REFUND_TOPUP":           repositories.LedgerAccountMidtransClearing,
+}
+
+// ReversalService membalik transaksi yang salah (mis. voucher salah kredit atau
+// top up terbukukan dua kali) dengan transaksi pembalik yang saling terhubung.
+// ev_poin yang sudah diberikan tidak ikut ditarik.
+type ReversalService struct {
+	repo     repositories.ReversalRepo
+	wallet   repositories.WalletRepo
+	ledger   *LedgerService
+	lots     *LotService
+	validate *validator.Validate
+	now      func() time.Time
+}
+
+func NewReversalService(r repositories.ReversalRepo, wallet repositories.WalletRepo, ledger *LedgerService, lots *LotService, v *validator.Validate) *ReversalService {
+	return &ReversalService{repo: r, wallet: wallet, ledger: ledger, lots: lots, validate: v, now: time.Now}
+}
+
+type ReverseTransactionInput struct {
+	TransactionID string `json:"-"      validate:"required,uuid4"`
+	Reason        string `json:"reason" validate:"required,max=500"`
+	ReversedBy    string `json:"-"`
+}
+
+type ReversalDTO struct {
+	ID                    string       `json:"id"`
+	OriginalTransactionID string       `json:"originalTransactionId"`
+	ReversalTransactionID string       `json:"reversalTransactionId"`
+	UserID                string       `json:"userId"`
+	Type                  string       `json:"type"`
+	Amount                money.Amount `json:"amount"`
+	Reason                string       `json:"reason"`
+	ReversedBy            *string      `json:"reversedBy,omitempty"`
+	CreatedAt             time.Time    `json:"createdAt"`
+}
+
+// Reverse membukukan kebalikan transaksi asal ke akun sistem lawannya. Status wallet
+// tidak dicek karena ini koreksi oleh admin, tetapi saldo tersedia (di luar hold)
+// tetap harus cukup bila pembalikan mengurangi saldo.
+func (s *ReversalService) Reverse(ctx context.Context, in ReverseTransactionInput) (ReversalDTO, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if err := s.validate.Struct(in); err != nil {
+		return ReversalDTO{}, ErrBadRequest{Err: err}
+	}
+
+	orig, err := s.repo.GetTransaction(ctx, in.TransactionID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return ReversalDTO{}, ErrNotFoundResource{Msg: "transaksi tidak ditemukan"}
+		}
+		return ReversalDTO{}, err
+	}
+
+	tx, err := s.wallet.BeginTx(ctx)
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+	defer tx.Rollback()
+
+	var reversedBy *string
+	if in.ReversedBy != "" {
+		reversedBy = &in.ReversedBy
+	}
+	res, err := s.reverse(ctx, tx, orig, in.Reason, reversedBy)
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ReversalDTO{}, err
+	}
+	return res, nil
+}
+
+// reverse membalik orig di dalam tx milik caller. Transaksi yang sudah pernah dibalik
+// ditolak dengan ErrConflict.
+func (s *ReversalService) reverse(ctx context.Context, tx *sql.Tx, orig repositories.TransactionRecord, reason string, reversedBy *string) (ReversalDTO, error) {
+	counterpart, ok := reversalCounterparts[orig.Type]
+	if !ok {
+		return ReversalDTO{}, ErrConflict{Msg: fmt.Sprintf("transaksi %s tidak bisa dibalik", orig.Type)}
+	}
+
+	// urutan lock sama dengan alur saldo lain: wallet dulu, baru baris transaksinya
+	_, topup, redeem, _, err := s.wallet.GetSaldoForUpdate(ctx, tx, orig.UserID)
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+	if _, err := s.repo.GetTransactionForUpdate(ctx, tx, orig.ID); err != nil {
+		return ReversalDTO{}, err
+	}
+	if existing, err := s.repo.FindReversal(ctx, tx, orig.ID); err == nil {
+		if existing.ReversalTransactionID == orig.ID {
+			return ReversalDTO{}, ErrConflict{Msg: "transaksi pembalikan tidak bisa dibalik"}
+		}
+		return ReversalDTO{}, ErrConflict{Msg: "transaksi sudah pernah dibalik"}
+	} else {
+		var notFound repositories.ErrNotFound
+		if !errors.As(err, &notFound) {
+			return ReversalDTO{}, err
+		}
+	}
+
+	order, err := s.checkLinked(ctx, tx, orig)
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+
+	dTopup, dRedeem, err := transactionDelta(orig.Type, orig.Amount)
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+	account, kind := repositories.LedgerAccountUserTopup, repositories.LotKindRedeem
+	delta := dTopup.Neg()
+	if !dRedeem.IsZero() {
+		account, delta = repositories.LedgerAccountUserRedeem, dRedeem.Neg()
+	}
+	debit := delta.IsNegative()
+
+	var reversalType string
+	switch {
+	case debit && account == repositories.LedgerAccountUserTopup:
+		reversalType = "PEMBALIKAN_DEBIT_TOPUP"
+	case debit:
+		reversalType = "PEMBALIKAN_DEBIT_REDEEM"
+	case account == repositories.LedgerAccountUserTopup:
+		reversalType = "PEMBALIKAN_KREDIT_TOPUP"
+	default:
+		reversalType = "PEMBALIKAN_KREDIT_REDEEM"
+	}
+
+	if debit {
+		heldTopup, heldRedeem, err := s.wallet.GetHeldTx(ctx, tx, orig.UserID)
+		if err != nil {
+			return ReversalDTO{}, err
+		}
+		available := topup.Sub(heldTopup)
+		if account == repositories.LedgerAccountUserRedeem {
+			available = redeem.Sub(heldRedeem)
+		}
+		if available.Cmp(orig.Amount) < 0 {
+			return ReversalDTO{}, ErrInsufficientBalance{Msg: "saldo tersedia tidak mencukupi untuk pembalikan"}
+		}
+	}
+
+	now := s.now()
+	ref := orig.ID
+	desc := "Pembalikan " + orig.Type + ": " + reason
+	txnID, err := createTransaction(ctx, s.wallet, tx, repositories.CreateTransactionParams{
+		UserID:        orig.UserID,
+		TipeTransaksi: reversalType,
+		Jumlah:        orig.Amount,
+		Deskripsi:     desc,
+		ReferensiID:   &ref,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+
+	postings := []LedgerPosting{
+		ledgerDebit(orig.UserID, account, orig.Amount),
+		ledgerCredit("", counterpart, orig.Amount),
+	}
+	if !debit {
+		postings = []LedgerPosting{
+			ledgerDebit("", counterpart, orig.Amount),
+			ledgerCredit(orig.UserID, account, orig.Amount),
+		}
+	}
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     reversalType,
+		TransactionID: &txnID,
+		ReferensiID:   &ref,
+		Deskripsi:     desc,
+		Postings:      postings,
+		CreatedAt:     now,
+	}); err != nil {
+		return ReversalDTO{}, err
+	}
+
+	// lot saldo redeem ikut disesuaikan supaya sweeper kedaluwarsa tetap akurat
+	if account == repositories.LedgerAccountUserRedeem {
+		if debit {
+			if _, err := s.lots.Consume(ctx, tx, orig.UserID, kind, orig.Amount); err != nil {
+				return ReversalDTO{}, err
+			}
+		} else if err := s.lots.Credit(ctx, tx, LotCredit{
+			UserID:     orig.UserID,
+			Kind:       kind,
+			Quantity:   orig.Amount,
+			SourceType: reversalType,
+			SourceID:   orig.ID,
+			CreatedAt:  now,
+		}); err != nil {
+			return ReversalDTO{}, err
+		}
+	}
+
+	if order != nil {
+		if err := s.wallet.MarkPaymentOrderReversed(ctx, tx, order.OrderID, txnID, now); err != nil {
+			return ReversalDTO{}, err
+		}
+	}
+
+	rec, err := s.repo.CreateReversal(ctx, tx, repositories.CreateReversalParams{
+		OriginalTransactionID: orig.ID,
+		ReversalTransactionID: txnID,
+		UserID:                orig.UserID,
+		Reason:                reason,
+		ReversedBy:            reversedBy,
+		CreatedAt:             now,
+	})
+	if err != nil {
+		return ReversalDTO{}, err
+	}
+
+	return ReversalDTO{
+		ID:                    rec.ID,
+		OriginalTransactionID: orig.ID,
+		ReversalTransactionID: txnID,
+		UserID:                orig.UserID,
+		Type:                  reversalType,
+		Amount:                orig.Amount,
+		Reason:                reason,
+		ReversedBy:            reversedBy,
+		CreatedAt:             now,
+	}, nil
+}
+
+// checkLinked mengunci dan memeriksa record yang terhubung dengan transaksi asal.
+// Tarik saldo hanya boleh dibalik setelah payout-nya final: payout yang masih bisa
+// dikirim akan mengeluarkan dana yang sudah dikembalikan. Top up yang refund-nya
+// sedang diproses atau selesai tidak boleh dibalik karena saldonya sudah didebit
+// refund; order-nya dikembalikan agar pembalikan dicatat di sana.
+func (s *ReversalService) checkLinked(ctx context.Context, tx *sql.Tx, orig repositories.TransactionRecord) (*repositories.PaymentOrderRecord, error) {
+	switch orig.Type {
+	case "TARIK_SALDO_PENDAPATAN", "TARIK_SALDO_REFUND":
+		// histori lama bisa tidak merujuk ke payout_requests
+		if _, err := uuid.Parse(orig.ReferenceID.String); !orig.ReferenceID.Valid || err != nil {
+			return nil, nil
+		}
+		payout, err := s.wallet.GetPayoutRequestForUpdate(ctx, tx, orig.ReferenceID.String)
+		if err != nil {
+			var notFound repositories.ErrNotFound
+			if errors.As(err, &notFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if payout.Status != "COMPLETED" && payout.Status != "FAILED" {
+			return nil, ErrConflict{Msg: fmt.Sprintf("payout %s masih berstatus %s; selesaikan payout sebelum membalik tarik saldo", payout.ID, payout.Status)}
+		}
+		return nil, nil
+	case "TOP_UP":
+		order, err := s.wallet.GetPaymentOrderByTransactionForUpdate(ctx, tx, orig.ID)
+		if err != nil {
+			var notFound repositories.ErrNotFound
+			if errors.As(err, &notFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		switch order.RefundStatus.String {
+		case repositories.TopUpRefundPending, repositories.TopUpRefundRefunded:
+			return nil, ErrConflict{Msg: fmt.Sprintf("top up %s sudah/sedang direfund; tidak bisa dibalik", order.OrderID)}
+		}
+		return &order, nil
+	}
+	return nil, nil
+}
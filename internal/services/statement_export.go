@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// WriteStatementCSV menulis statement sebagai CSV: baris saldo awal, mutasi, lalu saldo akhir.
+// Nominal ditulis tanpa pemisah ribuan supaya mudah diolah spreadsheet.
+func WriteStatementCSV(ctx context.Context, w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"tanggal", "tipe", "deskripsi", "referensi", "kredit", "debit", "saldo_topup", "saldo_redeem", "saldo_total"})
+
+	h := st.Header
+	_ = cw.Write([]string{h.From.In(wib).Format(time.RFC3339), "SALDO_AWAL", "Saldo awal periode " + h.Period, "", "", "",
+		h.Opening.Topup.String(), h.Opening.Redeem.String(), h.Opening.Total.String()})
+
+	n := 0
+	err := st.Lines(ctx, func(l StatementLine) error {
+		_ = cw.Write([]string{
+			l.Time.In(wib).Format(time.RFC3339),
+			l.Type,
+			l.Description,
+			l.Reference,
+			csvAmount(l.Credit),
+			csvAmount(l.Debit),
+			l.Balance.Topup.String(),
+			l.Balance.Redeem.String(),
+			l.Balance.Total.String(),
+		})
+		// flush berkala supaya data langsung mengalir ke client
+		if n++; n%500 == 0 {
+			cw.Flush()
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	_ = cw.Write([]string{h.To.In(wib).Format(time.RFC3339), "SALDO_AKHIR", "Saldo akhir periode " + h.Period,
+		"", h.TotalCredit.String(), h.TotalDebit.String(),
+		h.Closing.Topup.String(), h.Closing.Redeem.String(), h.Closing.Total.String()})
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvAmount(a money.Amount) string {
+	if a.IsZero() {
+		return ""
+	}
+	return a.String()
+}
+
+// formatRupiah menampilkan nominal dengan format Indonesia, mis. Rp1.250.000 atau Rp10.000,5.
+func formatRupiah(a money.Amount) string {
+	sign := ""
+	if a.IsNegative() {
+		sign = "-"
+		a = a.Neg()
+	}
+	intPart, frac, _ := strings.Cut(a.String(), ".")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteByte(',')
+		b.WriteString(frac)
+	}
+	return sign + "Rp" + b.String()
+}
+
+// ===== PDF =====
+//
+// PDF ditulis manual (PDF 1.4, font standar Helvetica) supaya tidak perlu dependensi
+// baru. Setiap halaman di-buffer lalu langsung ditulis, jadi memori yang dipakai
+// hanya sebesar satu halaman berapa pun panjang histori.
+
+const (
+	pdfPageWidth  = 595 // A4 dalam point
+	pdfPageHeight = 842
+	pdfMargin     = 40
+	pdfLineHeight = 13
+	pdfFontSize   = 8
+)
+
+// Lebar glyph Helvetica (per 1000 em) untuk karakter yang dipakai di kolom nominal.
+var helveticaWidths = map[rune]int{
+	'0': 556, '1': 556, '2': 556, '3': 556, '4': 556, '5': 556, '6': 556, '7': 556, '8': 556, '9': 556,
+	'.': 278, ',': 278, '-': 333, '+': 584, ' ': 278, 'R': 722, 'p': 556,
+}
+
+func textWidth(s string, size float64) float64 {
+	w := 0
+	for _, r := range s {
+		if cw, ok := helveticaWidths[r]; ok {
+			w += cw
+		} else {
+			w += 556
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+type pdfWriter struct {
+	w       io.Writer
+	written int64
+	offsets map[int]int64
+	err     error
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.written += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) beginObj(num int) {
+	p.offsets[num] = p.written
+	p.printf("%d 0 obj\n", num)
+}
+
+func (p *pdfWriter) endObj() { p.printf("endobj\n") }
+
+// pdfText meng-escape string PDF; karakter di luar ASCII diganti '?' karena font
+// standar hanya dijamin untuk WinAnsi.
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-1]) + "~"
+	}
+	return s
+}
+
+// pdfPage mengumpulkan content stream satu halaman.
+type pdfPage struct {
+	buf bytes.Buffer
+	y   float64
+}
+
+func (pg *pdfPage) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(&pg.buf, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfText(s))
+}
+
+func (pg *pdfPage) textRight(right, y float64, font string, size float64, s string) {
+	pg.text(right-textWidth(s, size), y, font, size, s)
+}
+
+func (pg *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&pg.buf, "%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// Posisi kolom tabel mutasi.
+const (
+	colDate   = pdfMargin
+	colType   = pdfMargin + 62
+	colDesc   = pdfMargin + 160
+	colAmount = pdfMargin + 420 // rata kanan
+	colSaldo  = pdfPageWidth - pdfMargin
+)
+
+// WriteStatementPDF menulis statement sebagai PDF A4.
+func WriteStatementPDF(ctx context.Context, w io.Writer, st *Statement) error {
+	p := &pdfWriter{w: w, offsets: make(map[int]int64)}
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// objek 1-4 tetap: catalog, pages, font regular, font bold. Halaman mulai dari 5.
+	p.beginObj(3)
+	p.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\n")
+	p.endObj()
+	p.beginObj(4)
+	p.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\n")
+	p.endObj()
+
+	var kids []int
+	next := 5
+	flush := func(pg *pdfPage) {
+		pageNum, contentNum := next, next+1
+		next += 2
+		kids = append(kids, pageNum)
+		pg.textRight(colSaldo, pdfMargin-16, "F1", pdfFontSize, fmt.Sprintf("Halaman %d", len(kids)))
+
+		p.beginObj(pageNum)
+		p.printf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\n",
+			pdfPageWidth, pdfPageHeight, contentNum)
+		p.endObj()
+		p.beginObj(contentNum)
+		p.printf("<< /Length %d >>\nstream\n", pg.buf.Len())
+		p.write(pg.buf.Bytes())
+		p.printf("\nendstream\n")
+		p.endObj()
+	}
+
+	h := st.Header
+	pg := &pdfPage{y: pdfPageHeight - pdfMargin}
+	writeStatementSummary(pg, h)
+	writeTableHeader(pg)
+
+	err := st.Lines(ctx, func(l StatementLine) error {
+		if pg.y < pdfMargin+pdfLineHeight {
+			flush(pg)
+			if p.err != nil {
+				return p.err
+			}
+			pg = &pdfPage{y: pdfPageHeight - pdfMargin}
+			writeTableHeader(pg)
+		}
+		amount := "+" + formatRupiah(l.Credit)
+		if l.Debit.IsPositive() {
+			amount = "-" + formatRupiah(l.Debit)
+		}
+		desc := l.Description
+		if desc == "" {
+			desc = l.Reference
+		}
+		pg.text(colDate, pg.y, "F1", pdfFontSize, l.Time.In(wib).Format("02/01/06 15:04"))
+		pg.text(colType, pg.y, "F1", pdfFontSize, truncate(l.Type, 22))
+		pg.text(colDesc, pg.y, "F1", pdfFontSize, truncate(desc, 44))
+		pg.textRight(colAmount, pg.y, "F1", pdfFontSize, amount)
+		pg.textRight(colSaldo, pg.y, "F1", pdfFontSize, formatRupiah(l.Balance.Total))
+		pg.y -= pdfLineHeight
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if pg.y < pdfMargin+2*pdfLineHeight {
+		flush(pg)
+		pg = &pdfPage{y: pdfPageHeight - pdfMargin}
+	}
+	pg.line(pdfMargin, pg.y+pdfLineHeight-3, colSaldo, pg.y+pdfLineHeight-3)
+	pg.text(colDesc, pg.y-2, "F2", pdfFontSize, "Saldo akhir")
+	pg.textRight(colSaldo, pg.y-2, "F2", pdfFontSize, formatRupiah(h.Closing.Total))
+	flush(pg)
+
+	p.beginObj(2)
+	p.printf("<< /Type /Pages /Count %d /Kids [", len(kids))
+	for _, k := range kids {
+		p.printf("%d 0 R ", k)
+	}
+	p.printf("] >>\n")
+	p.endObj()
+	p.beginObj(1)
+	p.printf("<< /Type /Catalog /Pages 2 0 R >>\n")
+	p.endObj()
+
+	xref := p.written
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", next)
+	for i := 1; i < next; i++ {
+		p.printf("%010d 00000 n \n", p.offsets[i])
+	}
+	p.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", next, xref)
+	return p.err
+}
+
+func writeStatementSummary(pg *pdfPage, h StatementHeader) {
+	pg.text(pdfMargin, pg.y, "F2", 14, "E-Statement Wallet")
+	pg.y -= 22
+	rows := [][2]string{
+		{"Nama", h.Name},
+		{"Email", h.Email},
+		{"Periode", fmt.Sprintf("%s s/d %s", h.From.In(wib).Format("02/01/2006"), h.To.Add(-time.Second).In(wib).Format("02/01/2006"))},
+		{"Dicetak", h.GeneratedAt.In(wib).Format("02/01/2006 15:04") + " WIB"},
+	}
+	for _, r := range rows {
+		pg.text(pdfMargin, pg.y, "F1", 9, r[0])
+		pg.text(pdfMargin+70, pg.y, "F1", 9, ": "+r[1])
+		pg.y -= pdfLineHeight
+	}
+	pg.y -= 6
+
+	summary := [][2]string{
+		{"Saldo awal", formatRupiah(h.Opening.Total)},
+		{"Total masuk", formatRupiah(h.TotalCredit)},
+		{"Total keluar", formatRupiah(h.TotalDebit)},
+		{"Saldo akhir", formatRupiah(h.Closing.Total)},
+		{"  Saldo top up", formatRupiah(h.Closing.Topup)},
+		{"  Saldo redeem", formatRupiah(h.Closing.Redeem)},
+	}
+	for _, r := range summary {
+		pg.text(pdfMargin, pg.y, "F1", 9, r[0])
+		pg.textRight(pdfMargin+220, pg.y, "F1", 9, r[1])
+		pg.y -= pdfLineHeight
+	}
+	pg.y -= 10
+}
+
+func writeTableHeader(pg *pdfPage) {
+	pg.text(colDate, pg.y, "F2", pdfFontSize, "Tanggal")
+	pg.text(colType, pg.y, "F2", pdfFontSize, "Tipe")
+	pg.text(colDesc, pg.y, "F2", pdfFontSize, "Keterangan")
+	pg.textRight(colAmount, pg.y, "F2", pdfFontSize, "Mutasi")
+	pg.textRight(colSaldo, pg.y, "F2", pdfFontSize, "Saldo")
+	pg.line(pdfMargin, pg.y-4, colSaldo, pg.y-4)
+	pg.y -= pdfLineHeight + 2
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// Format file e-statement.
+const (
+	StatementFormatCSV = "csv"
+	StatementFormatPDF = "pdf"
+)
+
+// StatementService menyusun e-statement bulanan: saldo awal, mutasi dan saldo akhir.
+// Saldo awal/akhir dihitung mundur dari wallet_summary dikurangi efek transaksi
+// setelah batas periode, sehingga tidak bergantung pada balance_after histori lama.
+type StatementService struct {
+	repo     repositories.StatementRepo
+	wallet   repositories.WalletRepo
+	validate *validator.Validate
+	now      func() time.Time
+}
+
+func NewStatementService(r repositories.StatementRepo, wallet repositories.WalletRepo, v *validator.Validate) *StatementService {
+	return &StatementService{repo: r, wallet: wallet, validate: v, now: time.Now}
+}
+
+type StatementHeader struct {
+	UserID      string
+	Name        string
+	Email       string
+	Period      string    // YYYY-MM
+	From        time.Time // inklusif
+	To          time.Time // eksklusif
+	GeneratedAt time.Time
+	Opening     BalanceAfterDTO
+	Closing     BalanceAfterDTO
+	TotalCredit money.Amount
+	TotalDebit  money.Amount
+}
+
+// StatementLine adalah satu baris mutasi beserta saldo berjalannya.
+type StatementLine struct {
+	Time        time.Time
+	Type        string
+	Description string
+	Reference   string
+	Credit      money.Amount
+	Debit       money.Amount
+	Balance     BalanceAfterDTO
+}
+
+// Statement memegang snapshot DB sampai Close dipanggil; baris mutasi dibaca
+// bertahap lewat Lines supaya histori besar tidak dimuat sekaligus ke memori.
+type Statement struct {
+	Header StatementHeader
+
+	repo repositories.StatementRepo
+	tx   *sql.Tx
+}
+
+// Open menyiapkan statement untuk bulan period (YYYY-MM, WIB); kosong = bulan berjalan.
+func (s *StatementService) Open(ctx context.Context, userID, period string) (*Statement, error) {
+	if err := s.validate.Var(userID, "required,uuid4"); err != nil {
+		return nil, ErrBadRequest{Err: err}
+	}
+	now := s.now()
+	from, to, err := statementPeriod(period, now)
+	if err != nil {
+		return nil, ErrBadRequest{Err: err}
+	}
+
+	user, err := s.wallet.GetUserProfile(ctx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrNotFoundResource{Msg: "user tidak ditemukan"}
+		}
+		return nil, err
+	}
+
+	// snapshot dipakai juga oleh stream yang berjalan setelah handler selesai,
+	// jadi tidak boleh ikut dibatalkan bersama context request
+	tx, err := s.repo.BeginSnapshot(context.WithoutCancel(ctx))
+	if err != nil {
+		return nil, err
+	}
+	st := &Statement{repo: s.repo, tx: tx}
+	st.Header = StatementHeader{
+		UserID:      userID,
+		Name:        user.Name,
+		Email:       user.Email,
+		Period:      from.Format("2006-01"),
+		From:        from,
+		To:          to,
+		GeneratedAt: now,
+	}
+	if err := st.computeBalances(ctx, userID); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return st, nil
+}
+
+func statementPeriod(period string, now time.Time) (time.Time, time.Time, error) {
+	from := startOfMonth(now)
+	if v := strings.TrimSpace(period); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, wib)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("month harus berformat YYYY-MM")
+		}
+		from = t
+	}
+	if from.After(now) {
+		return time.Time{}, time.Time{}, errors.New("periode statement belum berjalan")
+	}
+	to := from.AddDate(0, 1, 0)
+	if to.After(now) {
+		to = now
+	}
+	return from, to, nil
+}
+
+func (st *Statement) computeBalances(ctx context.Context, userID string) error {
+	topup, redeem, err := st.repo.GetBalances(ctx, st.tx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if !errors.As(err, &notFound) {
+			return err
+		}
+	}
+	sinceFrom, err := st.repo.SumTransactionsByTypeSince(ctx, st.tx, userID, st.Header.From)
+	if err != nil {
+		return err
+	}
+	sinceTo, err := st.repo.SumTransactionsByTypeSince(ctx, st.tx, userID, st.Header.To)
+	if err != nil {
+		return err
+	}
+
+	closeTopup, closeRedeem := topup, redeem
+	for tipe, sum := range sinceTo {
+		dTopup, dRedeem, err := transactionDelta(tipe, sum)
+		if err != nil {
+			return err
+		}
+		closeTopup, closeRedeem = closeTopup.Sub(dTopup), closeRedeem.Sub(dRedeem)
+	}
+	openTopup, openRedeem := closeTopup, closeRedeem
+	for tipe, sum := range sinceFrom {
+		inPeriod := sum.Sub(sinceTo[tipe])
+		dTopup, dRedeem, err := transactionDelta(tipe, inPeriod)
+		if err != nil {
+			return err
+		}
+		openTopup, openRedeem = openTopup.Sub(dTopup), openRedeem.Sub(dRedeem)
+		if delta := dTopup.Add(dRedeem); delta.IsPositive() {
+			st.Header.TotalCredit = st.Header.TotalCredit.Add(delta)
+		} else {
+			st.Header.TotalDebit = st.Header.TotalDebit.Sub(delta)
+		}
+	}
+
+	st.Header.Opening = BalanceAfterDTO{Total: openTopup.Add(openRedeem), Topup: openTopup, Redeem: openRedeem}
+	st.Header.Closing = BalanceAfterDTO{Total: closeTopup.Add(closeRedeem), Topup: closeTopup, Redeem: closeRedeem}
+	return nil
+}
+
+// Lines memanggil fn untuk setiap mutasi periode dari yang terlama.
+func (st *Statement) Lines(ctx context.Context, fn func(StatementLine) error) error {
+	topup, redeem := st.Header.Opening.Topup, st.Header.Opening.Redeem
+	return st.repo.ForEachTransaction(ctx, st.tx, st.Header.UserID, st.Header.From, st.Header.To, func(rec repositories.TransactionRecord) error {
+		dTopup, dRedeem, err := transactionDelta(rec.Type, rec.Amount)
+		if err != nil {
+			return err
+		}
+		topup, redeem = topup.Add(dTopup), redeem.Add(dRedeem)
+
+		line := StatementLine{
+			Time:        rec.CreatedAt,
+			Type:        rec.Type,
+			Description: rec.Description.String,
+			Reference:   rec.ReferenceID.String,
+			Balance:     BalanceAfterDTO{Total: topup.Add(redeem), Topup: topup, Redeem: redeem},
+		}
+		if delta := dTopup.Add(dRedeem); delta.IsNegative() {
+			line.Debit = delta.Neg()
+		} else {
+			line.Credit = delta
+		}
+		return fn(line)
+	})
+}
+
+// Close melepas snapshot DB. Aman dipanggil lebih dari sekali.
+func (st *Statement) Close() error {
+	if err := st.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// FileName adalah nama file unduhan untuk format yang diminta.
+func (st *Statement) FileName(format string) string {
+	id := st.Header.UserID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("e-statement-%s-%s.%s", id, st.Header.Period, format)
+}
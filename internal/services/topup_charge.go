@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+)
+
+// ChargeTopUpInput membuat top up lewat Core API sehingga aplikasi bisa menampilkan
+// nomor VA atau QR sendiri tanpa halaman Snap.
+type ChargeTopUpInput struct {
+	TopUpInput
+	Channel string `json:"channel" validate:"required,oneof=BANK_TRANSFER QRIS GOPAY"`
+	Bank    string `json:"bank"    validate:"required_if=Channel BANK_TRANSFER,omitempty,oneof=bca bni bri cimb permata mandiri"`
+}
+
+// PaymentInstructions adalah data bayar per channel yang disimpan di
+// payment_orders.channel_data. Field yang tidak relevan untuk channel-nya kosong.
+type PaymentInstructions struct {
+	Bank        string `json:"bank,omitempty"`
+	VANumber    string `json:"vaNumber,omitempty"`
+	BillerCode  string `json:"billerCode,omitempty"` // Mandiri bill payment
+	BillKey     string `json:"billKey,omitempty"`
+	QRString    string `json:"qrString,omitempty"`
+	QRImageURL  string `json:"qrImageUrl,omitempty"`
+	DeeplinkURL string `json:"deeplinkUrl,omitempty"` // GoPay
+}
+
+type ChargeTopUpResult struct {
+	OrderID      string              `json:"orderId"`
+	Channel      string              `json:"channel"`
+	Status       string              `json:"status"`
+	ExpiresAt    time.Time           `json:"expiresAt"`
+	Instructions PaymentInstructions `json:"instructions"`
+}
+
+// ChargeTopUp membuat charge VA, QRIS atau GoPay. Settlement, pembatalan, kedaluwarsa
+// dan rekonsiliasinya memakai jalur yang sama dengan order Snap.
+func (s *WalletService) ChargeTopUp(ctx context.Context, in ChargeTopUpInput) (ChargeTopUpResult, error) {
+	in.Channel = strings.ToUpper(strings.TrimSpace(in.Channel))
+	in.Bank = strings.ToLower(strings.TrimSpace(in.Bank))
+	if err := s.validate.Struct(in); err != nil {
+		return ChargeTopUpResult{}, ErrBadRequest{Err: err}
+	}
+	if in.Channel != repositories.PaymentChannelBankTransfer {
+		in.Bank = ""
+	}
+	gw, err := s.gateways.ForChannel(in.Channel)
+	if err != nil {
+		return ChargeTopUpResult{}, err
+	}
+	customer, err := s.prepareTopUp(ctx, in.TopUpInput)
+	if err != nil {
+		return ChargeTopUpResult{}, err
+	}
+
+	orderID := newTopUpOrderID()
+	now := s.now()
+	expiry := s.cfg.PendingTopUp.expiry()
+	if err := s.openTopUpOrder(ctx, repositories.CreatePaymentOrderParams{
+		UserID:      in.UserID,
+		OrderID:     orderID,
+		GrossAmount: in.Jumlah,
+		ExpiresAt:   now.Add(expiry),
+		Channel:     in.Channel,
+		Provider:    gw.Name(),
+	}, now); err != nil {
+		return ChargeTopUpResult{}, err
+	}
+
+	instructions, err := gw.Charge(ctx, ChargeRequest{
+		OrderID:   orderID,
+		Channel:   in.Channel,
+		Bank:      in.Bank,
+		Amount:    in.Jumlah,
+		Customer:  customer,
+		StartAt:   now,
+		ExpiresIn: expiry,
+	})
+	if err != nil {
+		if fErr := s.failTopUpOrder(ctx, orderID); fErr != nil {
+			return ChargeTopUpResult{}, errors.Join(err, fErr)
+		}
+		if isPermanent(err) {
+			return ChargeTopUpResult{}, ErrConflict{Msg: "channel pembayaran ditolak payment gateway: " + err.Error()}
+		}
+		return ChargeTopUpResult{}, err
+	}
+
+	data, err := json.Marshal(instructions)
+	if err != nil {
+		return ChargeTopUpResult{}, err
+	}
+	if err := s.repo.SetPaymentOrderCheckout(ctx, repositories.PaymentOrderCheckoutParams{
+		OrderID:     orderID,
+		ChannelData: data,
+	}); err != nil {
+		return ChargeTopUpResult{}, err
+	}
+
+	return ChargeTopUpResult{
+		OrderID:      orderID,
+		Channel:      in.Channel,
+		Status:       "PENDING",
+		ExpiresAt:    now.Add(expiry),
+		Instructions: instructions,
+	}, nil
+}
+
+// paymentInstructions membaca ulang channel_data; order Snap tidak punya instruksi.
+func paymentInstructions(rec repositories.PaymentOrderRecord) *PaymentInstructions {
+	if !rec.ChannelData.Valid {
+		return nil
+	}
+	var out PaymentInstructions
+	if err := json.Unmarshal([]byte(rec.ChannelData.String), &out); err != nil {
+		return nil
+	}
+	return &out
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	pendingTopUpBatchSize = 100
+	topUpExpiryBatchSize  = 200
+	defaultTopUpExpiry    = 24 * time.Hour
+)
+
+// PendingTopUpConfig mengatur masa berlaku order top up dan polling order yang
+// notifikasinya tidak datang.
+type PendingTopUpConfig struct {
+	Expiry      time.Duration // batas waktu bayar yang dikirim ke payment gateway
+	ExpiryGrace time.Duration // jeda setelah expires_at sebelum order ditandai EXPIRED
+	StaleAfter  time.Duration // order PENDING lebih tua dari ini ditanyakan ke gateway-nya
+	MaxAge      time.Duration // order yang lebih tua dari ini tidak lagi dipolling
+}
+
+func (c PendingTopUpConfig) expiry() time.Duration {
+	if c.Expiry <= 0 {
+		return defaultTopUpExpiry
+	}
+	return c.Expiry
+}
+
+// TopUpReconcileReport merangkum satu putaran ReconcilePendingTopUps.
+type TopUpReconcileReport struct {
+	Checked  int // order yang statusnya ditanyakan
+	Updated  int // order yang statusnya berubah (termasuk yang saldonya masuk)
+	NotFound int // belum ada transaksi di payment gateway
+}
+
+// ReconcilePendingTopUps menanyakan status order top up PENDING yang sudah basi ke
+// gateway yang tercatat di order dan menerapkannya lewat jalur yang sama dengan notifikasi. Satu order yang
+// gagal tidak menghentikan order lain; error-nya dikembalikan di akhir.
+func (s *WalletService) ReconcilePendingTopUps(ctx context.Context) (TopUpReconcileReport, error) {
+	var report TopUpReconcileReport
+	if s.gateways.Len() == 0 {
+		return report, fmt.Errorf("payment gateway belum dikonfigurasi")
+	}
+	now := s.now()
+	cfg := s.cfg.PendingTopUp
+	orders, err := s.repo.ListStalePendingOrders(ctx, now.Add(-cfg.MaxAge), now.Add(-cfg.StaleAfter), pendingTopUpBatchSize)
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		report.Checked++
+		// dicatat apa pun hasilnya agar putaran berikutnya mendahulukan order lain
+		if err := s.repo.MarkPaymentOrderPolled(ctx, order.OrderID, now); err != nil {
+			errs = append(errs, fmt.Errorf("poll order %s: %w", order.OrderID, err))
+		}
+		gw, err := s.gateways.Get(order.Provider)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("status order %s: %w", order.OrderID, err))
+			continue
+		}
+		update, err := gw.GetStatus(ctx, order.OrderID)
+		if errors.Is(err, ErrPaymentNotFound) {
+			report.NotFound++
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("status order %s: %w", order.OrderID, err))
+			continue
+		}
+		if update.Status == "PENDING" {
+			continue
+		}
+		if err := s.applyPaymentStatus(ctx, order.Provider, update); err != nil {
+			errs = append(errs, fmt.Errorf("apply order %s: %w", order.OrderID, err))
+			continue
+		}
+		report.Updated++
+	}
+	return report, errors.Join(errs...)
+}
+
+// TopUpExpiryReport merangkum satu putaran ExpirePendingTopUps.
+type TopUpExpiryReport struct {
+	Checked int // order lewat batas bayar yang ditanyakan ke gateway
+	Expired int // ditandai EXPIRED
+	Updated int // ternyata sudah final di gateway (termasuk yang saldonya masuk)
+}
+
+// ExpirePendingTopUps menangani order PENDING yang sudah lewat batas bayar ditambah
+// ExpiryGrace. Status tiap order ditanyakan dulu ke gateway-nya: order hanya ditandai
+// EXPIRED bila gateway tidak punya transaksinya atau ikut menyatakan kedaluwarsa, dan
+// pembayaran yang notifikasinya hilang dibukukan lewat applyPaymentStatus. Order yang
+// statusnya belum bisa dipastikan dibiarkan PENDING untuk putaran berikutnya.
+func (s *WalletService) ExpirePendingTopUps(ctx context.Context) (TopUpExpiryReport, error) {
+	var report TopUpExpiryReport
+	if s.gateways.Len() == 0 {
+		return report, fmt.Errorf("payment gateway belum dikonfigurasi")
+	}
+	now := s.now()
+	before := now.Add(-s.cfg.PendingTopUp.ExpiryGrace)
+	// satu batch per putaran; order yang dilewati kembali setelah order lain dipolling
+	orders, err := s.repo.ListExpiredPendingOrders(ctx, before, topUpExpiryBatchSize)
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		report.Checked++
+		if err := s.repo.MarkPaymentOrderPolled(ctx, order.OrderID, now); err != nil {
+			errs = append(errs, fmt.Errorf("poll order %s: %w", order.OrderID, err))
+		}
+		gw, err := s.gateways.Get(order.Provider)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("status order %s: %w", order.OrderID, err))
+			continue
+		}
+		update, err := gw.GetStatus(ctx, order.OrderID)
+		switch {
+		case errors.Is(err, ErrPaymentNotFound):
+			// user tidak pernah memilih metode bayar; gateway tidak akan menerima dana
+			ok, err := s.repo.ExpirePaymentOrder(ctx, order.OrderID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("expire order %s: %w", order.OrderID, err))
+				continue
+			}
+			if ok {
+				report.Expired++
+			}
+		case err != nil:
+			errs = append(errs, fmt.Errorf("status order %s: %w", order.OrderID, err))
+		case update.Status == "PENDING":
+			// gateway belum menutup transaksinya; dana masih mungkin masuk
+		default:
+			if err := s.applyPaymentStatus(ctx, order.Provider, update); err != nil {
+				errs = append(errs, fmt.Errorf("apply order %s: %w", order.OrderID, err))
+				continue
+			}
+			if update.Status == "EXPIRED" {
+				report.Expired++
+			} else {
+				report.Updated++
+			}
+		}
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// TopUpRefundService mengembalikan top up yang sudah settlement ke pembayar lewat
+// refund di payment gateway order tersebut. Saldo top up didebit lebih dulu agar tidak
+// bisa dipakai selama refund diproses; bila gateway menolak refund, debit itu dibalik sebagai
+// pembalikan transaksi REFUND_TOPUP. ev_poin dari top up tidak ikut ditarik.
+type TopUpRefundService struct {
+	repo      repositories.WalletRepo
+	reversals *ReversalService
+	ledger    *LedgerService
+	limits    *LimitService
+	gateways  *PaymentGateways
+	validate  *validator.Validate
+	now       func() time.Time
+}
+
+func NewTopUpRefundService(r repositories.WalletRepo, reversals *ReversalService, ledger *LedgerService, limits *LimitService, gateways *PaymentGateways, v *validator.Validate) *TopUpRefundService {
+	return &TopUpRefundService{repo: r, reversals: reversals, ledger: ledger, limits: limits, gateways: gateways, validate: v, now: time.Now}
+}
+
+type RefundTopUpInput struct {
+	OrderID     string `json:"-"      validate:"required,max=64"`
+	Reason      string `json:"reason" validate:"required,max=500"`
+	RequestedBy string `json:"-"`
+}
+
+type TopUpRefundDTO struct {
+	OrderID       string       `json:"orderId"`
+	UserID        string       `json:"userId"`
+	Amount        money.Amount `json:"amount"`
+	Status        string       `json:"status"`
+	TransactionID string       `json:"transactionId,omitempty"`
+	Reason        string       `json:"reason,omitempty"`
+	RequestedBy   *string      `json:"requestedBy,omitempty"`
+	RequestedAt   *time.Time   `json:"requestedAt,omitempty"`
+	RefundedAt    *time.Time   `json:"refundedAt,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+func toTopUpRefundDTO(rec repositories.PaymentOrderRecord) TopUpRefundDTO {
+	dto := TopUpRefundDTO{
+		OrderID:       rec.OrderID,
+		UserID:        rec.UserID,
+		Amount:        rec.GrossAmount,
+		Status:        rec.RefundStatus.String,
+		TransactionID: rec.RefundTransactionID.String,
+		Reason:        rec.RefundReason.String,
+		Error:         rec.RefundError.String,
+	}
+	if rec.RefundRequestedBy.Valid {
+		by := rec.RefundRequestedBy.String
+		dto.RequestedBy = &by
+	}
+	if rec.RefundRequestedAt.Valid {
+		t := rec.RefundRequestedAt.Time
+		dto.RequestedAt = &t
+	}
+	if rec.RefundedAt.Valid {
+		t := rec.RefundedAt.Time
+		dto.RefundedAt = &t
+	}
+	return dto
+}
+
+// Refund merefund penuh satu order top up. Refund yang masih PENDING (hasil panggilan
+// sebelumnya tidak diketahui) dikirim ulang dengan refund_key yang sama.
+func (s *TopUpRefundService) Refund(ctx context.Context, in RefundTopUpInput) (TopUpRefundDTO, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if err := s.validate.Struct(in); err != nil {
+		return TopUpRefundDTO{}, ErrBadRequest{Err: err}
+	}
+	order, err := s.repo.GetPaymentOrder(ctx, in.OrderID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return TopUpRefundDTO{}, ErrNotFoundResource{Msg: "order tidak ditemukan"}
+		}
+		return TopUpRefundDTO{}, err
+	}
+	gw, err := s.gateways.Get(order.Provider)
+	if err != nil {
+		return TopUpRefundDTO{}, err
+	}
+	if order.RefundStatus.String != repositories.TopUpRefundPending {
+		if order, err = s.debit(ctx, order.OrderID, in); err != nil {
+			return TopUpRefundDTO{}, err
+		}
+	}
+	return s.send(ctx, gw, order)
+}
+
+// debit mendebit saldo top up user dan menandai refund PENDING dalam satu transaksi.
+func (s *TopUpRefundService) debit(ctx context.Context, orderID string, in RefundTopUpInput) (repositories.PaymentOrderRecord, error) {
+	var none repositories.PaymentOrderRecord
+	order, err := s.repo.GetPaymentOrder(ctx, orderID)
+	if err != nil {
+		return none, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return none, err
+	}
+	defer tx.Rollback()
+
+	// urutan lock sama dengan alur saldo lain: wallet dulu, baru order
+	_, topup, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, order.UserID)
+	if err != nil {
+		return none, err
+	}
+	order, err = s.repo.GetPaymentOrderForUpdate(ctx, tx, orderID)
+	if err != nil {
+		return none, err
+	}
+	if err := refundable(order); err != nil {
+		return none, err
+	}
+
+	heldTopup, _, err := s.repo.GetHeldTx(ctx, tx, order.UserID)
+	if err != nil {
+		return none, err
+	}
+	if topup.Sub(heldTopup).Cmp(order.GrossAmount) < 0 {
+		return none, ErrInsufficientBalance{Msg: "saldo top up tersedia tidak mencukupi untuk refund"}
+	}
+
+	now := s.now()
+	desc := "Refund top up " + order.OrderID
+	txnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
+		UserID:        order.UserID,
+		TipeTransaksi: "REFUND_TOPUP",
+		Jumlah:        order.GrossAmount,
+		Deskripsi:     desc,
+		ReferensiID:   &order.OrderID,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return none, err
+	}
+
+	// saldo top up user kembali ke clearing Midtrans, lalu keluar ke pembayar
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     "REFUND_TOPUP",
+		TransactionID: &txnID,
+		ReferensiID:   &order.OrderID,
+		Deskripsi:     desc,
+		Postings: []LedgerPosting{
+			ledgerDebit(order.UserID, repositories.LedgerAccountUserTopup, order.GrossAmount),
+			ledgerCredit("", repositories.LedgerAccountMidtransClearing, order.GrossAmount),
+		},
+		CreatedAt: now,
+	}); err != nil {
+		return none, err
+	}
+
+	if err := s.limits.Release(ctx, tx, order.UserID, LimitOpTopUp, order.OrderID, now); err != nil {
+		return none, err
+	}
+
+	key := "RF-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	var requestedBy *string
+	if in.RequestedBy != "" {
+		requestedBy = &in.RequestedBy
+	}
+	if _, err := s.repo.UpdatePaymentOrderRefundTx(ctx, tx, repositories.UpdatePaymentOrderRefundParams{
+		OrderID:       order.OrderID,
+		Status:        repositories.TopUpRefundPending,
+		Key:           &key,
+		Reason:        &in.Reason,
+		TransactionID: &txnID,
+		RequestedBy:   requestedBy,
+		RequestedAt:   &now,
+	}); err != nil {
+		return none, err
+	}
+	if err := tx.Commit(); err != nil {
+		return none, err
+	}
+	return s.repo.GetPaymentOrder(ctx, orderID)
+}
+
+// refundable memastikan saldo top up order masih bisa dikembalikan ke pembayar.
+// Top up yang transaksinya sudah dibalik admin tidak bisa direfund karena saldonya
+// sudah ditarik lewat pembalikan itu.
+func refundable(order repositories.PaymentOrderRecord) error {
+	if order.Status != "SETTLEMENT" || !order.BalanceApplied {
+		return ErrConflict{Msg: "hanya top up yang sudah settlement yang bisa direfund"}
+	}
+	if order.ReversedAt.Valid {
+		return ErrConflict{Msg: "transaksi top up order sudah dibalik"}
+	}
+	switch order.RefundStatus.String {
+	case repositories.TopUpRefundRefunded:
+		return ErrConflict{Msg: "order sudah direfund"}
+	case repositories.TopUpRefundPending:
+		return ErrConflict{Msg: "refund order sedang diproses"}
+	}
+	return nil
+}
+
+// refundRejected bernilai true bila gateway pasti tidak memproses refund sehingga
+// debitnya boleh dikompensasi. Error lain (timeout, 5xx) bisa berarti refund sudah
+// diproses, jadi refund dibiarkan PENDING.
+func refundRejected(err error) bool {
+	return errors.Is(err, ErrPaymentNotFound) || isPermanent(err)
+}
+
+// compensable bernilai true bila refund order masih PENDING dengan key yang sama;
+// refund yang sudah diselesaikan request lain tidak boleh dikompensasi lagi.
+func compensable(order repositories.PaymentOrderRecord, key string) bool {
+	return order.RefundStatus.String == repositories.TopUpRefundPending && order.RefundKey.String == key
+}
+
+// send memanggil refund gateway untuk order yang refund-nya PENDING. Penolakan
+// permanen dikompensasi; gagal sementara dibiarkan PENDING untuk dicoba lagi.
+func (s *TopUpRefundService) send(ctx context.Context, gw PaymentGateway, order repositories.PaymentOrderRecord) (TopUpRefundDTO, error) {
+	err := gw.Refund(ctx, order.OrderID, PaymentRefund{
+		Key:    order.RefundKey.String,
+		Amount: order.GrossAmount,
+		Reason: order.RefundReason.String,
+	})
+
+	switch {
+	case err == nil:
+		now := s.now()
+		ok, err := s.repo.UpdatePaymentOrderRefund(ctx, repositories.UpdatePaymentOrderRefundParams{
+			OrderID:    order.OrderID,
+			PendingKey: order.RefundKey.String,
+			Status:     repositories.TopUpRefundRefunded,
+			RefundedAt: &now,
+		})
+		if err != nil {
+			return TopUpRefundDTO{}, err
+		}
+		if !ok {
+			// request lain sudah menyelesaikan refund ini lebih dulu
+			return s.current(ctx, order.OrderID)
+		}
+		order.RefundStatus.String = repositories.TopUpRefundRefunded
+		order.RefundedAt = sql.NullTime{Time: now, Valid: true}
+		order.RefundError.String = ""
+		return toTopUpRefundDTO(order), nil
+	case refundRejected(err):
+		if err := s.compensate(ctx, order.OrderID, order.RefundKey.String, err.Error()); err != nil {
+			return TopUpRefundDTO{}, err
+		}
+		return TopUpRefundDTO{}, ErrConflict{Msg: "refund ditolak payment gateway, saldo top up dikembalikan: " + err.Error()}
+	default:
+		msg := err.Error()
+		if _, uErr := s.repo.UpdatePaymentOrderRefund(ctx, repositories.UpdatePaymentOrderRefundParams{
+			OrderID:    order.OrderID,
+			PendingKey: order.RefundKey.String,
+			Status:     repositories.TopUpRefundPending,
+			Error:      &msg,
+		}); uErr != nil {
+			return TopUpRefundDTO{}, uErr
+		}
+		return TopUpRefundDTO{}, fmt.Errorf("refund %s belum terkonfirmasi payment gateway, ulangi request untuk mencoba lagi: %w", order.OrderID, err)
+	}
+}
+
+// compensate membalik debit refund dan menandai refund FAILED. Order yang refund-nya
+// sudah diselesaikan request lain (key berbeda atau bukan PENDING) dibiarkan.
+func (s *TopUpRefundService) compensate(ctx context.Context, orderID, key, reason string) error {
+	order, err := s.repo.GetPaymentOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, order.UserID); err != nil {
+		return err
+	}
+	order, err = s.repo.GetPaymentOrderForUpdate(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if !compensable(order, key) {
+		return tx.Commit()
+	}
+	if !order.RefundTransactionID.Valid {
+		return fmt.Errorf("refund order %s tidak terhubung ke transaksi", orderID)
+	}
+
+	orig, err := s.reversals.repo.GetTransactionForUpdate(ctx, tx, order.RefundTransactionID.String)
+	if err != nil {
+		return err
+	}
+	if _, err := s.reversals.reverse(ctx, tx, orig, "Refund top up gagal: "+reason, nil); err != nil {
+		// sudah dibalik manual oleh admin; saldo tidak boleh dikembalikan lagi
+		var conflict ErrConflict
+		if !errors.As(err, &conflict) {
+			return err
+		}
+	} else if err := s.limits.Restore(ctx, tx, order.UserID, LimitOpTopUp, order.OrderID); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.UpdatePaymentOrderRefundTx(ctx, tx, repositories.UpdatePaymentOrderRefundParams{
+		OrderID: orderID,
+		Status:  repositories.TopUpRefundFailed,
+		Error:   &reason,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *TopUpRefundService) current(ctx context.Context, orderID string) (TopUpRefundDTO, error) {
+	order, err := s.repo.GetPaymentOrder(ctx, orderID)
+	if err != nil {
+		return TopUpRefundDTO{}, err
+	}
+	return toTopUpRefundDTO(order), nil
+}
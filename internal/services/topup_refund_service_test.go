@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+func TestRefundable(t *testing.T) {
+	settled := repositories.PaymentOrderRecord{Status: "SETTLEMENT", BalanceApplied: true}
+	withRefund := func(status string) repositories.PaymentOrderRecord {
+		o := settled
+		o.RefundStatus = sql.NullString{String: status, Valid: true}
+		return o
+	}
+	reversed := settled
+	reversed.ReversedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name  string
+		order repositories.PaymentOrderRecord
+		ok    bool
+	}{
+		{"settlement", settled, true},
+		{"refund sebelumnya gagal", withRefund(repositories.TopUpRefundFailed), true},
+		{"masih pending", repositories.PaymentOrderRecord{Status: "PENDING"}, false},
+		{"settlement belum dibukukan", repositories.PaymentOrderRecord{Status: "SETTLEMENT"}, false},
+		{"transaksi top up sudah dibalik", reversed, false},
+		{"refund sedang diproses", withRefund(repositories.TopUpRefundPending), false},
+		{"sudah direfund", withRefund(repositories.TopUpRefundRefunded), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := refundable(tt.order)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("refundable = %v, want nil", err)
+				}
+				return
+			}
+			var conflict ErrConflict
+			if !errors.As(err, &conflict) {
+				t.Fatalf("refundable = %v, want ErrConflict", err)
+			}
+		})
+	}
+}
+
+func TestCompensable(t *testing.T) {
+	order := repositories.PaymentOrderRecord{
+		RefundStatus: sql.NullString{String: repositories.TopUpRefundPending, Valid: true},
+		RefundKey:    sql.NullString{String: "RF-1", Valid: true},
+	}
+	if !compensable(order, "RF-1") {
+		t.Error("refund PENDING dengan key yang sama harus dikompensasi")
+	}
+	if compensable(order, "RF-2") {
+		t.Error("refund dengan key lain tidak boleh dikompensasi")
+	}
+	order.RefundStatus.String = repositories.TopUpRefundRefunded
+	if compensable(order, "RF-1") {
+		t.Error("refund yang sudah selesai tidak boleh dikompensasi")
+	}
+}
+
+// refundTestRepo hanya mengimplementasikan method yang dipakai send/compensate;
+// method lain panic karena WalletRepo yang di-embed nil.
+type refundTestRepo struct {
+	repositories.WalletRepo
+	order   repositories.PaymentOrderRecord
+	updates []repositories.UpdatePaymentOrderRefundParams
+	begins  int
+}
+
+var errTestBegin = errors.New("begin tx")
+
+func (r *refundTestRepo) GetPaymentOrder(ctx context.Context, orderID string) (repositories.PaymentOrderRecord, error) {
+	return r.order, nil
+}
+
+func (r *refundTestRepo) UpdatePaymentOrderRefund(ctx context.Context, p repositories.UpdatePaymentOrderRefundParams) (bool, error) {
+	r.updates = append(r.updates, p)
+	return true, nil
+}
+
+func (r *refundTestRepo) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	// transaksi kompensasi cukup dicatat; isinya butuh database
+	r.begins++
+	return nil, errTestBegin
+}
+
+type refundTestGateway struct {
+	PaymentGateway
+	err error
+}
+
+func (g refundTestGateway) Refund(ctx context.Context, orderID string, r PaymentRefund) error {
+	return g.err
+}
+
+func TestRefundSendOutcome(t *testing.T) {
+	tests := []struct {
+		name       string
+		gatewayErr error
+		wantStatus string // status refund yang ditulis tanpa transaksi; kosong bila tidak ada
+		compensate bool
+	}{
+		{"berhasil", nil, repositories.TopUpRefundRefunded, false},
+		{"transaksi tidak ditemukan", fmt.Errorf("refund: %w", ErrPaymentNotFound), "", true},
+		{"ditolak midtrans", &MidtransError{StatusCode: http.StatusPreconditionFailed, Message: "cannot be refunded"}, "", true},
+		{"timeout", context.DeadlineExceeded, repositories.TopUpRefundPending, false},
+		{"midtrans 5xx", &MidtransError{StatusCode: http.StatusInternalServerError}, repositories.TopUpRefundPending, false},
+		{"midtrans 429", &MidtransError{StatusCode: http.StatusTooManyRequests}, repositories.TopUpRefundPending, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := repositories.PaymentOrderRecord{
+				OrderID:      "TOPUP-1",
+				UserID:       limitTestUser,
+				GrossAmount:  money.FromRupiah(50_000),
+				Status:       "SETTLEMENT",
+				RefundStatus: sql.NullString{String: repositories.TopUpRefundPending, Valid: true},
+				RefundKey:    sql.NullString{String: "RF-1", Valid: true},
+			}
+			repo := &refundTestRepo{order: order}
+			svc := &TopUpRefundService{repo: repo, now: time.Now}
+
+			_, err := svc.send(context.Background(), refundTestGateway{err: tt.gatewayErr}, order)
+
+			if tt.compensate {
+				if repo.begins != 1 || !errors.Is(err, errTestBegin) {
+					t.Fatalf("kompensasi tidak dijalankan: begins=%d err=%v", repo.begins, err)
+				}
+				if len(repo.updates) != 0 {
+					t.Fatalf("refund yang ditolak tidak boleh ditandai tanpa kompensasi: %+v", repo.updates)
+				}
+				return
+			}
+			if repo.begins != 0 {
+				t.Fatalf("kompensasi tidak boleh dijalankan untuk %q", tt.name)
+			}
+			if len(repo.updates) != 1 {
+				t.Fatalf("updates = %d, want 1", len(repo.updates))
+			}
+			got := repo.updates[0]
+			if got.Status != tt.wantStatus || got.PendingKey != "RF-1" {
+				t.Fatalf("update = %+v, want status %s dengan PendingKey RF-1", got, tt.wantStatus)
+			}
+			if tt.gatewayErr == nil && err != nil {
+				t.Fatalf("send = %v", err)
+			}
+			if tt.gatewayErr != nil && (err == nil || got.Error == nil) {
+				t.Fatalf("refund yang belum pasti harus mengembalikan error dan mencatatnya: err=%v", err)
+			}
+		})
+	}
+}
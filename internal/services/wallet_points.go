@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// PointsRedeemConfig mengatur penukaran ev_poin ke saldo redeem.
+type PointsRedeemConfig struct {
+	RupiahPerPoint money.Amount // nilai satu poin; nol = penukaran dimatikan
+	MinPoints      int          // minimal poin per penukaran
+}
+
+type RedeemPointsInput struct {
+	UserID string `json:"userId" validate:"required,uuid4"`
+	Poin   int    `json:"poin"   validate:"required,gt=0"`
+}
+
+type RedeemPointsResult struct {
+	RedeemID      string       `json:"redeemId"`
+	PoinDitukar   int          `json:"poinDitukar"`
+	Amount        money.Amount `json:"amount"`
+	SisaPoin      int          `json:"sisaPoin"`
+	SaldoRedeem   money.Amount `json:"saldoRedeem"`
+	TransactionID string       `json:"transactionId"`
+}
+
+// RedeemPoints membakar ev_poin dan menambah saldo redeem sebesar poin x kurs
+// dalam satu transaksi DB.
+func (s *WalletService) RedeemPoints(ctx context.Context, in RedeemPointsInput) (RedeemPointsResult, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return RedeemPointsResult{}, ErrBadRequest{Err: err}
+	}
+	cfg := s.cfg.Points
+	if !cfg.RupiahPerPoint.IsPositive() {
+		return RedeemPointsResult{}, ErrConflict{Msg: "penukaran poin sedang tidak tersedia"}
+	}
+	if in.Poin < cfg.MinPoints {
+		return RedeemPointsResult{}, ErrBadRequest{Err: fmt.Errorf("minimal penukaran %d poin", cfg.MinPoints)}
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return RedeemPointsResult{}, err
+	}
+	defer tx.Rollback()
+
+	_, _, redeem, poin, err := s.repo.GetSaldoForUpdate(ctx, tx, in.UserID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return RedeemPointsResult{}, ErrNotFoundResource{Msg: "wallet tidak ditemukan"}
+		}
+		return RedeemPointsResult{}, err
+	}
+	if err := ensureWalletAllows(ctx, s.repo, tx, in.UserID, walletDebit); err != nil {
+		return RedeemPointsResult{}, err
+	}
+	if poin < in.Poin {
+		return RedeemPointsResult{}, ErrInsufficientBalance{Msg: "ev_poin tidak mencukupi"}
+	}
+
+	now := s.now()
+	amount := cfg.RupiahPerPoint.MulInt(int64(in.Poin))
+	redeemID := fmt.Sprintf("PTS-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	desc := fmt.Sprintf("Tukar %d ev_poin", in.Poin)
+
+	txnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
+		UserID:        in.UserID,
+		TipeTransaksi: "TUKAR_POIN",
+		Jumlah:        amount,
+		Deskripsi:     desc,
+		ReferensiID:   &redeemID,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return RedeemPointsResult{}, err
+	}
+
+	// Nilai poin didanai akun points funding dan masuk ke saldo redeem user.
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     "TUKAR_POIN",
+		TransactionID: &txnID,
+		ReferensiID:   &redeemID,
+		Deskripsi:     desc,
+		Postings: []LedgerPosting{
+			ledgerDebit("", repositories.LedgerAccountPointsFunding, amount),
+			ledgerCredit(in.UserID, repositories.LedgerAccountUserRedeem, amount),
+		},
+		CreatedAt: now,
+	}); err != nil {
+		return RedeemPointsResult{}, err
+	}
+
+	if err := s.lots.Credit(ctx, tx, LotCredit{
+		UserID:     in.UserID,
+		Kind:       repositories.LotKindRedeem,
+		Quantity:   amount,
+		SourceType: "TUKAR_POIN",
+		SourceID:   redeemID,
+		CreatedAt:  now,
+	}); err != nil {
+		return RedeemPointsResult{}, err
+	}
+
+	if err := s.points.Spend(ctx, tx, in.Poin, PointsEvent{
+		UserID:        in.UserID,
+		EventType:     PointsEventRedeem,
+		Amount:        amount,
+		SourceID:      redeemID,
+		TransactionID: &txnID,
+		Deskripsi:     desc,
+		OccurredAt:    now,
+	}); err != nil {
+		return RedeemPointsResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RedeemPointsResult{}, err
+	}
+
+	return RedeemPointsResult{
+		RedeemID:      redeemID,
+		PoinDitukar:   in.Poin,
+		Amount:        amount,
+		SisaPoin:      poin - in.Poin,
+		SaldoRedeem:   redeem.Add(amount),
+		TransactionID: txnID,
+	}, nil
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,65 +13,101 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
 )
 
 type WalletService struct {
-	repo     repositories.WalletRepo
-	validate *validator.Validate
-	now      func() time.Time
-	snapClient        *SnapClient
-	irisClient        *IrisClient
-	midtransServerKey string
-	callbackToken     string
+	repo          repositories.WalletRepo
+	ledger        *LedgerService
+	points        *PointsService
+	lots          *LotService
+	limits        *LimitService
+	beneficiaries repositories.BeneficiaryRepo
+	validate      *validator.Validate
+	now           func() time.Time
+	gateways      *PaymentGateways
+	cfg           WalletConfig
 }
 
-func NewWalletService(r repositories.WalletRepo, v *validator.Validate, snap *SnapClient, iris *IrisClient, serverKey, callbackToken string) *WalletService {
-	return &WalletService{
-		repo:              r,
-		validate:          v,
-		now:               time.Now,
-		snapClient:        snap,
-		irisClient:        iris,
-		midtransServerKey: serverKey,
-		callbackToken:     callbackToken,
-	}
+// WalletConfig berisi aturan bisnis wallet yang bisa diatur lewat env.
+type WalletConfig struct {
+	Transfer     TransferLimits
+	Points       PointsRedeemConfig
+	PendingTopUp PendingTopUpConfig
+	Payouts      PayoutRouting // provider yang dicatat di payout tarik saldo baru
 }
 
-func (s *WalletService) CallbackToken() string {
-	return s.callbackToken
+func NewWalletService(r repositories.WalletRepo, ledger *LedgerService, points *PointsService, lots *LotService, limits *LimitService, beneficiaries repositories.BeneficiaryRepo, v *validator.Validate, gateways *PaymentGateways, cfg WalletConfig) *WalletService {
+	return &WalletService{
+		repo:          r,
+		ledger:        ledger,
+		points:        points,
+		lots:          lots,
+		limits:        limits,
+		beneficiaries: beneficiaries,
+		validate:      v,
+		now:           time.Now,
+		gateways:      gateways,
+		cfg:           cfg,
+	}
 }
 
 type SaldoDTO struct {
-	Total  float64 `json:"total_saldo"`
-	Topup  float64 `json:"saldo_topup"`
-	Redeem float64 `json:"saldo_redeem"`
-	EvPoin int     `json:"ev_poin"`
+	Total  money.Amount `json:"total_saldo"`
+	Topup  money.Amount `json:"saldo_topup"`
+	Redeem money.Amount `json:"saldo_redeem"`
+	EvPoin int          `json:"ev_poin"`
+
+	// Saldo yang sedang di-hold dan sisa yang bisa dipakai (saldo - hold).
+	HeldTotal       money.Amount `json:"held_saldo"`
+	HeldTopup       money.Amount `json:"held_topup"`
+	HeldRedeem      money.Amount `json:"held_redeem"`
+	AvailableTotal  money.Amount `json:"available_saldo"`
+	AvailableTopup  money.Amount `json:"available_topup"`
+	AvailableRedeem money.Amount `json:"available_redeem"`
+
+	Status string `json:"status"`
 }
 
 type TransactionDTO struct {
-	ID          string    `json:"id"`
-	Type        string    `json:"type"`
-	Amount      float64   `json:"amount"`
-	Description string    `json:"description"`
-	ReferenceID *string   `json:"reference_id,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string       `json:"id"`
+	Type        string       `json:"type"`
+	Amount      money.Amount `json:"amount"`
+	Description string       `json:"description"`
+	ReferenceID *string      `json:"reference_id,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+
+	// Saldo setelah transaksi ini; kosong untuk histori lama yang belum di-backfill.
+	BalanceAfter *BalanceAfterDTO `json:"balance_after,omitempty"`
+}
+
+type BalanceAfterDTO struct {
+	Total  money.Amount `json:"total_saldo"`
+	Topup  money.Amount `json:"saldo_topup"`
+	Redeem money.Amount `json:"saldo_redeem"`
 }
 
 type VoucherDTO struct {
-	ID          string     `json:"id"`
-	Code        string     `json:"kode_voucher"`
-	Amount      float64    `json:"nilai"`
-	Description string     `json:"deskripsi"`
-	ExpiresAt   *time.Time `json:"tanggal_kadaluarsa,omitempty"`
+	ID          string       `json:"id"`
+	Code        string       `json:"kode_voucher"`
+	Amount      money.Amount `json:"nilai"`
+	Description string       `json:"deskripsi"`
+	ExpiresAt   *time.Time   `json:"tanggal_kadaluarsa,omitempty"`
 }
 
 type PaymentStatusDTO struct {
-	OrderID     string     `json:"orderId"`
-	Status      string     `json:"status"`
-	Amount      float64    `json:"amount"`
-	SnapToken   string     `json:"snapToken,omitempty"`
-	RedirectURL string     `json:"redirectUrl,omitempty"`
-	SettledAt   *time.Time `json:"settledAt,omitempty"`
+	OrderID      string       `json:"orderId"`
+	Status       string       `json:"status"`
+	Amount       money.Amount `json:"amount"`
+	SnapToken    string       `json:"snapToken,omitempty"`
+	RedirectURL  string       `json:"redirectUrl,omitempty"`
+	ExpiresAt    time.Time    `json:"expiresAt"`
+	SettledAt    *time.Time   `json:"settledAt,omitempty"`
+	CancelledAt  *time.Time   `json:"cancelledAt,omitempty"`
+	RefundStatus string       `json:"refundStatus,omitempty"`
+
+	Channel      string               `json:"channel"`
+	Instructions *PaymentInstructions `json:"instructions,omitempty"`
 }
 
 func (s *WalletService) GetSaldo(ctx context.Context, userID string) (SaldoDTO, error) {
@@ -78,15 +115,72 @@ func (s *WalletService) GetSaldo(ctx context.Context, userID string) (SaldoDTO,
 	if err != nil {
 		return SaldoDTO{}, err
 	}
-	return SaldoDTO{Total: tot, Topup: topup, Redeem: redeem, EvPoin: poin}, nil
+	heldTopup, heldRedeem, err := s.repo.GetHeld(ctx, userID)
+	if err != nil {
+		return SaldoDTO{}, err
+	}
+	status, err := s.walletStatus(ctx, userID)
+	if err != nil {
+		return SaldoDTO{}, err
+	}
+	return SaldoDTO{
+		Total:           tot,
+		Topup:           topup,
+		Redeem:          redeem,
+		EvPoin:          poin,
+		HeldTotal:       heldTopup.Add(heldRedeem),
+		HeldTopup:       heldTopup,
+		HeldRedeem:      heldRedeem,
+		AvailableTotal:  tot.Sub(heldTopup).Sub(heldRedeem),
+		AvailableTopup:  topup.Sub(heldTopup),
+		AvailableRedeem: redeem.Sub(heldRedeem),
+		Status:          status,
+	}, nil
+}
+
+// TransactionQuery adalah filter histori transaksi dari query string.
+type TransactionQuery struct {
+	UserID    string
+	Types     []string // tipe_transaksi, kosong = semua
+	From      string   // YYYY-MM-DD (WIB) atau RFC3339, inklusif
+	To        string   // YYYY-MM-DD (WIB, inklusif sampai akhir hari) atau RFC3339 (eksklusif)
+	MinAmount string
+	MaxAmount string
+	Cursor    string // nextCursor dari halaman sebelumnya
+	Limit     int
+}
+
+type TransactionPage struct {
+	Items      []TransactionDTO
+	NextCursor string // kosong bila sudah halaman terakhir
 }
 
-func (s *WalletService) GetTransactions(ctx context.Context, userID string, limit int) ([]TransactionDTO, error) {
-	rows, err := s.repo.ListTransactions(ctx, userID, limit)
+const maxTransactionPageSize = 200
+
+func (s *WalletService) GetTransactions(ctx context.Context, in TransactionQuery) (TransactionPage, error) {
+	if err := s.validate.Var(in.UserID, "required,uuid4"); err != nil {
+		return TransactionPage{}, ErrBadRequest{Err: err}
+	}
+	p, err := buildTransactionFilter(in)
 	if err != nil {
-		return nil, err
+		return TransactionPage{}, ErrBadRequest{Err: err}
 	}
-	result := make([]TransactionDTO, 0, len(rows))
+
+	// ambil satu baris lebih untuk tahu masih ada halaman berikutnya
+	limit := p.Limit
+	p.Limit++
+	rows, err := s.repo.ListTransactions(ctx, p)
+	if err != nil {
+		return TransactionPage{}, err
+	}
+
+	var page TransactionPage
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeTransactionCursor(last.CreatedAt, last.ID)
+	}
+	page.Items = make([]TransactionDTO, 0, len(rows))
 	for _, row := range rows {
 		var desc string
 		if row.Description.Valid {
@@ -97,16 +191,165 @@ func (s *WalletService) GetTransactions(ctx context.Context, userID string, limi
 			val := row.ReferenceID.String
 			ref = &val
 		}
-		result = append(result, TransactionDTO{
+		item := TransactionDTO{
 			ID:          row.ID,
 			Type:        row.Type,
 			Amount:      row.Amount,
 			Description: desc,
 			ReferenceID: ref,
 			CreatedAt:   row.CreatedAt,
-		})
+		}
+		if row.BalanceAfterTotal != nil && row.BalanceAfterTopup != nil && row.BalanceAfterRedeem != nil {
+			item.BalanceAfter = &BalanceAfterDTO{
+				Total:  *row.BalanceAfterTotal,
+				Topup:  *row.BalanceAfterTopup,
+				Redeem: *row.BalanceAfterRedeem,
+			}
+		}
+		page.Items = append(page.Items, item)
 	}
-	return result, nil
+	return page, nil
+}
+
+func buildTransactionFilter(in TransactionQuery) (repositories.ListTransactionsParams, error) {
+	p := repositories.ListTransactionsParams{UserID: in.UserID, Limit: in.Limit}
+	if p.Limit <= 0 {
+		p.Limit = 50
+	}
+	if p.Limit > maxTransactionPageSize {
+		p.Limit = maxTransactionPageSize
+	}
+
+	for _, t := range in.Types {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			p.Types = append(p.Types, t)
+		}
+	}
+
+	if v := strings.TrimSpace(in.From); v != "" {
+		from, _, err := parseHistoryTime(v)
+		if err != nil {
+			return p, fmt.Errorf("from tidak valid: %w", err)
+		}
+		p.From = &from
+	}
+	if v := strings.TrimSpace(in.To); v != "" {
+		to, dateOnly, err := parseHistoryTime(v)
+		if err != nil {
+			return p, fmt.Errorf("to tidak valid: %w", err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		p.To = &to
+	}
+	if p.From != nil && p.To != nil && !p.From.Before(*p.To) {
+		return p, errors.New("from harus sebelum to")
+	}
+
+	if v := strings.TrimSpace(in.MinAmount); v != "" {
+		amt, err := money.Parse(v)
+		if err != nil {
+			return p, fmt.Errorf("minAmount tidak valid: %w", err)
+		}
+		p.MinAmount = &amt
+	}
+	if v := strings.TrimSpace(in.MaxAmount); v != "" {
+		amt, err := money.Parse(v)
+		if err != nil {
+			return p, fmt.Errorf("maxAmount tidak valid: %w", err)
+		}
+		p.MaxAmount = &amt
+	}
+	if p.MinAmount != nil && p.MaxAmount != nil && p.MinAmount.Cmp(*p.MaxAmount) > 0 {
+		return p, errors.New("minAmount tidak boleh melebihi maxAmount")
+	}
+
+	if v := strings.TrimSpace(in.Cursor); v != "" {
+		at, id, err := decodeTransactionCursor(v)
+		if err != nil {
+			return p, err
+		}
+		p.BeforeCreatedAt, p.BeforeID = &at, &id
+	}
+	return p, nil
+}
+
+// parseHistoryTime menerima tanggal (awal hari WIB) atau timestamp RFC3339.
+func parseHistoryTime(v string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, wib); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+// Cursor berisi posisi (created_at, id) baris terakhir, dikodekan base64 supaya
+// dianggap opaque oleh client.
+func encodeTransactionCursor(at time.Time, id string) string {
+	raw := at.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransactionCursor(cursor string) (time.Time, string, error) {
+	errCursor := errors.New("cursor tidak valid")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", errCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", errCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return time.Time{}, "", errCursor
+	}
+	return at, id, nil
+}
+
+type PointsHistoryDTO struct {
+	EvPoin  int              `json:"ev_poin"`
+	Entries []PointsEntryDTO `json:"entries"`
+}
+
+// ExpiringSoon menampilkan saldo redeem dan ev_poin yang akan kedaluwarsa dalam
+// beberapa hari ke depan.
+func (s *WalletService) ExpiringSoon(ctx context.Context, userID string, days int) (ExpiringSoonDTO, error) {
+	if err := s.validate.Var(userID, "required,uuid4"); err != nil {
+		return ExpiringSoonDTO{}, ErrBadRequest{Err: err}
+	}
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+	return s.lots.ExpiringSoon(ctx, userID, s.now().AddDate(0, 0, days))
+}
+
+// GetLimits menampilkan limit top up/tarik saldo sesuai tier user dan sisa kuotanya.
+func (s *WalletService) GetLimits(ctx context.Context, userID string) (LimitsDTO, error) {
+	if err := s.validate.Var(userID, "required,uuid4"); err != nil {
+		return LimitsDTO{}, ErrBadRequest{Err: err}
+	}
+	return s.limits.Usage(ctx, userID, s.now())
+}
+
+func (s *WalletService) GetPointsHistory(ctx context.Context, userID string, limit int) (PointsHistoryDTO, error) {
+	_, _, _, poin, err := s.repo.GetSaldo(ctx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return PointsHistoryDTO{}, ErrNotFoundResource{Msg: "wallet tidak ditemukan"}
+		}
+		return PointsHistoryDTO{}, err
+	}
+	entries, err := s.points.History(ctx, userID, limit)
+	if err != nil {
+		return PointsHistoryDTO{}, err
+	}
+	return PointsHistoryDTO{EvPoin: poin, Entries: entries}, nil
 }
 
 func (s *WalletService) ListAvailableVouchers(ctx context.Context, userID string, limit int) ([]VoucherDTO, error) {
@@ -145,30 +388,53 @@ func (s *WalletService) GetPaymentStatus(ctx context.Context, orderID string) (P
 		}
 		return PaymentStatusDTO{}, err
 	}
-	var settledAt *time.Time
+	return toPaymentStatusDTO(rec), nil
+}
+
+func toPaymentStatusDTO(rec repositories.PaymentOrderRecord) PaymentStatusDTO {
+	var settledAt, cancelledAt *time.Time
 	if rec.SettledAt.Valid {
 		t := rec.SettledAt.Time
 		settledAt = &t
 	}
+	if rec.CancelledAt.Valid {
+		t := rec.CancelledAt.Time
+		cancelledAt = &t
+	}
 	return PaymentStatusDTO{
-		OrderID:     rec.OrderID,
-		Status:      rec.Status,
-		Amount:      rec.GrossAmount,
-		SnapToken:   rec.SnapToken,
-		RedirectURL: rec.RedirectURL,
-		SettledAt:   settledAt,
-	}, nil
+		OrderID:      rec.OrderID,
+		Status:       rec.Status,
+		Amount:       rec.GrossAmount,
+		SnapToken:    rec.SnapToken,
+		RedirectURL:  rec.RedirectURL,
+		ExpiresAt:    rec.ExpiresAt,
+		SettledAt:    settledAt,
+		CancelledAt:  cancelledAt,
+		RefundStatus: rec.RefundStatus.String,
+		Channel:      rec.PaymentChannel,
+		Instructions: paymentInstructions(rec),
+	}
 }
 
-func (s *WalletService) HandleSnapNotification(ctx context.Context, payload NotificationPayload) error {
-	if s.midtransServerKey == "" {
-		return fmt.Errorf("midtrans server key belum dikonfigurasi")
+// HandlePaymentNotification memverifikasi notifikasi lewat gateway bernama provider
+// lalu menerapkannya ke order.
+func (s *WalletService) HandlePaymentNotification(ctx context.Context, provider string, body []byte, header func(string) string) error {
+	gw, err := s.gateways.Get(provider)
+	if err != nil {
+		return ErrBadRequest{Err: err}
 	}
-	if !VerifyNotificationSignature(s.midtransServerKey, payload) {
-		return ErrBadRequest{Err: errors.New("signature midtrans tidak valid")}
+	update, err := gw.ParseNotification(ctx, body, header)
+	if err != nil {
+		return err
 	}
+	return s.applyPaymentStatus(ctx, provider, update)
+}
 
-	order, err := s.repo.GetPaymentOrder(ctx, payload.OrderID)
+// applyPaymentStatus menerapkan status transaksi dari gateway ke payment order, baik
+// dari notifikasi maupun dari polling status. Saldo hanya dikreditkan sekali: baris
+// order dikunci dan balance_applied dicek ulang di dalam transaksi.
+func (s *WalletService) applyPaymentStatus(ctx context.Context, provider string, update PaymentUpdate) error {
+	order, err := s.repo.GetPaymentOrder(ctx, update.OrderID)
 	if err != nil {
 		var notFound repositories.ErrNotFound
 		if errors.As(err, &notFound) {
@@ -176,100 +442,130 @@ func (s *WalletService) HandleSnapNotification(ctx context.Context, payload Noti
 		}
 		return err
 	}
+	// signature satu provider tidak boleh bisa melunasi order provider lain
+	if order.Provider != provider {
+		return ErrBadRequest{Err: fmt.Errorf("order %s bukan milik provider %s", order.OrderID, provider)}
+	}
+	if update.GrossAmount != order.GrossAmount {
+		return ErrBadRequest{Err: fmt.Errorf("gross_amount %s tidak sesuai order %s", update.GrossAmount, order.GrossAmount)}
+	}
 
-	status := strings.ToLower(payload.TransactionStatus)
-	fraud := strings.ToLower(payload.FraudStatus)
-	newStatus := strings.ToUpper(payload.TransactionStatus)
-	applyBalance := false
-
-	switch status {
-	case "capture":
-		if fraud == "accept" {
-			newStatus = "SETTLEMENT"
-			applyBalance = true
-		} else {
-			newStatus = "PENDING"
-		}
-	case "settlement":
-		newStatus = "SETTLEMENT"
-		applyBalance = true
-	case "cancel":
-		newStatus = "CANCELLED"
-	case "expire":
-		newStatus = "EXPIRED"
-	case "deny":
-		newStatus = "DENY"
-	}
-
-	rawBytes, _ := json.Marshal(payload)
-	var settledAt *time.Time
-	if payload.SettlementTime != "" {
-		if t, err := time.Parse(time.RFC3339, payload.SettlementTime); err == nil {
-			settledAt = &t
-		} else if t2, err2 := time.Parse("2006-01-02 15:04:05", payload.SettlementTime); err2 == nil {
-			settledAt = &t2
-		}
+	var providerTrxID *string
+	if update.ProviderTrxID != "" {
+		providerTrxID = &update.ProviderTrxID
 	}
 
-	var midtransID *string
-	if payload.TransactionID != "" {
-		midtransID = &payload.TransactionID
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	if newStatus == "SETTLEMENT" && !order.BalanceApplied && applyBalance {
-		tx, err := s.repo.BeginTx(ctx)
-		if err != nil {
-			return err
+	newStatus := update.Status
+	applyBalance := newStatus == "SETTLEMENT"
+	if applyBalance {
+		// wallet dikunci sebelum order, sama seperti alur saldo lain
+		if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, order.UserID); err != nil {
+			var notFound repositories.ErrNotFound
+			if !errors.As(err, &notFound) {
+				return err
+			}
 		}
-		defer tx.Rollback()
+	}
+	order, err = s.repo.GetPaymentOrderForUpdate(ctx, tx, order.OrderID)
+	if err != nil {
+		return err
+	}
+	// saldo sudah masuk; notifikasi ulang atau status yang datang terlambat diabaikan
+	if order.BalanceApplied {
+		return nil
+	}
+	// order yang sudah dibatalkan/kedaluwarsa tidak kembali ke PENDING
+	if newStatus == "PENDING" && order.Status != "PENDING" {
+		return tx.Commit()
+	}
 
-		applied := true
+	if !applyBalance {
 		if err := s.repo.UpdatePaymentOrderStatusTx(ctx, tx, repositories.UpdatePaymentOrderStatusParams{
-			OrderID:            order.OrderID,
-			Status:             newStatus,
-			MidtransTrxID:      midtransID,
-			RawNotification:    rawBytes,
-			SettledAt:          settledAt,
-			BalanceAlreadyUsed: &applied,
+			OrderID:         order.OrderID,
+			Status:          newStatus,
+			ProviderTrxID:   providerTrxID,
+			RawNotification: update.Raw,
+			SettledAt:       update.SettledAt,
 		}); err != nil {
 			return err
 		}
+		return tx.Commit()
+	}
 
-		ref := payload.TransactionID
-		if ref == "" {
-			ref = order.OrderID
-		}
-		if err := s.repo.CreateTransaction(ctx, tx, repositories.CreateTransactionParams{
-			UserID:        order.UserID,
-			TipeTransaksi: "TOP_UP",
-			Jumlah:        order.GrossAmount,
-			Deskripsi:     "Top up via Midtrans",
-			ReferensiID:   &ref,
-			CreatedAt:     s.now(),
-		}); err != nil {
-			return err
-		}
+	applied := true
+	if err := s.repo.UpdatePaymentOrderStatusTx(ctx, tx, repositories.UpdatePaymentOrderStatusParams{
+		OrderID:            order.OrderID,
+		Status:             newStatus,
+		ProviderTrxID:      providerTrxID,
+		RawNotification:    update.Raw,
+		SettledAt:          update.SettledAt,
+		BalanceAlreadyUsed: &applied,
+	}); err != nil {
+		return err
+	}
 
-		if err := s.repo.AddSaldo(ctx, tx, repositories.AddSaldoParams{
-			UserID:      order.UserID,
-			DeltaTotal:  order.GrossAmount,
-			DeltaTopup:  order.GrossAmount,
-			DeltaRedeem: 0,
-			UpdatedAt:   s.now(),
-		}); err != nil {
-			return err
-		}
+	ref := update.ProviderTrxID
+	if ref == "" {
+		ref = order.OrderID
+	}
+	now := s.now()
+	desc := "Top up via " + providerLabel(order.Provider)
+	txnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
+		UserID:        order.UserID,
+		TipeTransaksi: "TOP_UP",
+		Jumlah:        order.GrossAmount,
+		Deskripsi:     desc,
+		ReferensiID:   &ref,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+	// dipakai pembalikan dan refund untuk saling mengecek
+	if err := s.repo.SetPaymentOrderTransaction(ctx, tx, order.OrderID, txnID); err != nil {
+		return err
+	}
 
-		return tx.Commit()
+	// Dana masuk dari Midtrans (clearing) menjadi saldo top up user.
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     "TOP_UP",
+		TransactionID: &txnID,
+		ReferensiID:   &order.OrderID,
+		Deskripsi:     desc,
+		Postings: []LedgerPosting{
+			ledgerDebit("", repositories.LedgerAccountMidtransClearing, order.GrossAmount),
+			ledgerCredit(order.UserID, repositories.LedgerAccountUserTopup, order.GrossAmount),
+		},
+		CreatedAt: now,
+	}); err != nil {
+		return err
 	}
 
-	return s.repo.UpdatePaymentOrderStatus(ctx, repositories.UpdatePaymentOrderStatusParams{
-		OrderID:         order.OrderID,
-		Status:          newStatus,
-		MidtransTrxID:   midtransID,
-		RawNotification: rawBytes,
-		SettledAt:       settledAt,
-	})
+	// limit sudah dicek saat order dibuat; dana yang sudah masuk tetap dicatat. Waktunya
+	// mengikuti pembuatan order agar posisinya di jendela limit tidak bergeser.
+	if err := s.limits.Record(ctx, tx, order.UserID, LimitOpTopUp, order.OrderID, order.GrossAmount, order.CreatedAt); err != nil {
+		return err
+	}
+
+	if _, err := s.points.Award(ctx, tx, PointsEvent{
+		UserID:        order.UserID,
+		EventType:     PointsEventTopUp,
+		Amount:        order.GrossAmount,
+		SourceID:      order.OrderID,
+		TransactionID: &txnID,
+		Deskripsi:     "Poin top up " + order.OrderID,
+		OccurredAt:    now,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // ===== Klaim Voucher =====
@@ -279,22 +575,55 @@ type KlaimVoucherInput struct {
 	KodeVoucher string `json:"kodeVoucher" validate:"required,min=6"`
 }
 
+// errWholeRupiah dipakai untuk nominal yang diteruskan ke Midtrans, karena Snap
+// dan Iris hanya menerima rupiah bulat.
+var errWholeRupiah = errors.New("jumlah harus dalam rupiah bulat")
+
 type ErrBadRequest struct{ Err error }
 
 func (e ErrBadRequest) Error() string { return e.Err.Error() }
 
-type ErrConflict struct{ Msg string }
+// ErrConflict bisa membawa error asal (mis. penolakan provider) agar klasifikasinya
+// seperti isPermanent tetap terbaca oleh caller.
+type ErrConflict struct {
+	Msg string
+	Err error
+}
 
 func (e ErrConflict) Error() string { return e.Msg }
 
+func (e ErrConflict) Unwrap() error { return e.Err }
+
 type ErrInsufficientBalance struct{ Msg string }
 
 func (e ErrInsufficientBalance) Error() string { return e.Msg }
 
+// ErrForbidden dikembalikan untuk pemanggil yang kredensialnya ditolak, mis. callback
+// token notifikasi yang salah.
+type ErrForbidden struct{ Msg string }
+
+func (e ErrForbidden) Error() string { return e.Msg }
+
 type ErrNotFoundResource struct{ Msg string }
 
 func (e ErrNotFoundResource) Error() string { return e.Msg }
 
+type ErrLimitExceeded struct{ Msg string }
+
+func (e ErrLimitExceeded) Error() string { return e.Msg }
+
+type ErrIdempotencyMismatch struct{ Msg string }
+
+func (e ErrIdempotencyMismatch) Error() string { return e.Msg }
+
+// ErrWalletUnavailable dikembalikan bila status wallet menolak mutasi saldo.
+type ErrWalletUnavailable struct {
+	Status string
+	Msg    string
+}
+
+func (e ErrWalletUnavailable) Error() string { return e.Msg }
+
 func (s *WalletService) KlaimVoucher(ctx context.Context, in KlaimVoucherInput) error {
 	if err := s.validate.Struct(in); err != nil {
 		return ErrBadRequest{Err: err}
@@ -323,6 +652,10 @@ func (s *WalletService) KlaimVoucher(ctx context.Context, in KlaimVoucherInput)
 	}
 	defer tx.Rollback()
 
+	if err := ensureWalletAllows(ctx, s.repo, tx, in.UserID, walletCredit); err != nil {
+		return err
+	}
+
 	inserted, err := s.repo.CreateVoucherClaim(ctx, tx, in.UserID, vID, now)
 	if err != nil {
 		return err
@@ -332,24 +665,53 @@ func (s *WalletService) KlaimVoucher(ctx context.Context, in KlaimVoucherInput)
 	}
 
 	ref := vID
-	if err := s.repo.CreateTransaction(ctx, tx, repositories.CreateTransactionParams{
+	txnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
 		UserID:        in.UserID,
 		TipeTransaksi: "KLAIM_VOUCHER",
 		Jumlah:        nilai,
 		Deskripsi:     "Klaim voucher " + in.KodeVoucher,
 		ReferensiID:   &ref,
 		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Klaim voucher didanai akun voucher funding dan menambah saldo redeem.
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     "KLAIM_VOUCHER",
+		TransactionID: &txnID,
+		ReferensiID:   &ref,
+		Deskripsi:     "Klaim voucher " + in.KodeVoucher,
+		Postings: []LedgerPosting{
+			ledgerDebit("", repositories.LedgerAccountVoucherFunding, nilai),
+			ledgerCredit(in.UserID, repositories.LedgerAccountUserRedeem, nilai),
+		},
+		CreatedAt: now,
 	}); err != nil {
 		return err
 	}
 
-	// Klaim voucher menambah saldo redeem dan total saldo.
-	if err := s.repo.AddSaldo(ctx, tx, repositories.AddSaldoParams{
-		UserID:      in.UserID,
-		DeltaTotal:  nilai,
-		DeltaTopup:  0,
-		DeltaRedeem: nilai,
-		UpdatedAt:   now,
+	// saldo dari voucher adalah saldo promosi yang punya masa berlaku
+	if err := s.lots.Credit(ctx, tx, LotCredit{
+		UserID:     in.UserID,
+		Kind:       repositories.LotKindRedeem,
+		Quantity:   nilai,
+		SourceType: "KLAIM_VOUCHER",
+		SourceID:   vID,
+		CreatedAt:  now,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := s.points.Award(ctx, tx, PointsEvent{
+		UserID:        in.UserID,
+		EventType:     PointsEventVoucherClaim,
+		Amount:        nilai,
+		SourceID:      vID,
+		TransactionID: &txnID,
+		Deskripsi:     "Poin klaim voucher " + in.KodeVoucher,
+		OccurredAt:    now,
 	}); err != nil {
 		return err
 	}
@@ -360,56 +722,61 @@ func (s *WalletService) KlaimVoucher(ctx context.Context, in KlaimVoucherInput)
 // ===== Top Up =====
 
 type TopUpInput struct {
-	UserID string  `json:"userId" validate:"required,uuid4"`
-	Jumlah float64 `json:"jumlah" validate:"required,gt=0"`
+	UserID string       `json:"userId" validate:"required,uuid4"`
+	Jumlah money.Amount `json:"jumlah" validate:"required,gt=0"`
 }
 
 type TopUpResult struct {
-	OrderID     string `json:"orderId"`
-	SnapToken   string `json:"snapToken"`
-	RedirectURL string `json:"redirectUrl"`
-	Status      string `json:"status"`
+	OrderID     string    `json:"orderId"`
+	SnapToken   string    `json:"snapToken"`
+	RedirectURL string    `json:"redirectUrl"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expiresAt"`
 }
 
 func (s *WalletService) TopUp(ctx context.Context, in TopUpInput) (TopUpResult, error) {
 	if err := s.validate.Struct(in); err != nil {
 		return TopUpResult{}, ErrBadRequest{Err: err}
 	}
-
-	if s.snapClient == nil {
-		return TopUpResult{}, fmt.Errorf("midtrans snap client belum dikonfigurasi")
+	gw, err := s.gateways.ForChannel(repositories.PaymentChannelSnap)
+	if err != nil {
+		return TopUpResult{}, err
 	}
-
-	user, err := s.repo.GetUserProfile(ctx, in.UserID)
+	customer, err := s.prepareTopUp(ctx, in)
 	if err != nil {
 		return TopUpResult{}, err
 	}
 
-	orderID := fmt.Sprintf("TOPUP-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
-
-	req := SnapRequest{
-		TransactionDetails: SnapTransactionDetails{
-			OrderID:     orderID,
-			GrossAmount: in.Jumlah,
-		},
-	}
-	if user != nil {
-		req.CustomerDetails = &SnapCustomerDetails{
-			FirstName: user.Name,
-			Email:     user.Email,
-			Phone:     user.Phone,
-		}
+	orderID := newTopUpOrderID()
+	now := s.now()
+	expiry := s.cfg.PendingTopUp.expiry()
+	if err := s.openTopUpOrder(ctx, repositories.CreatePaymentOrderParams{
+		UserID:      in.UserID,
+		OrderID:     orderID,
+		GrossAmount: in.Jumlah,
+		ExpiresAt:   now.Add(expiry),
+		Channel:     repositories.PaymentChannelSnap,
+		Provider:    gw.Name(),
+	}, now); err != nil {
+		return TopUpResult{}, err
 	}
 
-	res, err := s.snapClient.CreateTransaction(ctx, req)
+	res, err := gw.CreateCheckout(ctx, CheckoutRequest{
+		OrderID:   orderID,
+		Amount:    in.Jumlah,
+		Customer:  customer,
+		StartAt:   now,
+		ExpiresIn: expiry,
+	})
 	if err != nil {
+		if fErr := s.failTopUpOrder(ctx, orderID); fErr != nil {
+			return TopUpResult{}, errors.Join(err, fErr)
+		}
 		return TopUpResult{}, err
 	}
 
-	if err := s.repo.CreatePaymentOrder(ctx, repositories.CreatePaymentOrderParams{
-		UserID:      in.UserID,
+	if err := s.repo.SetPaymentOrderCheckout(ctx, repositories.PaymentOrderCheckoutParams{
 		OrderID:     orderID,
-		GrossAmount: in.Jumlah,
 		SnapToken:   res.Token,
 		RedirectURL: res.RedirectURL,
 	}); err != nil {
@@ -421,24 +788,203 @@ func (s *WalletService) TopUp(ctx context.Context, in TopUpInput) (TopUpResult,
 		SnapToken:   res.Token,
 		RedirectURL: res.RedirectURL,
 		Status:      "PENDING",
+		ExpiresAt:   now.Add(expiry),
+	}, nil
+}
+
+func newTopUpOrderID() string {
+	return fmt.Sprintf("TOPUP-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+}
+
+// prepareTopUp menjalankan pengecekan yang sama untuk semua channel top up dan
+// mengembalikan data pelanggan untuk gateway. Dicek sebelum order dibuat;
+// settlement yang datang belakangan tetap dibukukan karena dananya sudah diterima.
+// Limit dicek ulang di openTopUpOrder di bawah kunci wallet.
+func (s *WalletService) prepareTopUp(ctx context.Context, in TopUpInput) (*PaymentCustomer, error) {
+	if !in.Jumlah.IsWholeRupiah() {
+		return nil, ErrBadRequest{Err: errWholeRupiah}
+	}
+	status, err := s.walletStatus(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWalletStatus(status, walletCredit); err != nil {
+		return nil, err
+	}
+	if err := s.limits.Check(ctx, in.UserID, LimitOpTopUp, in.Jumlah, s.now()); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserProfile(ctx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &PaymentCustomer{
+		Name:  user.Name,
+		Email: user.Email,
+		Phone: user.Phone,
 	}, nil
 }
 
+// openTopUpOrder membuat order PENDING sebelum gateway dipanggil. Order PENDING ikut
+// dihitung ke limit top up, jadi pengecekan limit dan pembuatan order dilakukan di
+// bawah kunci wallet agar request paralel tidak bersama-sama melewati limit.
+func (s *WalletService) openTopUpOrder(ctx context.Context, p repositories.CreatePaymentOrderParams, at time.Time) error {
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, p.UserID); err != nil {
+		var notFound repositories.ErrNotFound
+		if !errors.As(err, &notFound) {
+			return err
+		}
+	}
+	if err := s.limits.CheckTx(ctx, tx, p.UserID, LimitOpTopUp, p.GrossAmount, at); err != nil {
+		return err
+	}
+	if err := s.repo.CreatePaymentOrder(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// failTopUpOrder menandai FAILED order yang gagal dibuat di gateway sehingga tidak
+// lagi memakan limit. Bila gateway ternyata sempat membuat transaksinya, pembayaran
+// yang masuk tetap dibukukan lewat notifikasi.
+func (s *WalletService) failTopUpOrder(ctx context.Context, orderID string) error {
+	// request bisa gagal karena ctx-nya habis; order tetap harus dilepas
+	ctx = context.WithoutCancel(ctx)
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	order, err := s.repo.GetPaymentOrderForUpdate(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != "PENDING" || order.BalanceApplied {
+		return tx.Commit()
+	}
+	if err := s.repo.UpdatePaymentOrderStatusTx(ctx, tx, repositories.UpdatePaymentOrderStatusParams{
+		OrderID: orderID,
+		Status:  "FAILED",
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type CancelTopUpInput struct {
+	UserID  string `json:"userId" validate:"required,uuid4"`
+	OrderID string `json:"-"      validate:"required,max=64"`
+}
+
+// CancelTopUp membatalkan order top up milik user yang belum dibayar. Bila user belum
+// memilih metode pembayaran, gateway belum punya transaksinya dan order cukup
+// dibatalkan di sisi kita; pembayaran yang tetap masuk lewat halaman bayar akan
+// dibukukan seperti biasa oleh notifikasi.
+func (s *WalletService) CancelTopUp(ctx context.Context, in CancelTopUpInput) (PaymentStatusDTO, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return PaymentStatusDTO{}, ErrBadRequest{Err: err}
+	}
+	order, err := s.repo.GetPaymentOrder(ctx, in.OrderID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return PaymentStatusDTO{}, ErrNotFoundResource{Msg: "order tidak ditemukan"}
+		}
+		return PaymentStatusDTO{}, err
+	}
+	if order.UserID != in.UserID {
+		return PaymentStatusDTO{}, ErrNotFoundResource{Msg: "order tidak ditemukan"}
+	}
+	if err := checkCancellable(order); err != nil {
+		return PaymentStatusDTO{}, err
+	}
+
+	gw, err := s.gateways.Get(order.Provider)
+	if err != nil {
+		return PaymentStatusDTO{}, err
+	}
+	var providerTrxID *string
+	trxID, err := gw.Cancel(ctx, order.OrderID)
+	switch {
+	case errors.Is(err, ErrPaymentNotCancellable):
+		return PaymentStatusDTO{}, ErrConflict{Msg: "order sudah dibayar dan tidak bisa dibatalkan"}
+	case errors.Is(err, ErrPaymentNotFound):
+	case err != nil:
+		return PaymentStatusDTO{}, err
+	case trxID != "":
+		providerTrxID = &trxID
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return PaymentStatusDTO{}, err
+	}
+	defer tx.Rollback()
+
+	// settlement bisa masuk di antara pengecekan awal dan panggilan cancel
+	order, err = s.repo.GetPaymentOrderForUpdate(ctx, tx, order.OrderID)
+	if err != nil {
+		return PaymentStatusDTO{}, err
+	}
+	if err := checkCancellable(order); err != nil {
+		return PaymentStatusDTO{}, err
+	}
+	now := s.now()
+	if err := s.repo.UpdatePaymentOrderStatusTx(ctx, tx, repositories.UpdatePaymentOrderStatusParams{
+		OrderID:       order.OrderID,
+		Status:        "CANCELLED",
+		ProviderTrxID: providerTrxID,
+		CancelledAt:   &now,
+	}); err != nil {
+		return PaymentStatusDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return PaymentStatusDTO{}, err
+	}
+
+	order.Status = "CANCELLED"
+	order.CancelledAt = sql.NullTime{Time: now, Valid: true}
+	if providerTrxID != nil {
+		order.ProviderTrxID = sql.NullString{String: *providerTrxID, Valid: true}
+	}
+	return toPaymentStatusDTO(order), nil
+}
+
+func checkCancellable(order repositories.PaymentOrderRecord) error {
+	if order.BalanceApplied || order.Status == "SETTLEMENT" {
+		return ErrConflict{Msg: "order sudah dibayar dan tidak bisa dibatalkan"}
+	}
+	if order.Status != "PENDING" {
+		return ErrConflict{Msg: fmt.Sprintf("order sudah berstatus %s", order.Status)}
+	}
+	return nil
+}
+
 // ===== Withdraw =====
 
+// WithdrawInput menarik saldo ke rekening yang sudah disimpan dan divalidasi lewat
+// BeneficiaryService; data bank mentah tidak lagi diterima.
 type WithdrawInput struct {
-	UserID         string  `json:"userId"`
-	Jumlah         float64 `json:"jumlah"`
-	BalanceType    string  `json:"balanceType"`
-	BalanceTypeAlt string  `json:"balance_type"`
-	Source         string  `json:"source"`
-	BankCode       string  `json:"bankCode"`
-	BankName       string  `json:"bankName"`
-	AccountNumber  string  `json:"accountNumber"`
-	AccountHolderName  string  `json:"accountHolderName"`
-	Email          string  `json:"email"`
-	Phone          string  `json:"phone"`
-	Notes          string  `json:"notes"`
+	UserID         string       `json:"userId"`
+	Jumlah         money.Amount `json:"jumlah"`
+	BalanceType    string       `json:"balanceType"`
+	BalanceTypeAlt string       `json:"balance_type"`
+	Source         string       `json:"source"`
+	BeneficiaryID  string       `json:"beneficiaryId"`
+	Email          string       `json:"email"`
+	Phone          string       `json:"phone"`
+	Notes          string       `json:"notes"`
 }
 
 type WithdrawResult struct {
@@ -453,8 +999,11 @@ func (s *WalletService) Withdraw(ctx context.Context, in WithdrawInput) (Withdra
 	if err := s.validate.Var(in.Jumlah, "required,gt=0"); err != nil {
 		return WithdrawResult{}, ErrBadRequest{Err: err}
 	}
-	if strings.TrimSpace(in.BankCode) == "" || strings.TrimSpace(in.AccountNumber) == "" || strings.TrimSpace(in.AccountHolderName) == "" {
-		return WithdrawResult{}, ErrBadRequest{Err: errors.New("data bank wajib diisi")}
+	if !in.Jumlah.IsWholeRupiah() {
+		return WithdrawResult{}, ErrBadRequest{Err: errWholeRupiah}
+	}
+	if err := s.validate.Var(in.BeneficiaryID, "required,uuid4"); err != nil {
+		return WithdrawResult{}, ErrBadRequest{Err: errors.New("beneficiaryId wajib diisi dengan rekening yang sudah disimpan")}
 	}
 
 	balanceType := firstNonEmpty(
@@ -462,19 +1011,18 @@ func (s *WalletService) Withdraw(ctx context.Context, in WithdrawInput) (Withdra
 		in.BalanceTypeAlt,
 		in.Source,
 	)
-	balanceType = strings.ToLower(strings.TrimSpace(balanceType))
-	if balanceType == "" {
-		return WithdrawResult{}, ErrBadRequest{Err: errors.New("balanceType wajib diisi")}
+	target, err := parseBalanceTarget(balanceType)
+	if err != nil {
+		return WithdrawResult{}, err
 	}
 
-	var target string
-	switch balanceType {
-	case "topup", "saldo_topup", "pendapatan", "deposit", "saldo_deposit":
-		target = "topup"
-	case "redeem", "saldo_redeem", "refund", "saldo_refund", "ev", "ev_poin":
-		target = "redeem"
-	default:
-		return WithdrawResult{}, ErrBadRequest{Err: errors.New("balanceType tidak valid")}
+	ben, err := s.beneficiaries.GetBeneficiary(ctx, in.UserID, in.BeneficiaryID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return WithdrawResult{}, ErrNotFoundResource{Msg: "rekening tujuan tidak ditemukan"}
+		}
+		return WithdrawResult{}, err
 	}
 
 	tx, err := s.repo.BeginTx(ctx)
@@ -487,135 +1035,151 @@ func (s *WalletService) Withdraw(ctx context.Context, in WithdrawInput) (Withdra
 	if err != nil {
 		return WithdrawResult{}, err
 	}
+	if err := ensureWalletAllows(ctx, s.repo, tx, in.UserID, walletDebit); err != nil {
+		return WithdrawResult{}, err
+	}
+	heldTopup, heldRedeem, err := s.repo.GetHeldTx(ctx, tx, in.UserID)
+	if err != nil {
+		return WithdrawResult{}, err
+	}
+	// saldo yang sedang di-hold tidak boleh ditarik
+	topupBalance, redeemBalance = topupBalance.Sub(heldTopup), redeemBalance.Sub(heldRedeem)
+	total = total.Sub(heldTopup).Sub(heldRedeem)
 
 	amount := in.Jumlah
 
 	switch target {
 	case "topup":
-		if topupBalance < amount {
+		if topupBalance.Cmp(amount) < 0 {
 			return WithdrawResult{}, ErrInsufficientBalance{Msg: "saldo top up tidak mencukupi"}
 		}
 	case "redeem":
-		if redeemBalance < amount {
+		if redeemBalance.Cmp(amount) < 0 {
 			return WithdrawResult{}, ErrInsufficientBalance{Msg: "saldo redeem tidak mencukupi"}
 		}
 	}
 
-	if total < amount {
+	if total.Cmp(amount) < 0 {
 		return WithdrawResult{}, ErrInsufficientBalance{Msg: "total saldo tidak mencukupi"}
 	}
 
 	now := s.now()
+	payoutID := fmt.Sprintf("WD-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	if err := s.limits.Consume(ctx, tx, in.UserID, LimitOpWithdraw, payoutID, amount, now); err != nil {
+		return WithdrawResult{}, err
+	}
 
 	var (
-		deltaTopup  float64
-		deltaRedeem float64
-		txnType     string
-		txnDesc     string
+		sourceAccount string
+		txnType       string
+		txnDesc       string
 	)
 	if target == "topup" {
-		deltaTopup = -amount
+		sourceAccount = repositories.LedgerAccountUserTopup
 		txnType = "TARIK_SALDO_PENDAPATAN"
 		txnDesc = "Tarik saldo top up"
 	}
 	if target == "redeem" {
-		deltaRedeem = -amount
+		sourceAccount = repositories.LedgerAccountUserRedeem
 		txnType = "TARIK_SALDO_REFUND"
 		txnDesc = "Tarik saldo redeem"
 	}
 
-	payoutID := fmt.Sprintf("WD-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
-
 	rawReq := make(map[string]any)
-	rawReq["bankCode"] = in.BankCode
-	rawReq["bankName"] = in.BankName
-	rawReq["accountNumber"] = in.AccountNumber
-	rawReq["accountHolderName"] = in.AccountHolderName
+	rawReq["beneficiaryId"] = ben.ID
+	rawReq["bankCode"] = ben.BankCode
+	rawReq["bankName"] = ben.BankName.String
+	rawReq["accountNumber"] = ben.AccountNumber
+	rawReq["accountHolderName"] = ben.AccountHolderName
 	rawReq["notes"] = in.Notes
 	rawBytes, _ := json.Marshal(rawReq)
 
-	payout, err := s.repo.CreatePayoutRequest(ctx, repositories.CreatePayoutRequestParams{
+	// payout ikut transaksi debit; pengiriman ke provider dilakukan PayoutService.DispatchPending
+	payout, err := s.repo.CreatePayoutRequest(ctx, tx, repositories.CreatePayoutRequestParams{
 		UserID:            in.UserID,
 		Amount:            amount,
-		BankCode:          in.BankCode,
-		BankName:          in.BankName,
-		AccountNumber:     in.AccountNumber,
-		AccountHolderName: in.AccountHolderName,
+		BankCode:          ben.BankCode,
+		BankName:          ben.BankName.String,
+		AccountNumber:     ben.AccountNumber,
+		AccountHolderName: ben.AccountHolderName,
 		Status:            "PENDING",
 		RawResponse:       rawBytes,
+		PartnerTrxID:      payoutID,
+		BeneficiaryID:     &ben.ID,
+		BeneficiaryEmail:  strings.TrimSpace(in.Email),
+		Notes:             strings.TrimSpace(in.Notes),
 		RequestedAt:       now,
+		Provider:          s.cfg.Payouts.ProviderFor(ben.BankCode),
 	})
 	if err != nil {
 		return WithdrawResult{}, err
 	}
 
-	if err := s.repo.CreateTransaction(ctx, tx, repositories.CreateTransactionParams{
+	txnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
 		UserID:        in.UserID,
 		TipeTransaksi: txnType,
 		Jumlah:        amount,
 		Deskripsi:     txnDesc,
-		ReferensiID:   nil,
+		ReferensiID:   &payout.ID,
 		CreatedAt:     now,
+	})
+	if err != nil {
+		return WithdrawResult{}, err
+	}
+	// dipakai untuk mengembalikan saldo bila payout gagal
+	if err := s.repo.UpdatePayoutRequestStatusTx(ctx, tx, repositories.UpdatePayoutRequestStatusParams{
+		ID:            payout.ID,
+		Status:        "PENDING",
+		TransactionID: &txnID,
 	}); err != nil {
 		return WithdrawResult{}, err
 	}
 
-	if err := s.repo.AddSaldo(ctx, tx, repositories.AddSaldoParams{
-		UserID:      in.UserID,
-		DeltaTotal:  -amount,
-		DeltaTopup:  deltaTopup,
-		DeltaRedeem: deltaRedeem,
-		UpdatedAt:   now,
+	// Saldo user berpindah ke akun payout clearing sampai dana dikirim provider payout.
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     txnType,
+		TransactionID: &txnID,
+		ReferensiID:   &payout.ID,
+		Deskripsi:     txnDesc,
+		Postings: []LedgerPosting{
+			ledgerDebit(in.UserID, sourceAccount, amount),
+			ledgerCredit("", repositories.LedgerAccountPayoutClearing, amount),
+		},
+		CreatedAt: now,
 	}); err != nil {
 		return WithdrawResult{}, err
 	}
+	if target == "redeem" {
+		if _, err := s.lots.Consume(ctx, tx, in.UserID, repositories.LotKindRedeem, amount); err != nil {
+			return WithdrawResult{}, err
+		}
+	}
 
 	if err := tx.Commit(); err != nil {
 		return WithdrawResult{}, err
 	}
 
-	var payoutStatus = "PENDING"
-	if s.irisClient != nil && s.irisClient.ClientKey != "" && s.irisClient.ClientSecret != "" {
-		irisReq := IrisPayoutRequest{
-			Payouts: []struct {
-				Amount             string `json:"amount"`
-				BeneficiaryName    string `json:"beneficiary_name"`
-				BeneficiaryAccount string `json:"beneficiary_account"`
-				BeneficiaryBank    string `json:"beneficiary_bank"`
-				BeneficiaryEmail   string `json:"beneficiary_email,omitempty"`
-				Notes              string `json:"notes,omitempty"`
-				PartnerTrxID       string `json:"partner_trx_id"`
-			}{
-				{
-					Amount:             fmt.Sprintf("%.0f", amount),
-					BeneficiaryName:    in.AccountHolderName,
-					BeneficiaryAccount: in.AccountNumber,
-					BeneficiaryBank:    in.BankCode,
-					BeneficiaryEmail:   in.Email,
-					Notes:              in.Notes,
-					PartnerTrxID:       payoutID,
-				},
-			},
-		}
-		irisRes, err := s.irisClient.CreatePayout(ctx, irisReq)
-		if err == nil {
-			resBytes, _ := json.Marshal(irisRes)
-			_ = s.repo.UpdatePayoutRequestStatus(ctx, repositories.UpdatePayoutRequestStatusParams{
-				ID:               payout.ID,
-				Status:           strings.ToUpper(irisRes.Status),
-				MidtransPayoutID: &irisRes.PayoutID,
-				RawResponse:      resBytes,
-			})
-			payoutStatus = strings.ToUpper(irisRes.Status)
-		}
-	}
-
 	return WithdrawResult{
 		OrderID: payout.ID,
-		Status:  payoutStatus,
+		Status:  payout.Status,
 	}, nil
 }
 
+// parseBalanceTarget menormalkan nama sub-saldo dari klien menjadi "topup" atau "redeem".
+func parseBalanceTarget(balanceType string) (string, error) {
+	balanceType = strings.ToLower(strings.TrimSpace(balanceType))
+	switch balanceType {
+	case "":
+		return "", ErrBadRequest{Err: errors.New("balanceType wajib diisi")}
+	case "topup", "saldo_topup", "pendapatan", "deposit", "saldo_deposit":
+		return "topup", nil
+	case "redeem", "saldo_redeem", "refund", "saldo_refund", "ev", "ev_poin":
+		return "redeem", nil
+	default:
+		return "", ErrBadRequest{Err: errors.New("balanceType tidak valid")}
+	}
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if strings.TrimSpace(v) != "" {
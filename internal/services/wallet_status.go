@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+)
+
+// Status wallet (enum wallet_status).
+const (
+	WalletStatusActive       = "ACTIVE"
+	WalletStatusFrozen       = "FROZEN"
+	WalletStatusDebitBlocked = "DEBIT_BLOCKED"
+	WalletStatusClosed       = "CLOSED"
+)
+
+// walletOp membedakan mutasi yang menambah saldo dan yang mengurangi saldo.
+type walletOp int
+
+const (
+	walletCredit walletOp = iota
+	walletDebit
+)
+
+// ensureWalletAllows menolak mutasi saldo bila status wallet tidak mengizinkan.
+// Baris wallet_summary ikut dikunci; wallet yang belum ada dianggap ACTIVE.
+//
+//	ACTIVE        : semua mutasi boleh
+//	DEBIT_BLOCKED : hanya saldo masuk
+//	FROZEN/CLOSED : tidak ada mutasi
+func ensureWalletAllows(ctx context.Context, repo repositories.WalletRepo, tx repositories.DBTX, userID string, op walletOp) error {
+	st, err := repo.GetWalletStatusTx(ctx, tx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+	return checkWalletStatus(st.Status, op)
+}
+
+func checkWalletStatus(status string, op walletOp) error {
+	switch status {
+	case WalletStatusActive:
+		return nil
+	case WalletStatusDebitBlocked:
+		if op == walletCredit {
+			return nil
+		}
+		return ErrWalletUnavailable{Status: status, Msg: "wallet diblokir untuk transaksi keluar"}
+	case WalletStatusFrozen:
+		return ErrWalletUnavailable{Status: status, Msg: "wallet sedang dibekukan"}
+	case WalletStatusClosed:
+		return ErrWalletUnavailable{Status: status, Msg: "wallet sudah ditutup"}
+	default:
+		return ErrWalletUnavailable{Status: status, Msg: "status wallet tidak dikenal"}
+	}
+}
+
+type ChangeWalletStatusInput struct {
+	UserID    string `json:"-"      validate:"required,uuid4"`
+	Status    string `json:"status" validate:"required,oneof=ACTIVE FROZEN DEBIT_BLOCKED CLOSED"`
+	Reason    string `json:"reason" validate:"required,max=500"`
+	ChangedBy string `json:"-"`
+}
+
+type WalletStatusHistoryDTO struct {
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason"`
+	ChangedBy  *string   `json:"changedBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WalletStatusDTO struct {
+	UserID    string                   `json:"userId"`
+	Status    string                   `json:"status"`
+	Reason    string                   `json:"reason,omitempty"`
+	ChangedAt *time.Time               `json:"changedAt,omitempty"`
+	History   []WalletStatusHistoryDTO `json:"history"`
+}
+
+func (s *WalletService) GetWalletStatus(ctx context.Context, userID string) (WalletStatusDTO, error) {
+	st, err := s.repo.GetWalletStatus(ctx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return WalletStatusDTO{}, ErrNotFoundResource{Msg: "wallet tidak ditemukan"}
+		}
+		return WalletStatusDTO{}, err
+	}
+	rows, err := s.repo.ListWalletStatusHistory(ctx, userID, 50)
+	if err != nil {
+		return WalletStatusDTO{}, err
+	}
+
+	out := WalletStatusDTO{
+		UserID:  st.UserID,
+		Status:  st.Status,
+		Reason:  st.Reason.String,
+		History: make([]WalletStatusHistoryDTO, 0, len(rows)),
+	}
+	if st.ChangedAt.Valid {
+		t := st.ChangedAt.Time
+		out.ChangedAt = &t
+	}
+	for _, row := range rows {
+		var by *string
+		if row.ChangedBy.Valid {
+			v := row.ChangedBy.String
+			by = &v
+		}
+		out.History = append(out.History, WalletStatusHistoryDTO{
+			FromStatus: row.FromStatus,
+			ToStatus:   row.ToStatus,
+			Reason:     row.Reason,
+			ChangedBy:  by,
+			CreatedAt:  row.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+// ChangeWalletStatus dipakai tim support/admin untuk membekukan, memblokir debit,
+// mengaktifkan kembali atau menutup wallet. Wallet CLOSED tidak bisa dibuka lagi.
+func (s *WalletService) ChangeWalletStatus(ctx context.Context, in ChangeWalletStatusInput) (WalletStatusDTO, error) {
+	in.Status = strings.ToUpper(strings.TrimSpace(in.Status))
+	in.Reason = strings.TrimSpace(in.Reason)
+	if err := s.validate.Struct(in); err != nil {
+		return WalletStatusDTO{}, ErrBadRequest{Err: err}
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return WalletStatusDTO{}, err
+	}
+	defer tx.Rollback()
+
+	total, _, _, _, err := s.repo.GetSaldoForUpdate(ctx, tx, in.UserID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return WalletStatusDTO{}, ErrNotFoundResource{Msg: "wallet tidak ditemukan"}
+		}
+		return WalletStatusDTO{}, err
+	}
+	current, err := s.repo.GetWalletStatusTx(ctx, tx, in.UserID)
+	if err != nil {
+		return WalletStatusDTO{}, err
+	}
+	if current.Status == in.Status {
+		return WalletStatusDTO{}, ErrConflict{Msg: fmt.Sprintf("wallet sudah berstatus %s", in.Status)}
+	}
+	if current.Status == WalletStatusClosed {
+		return WalletStatusDTO{}, ErrConflict{Msg: "wallet yang sudah ditutup tidak bisa diubah"}
+	}
+	if in.Status == WalletStatusClosed {
+		heldTopup, heldRedeem, err := s.repo.GetHeldTx(ctx, tx, in.UserID)
+		if err != nil {
+			return WalletStatusDTO{}, err
+		}
+		if !total.IsZero() || !heldTopup.Add(heldRedeem).IsZero() {
+			return WalletStatusDTO{}, ErrConflict{Msg: "saldo dan hold harus nol sebelum wallet ditutup"}
+		}
+	}
+
+	var changedBy *string
+	if in.ChangedBy != "" {
+		changedBy = &in.ChangedBy
+	}
+	if err := s.repo.UpdateWalletStatus(ctx, tx, repositories.UpdateWalletStatusParams{
+		UserID:     in.UserID,
+		FromStatus: current.Status,
+		Status:     in.Status,
+		Reason:     in.Reason,
+		ChangedBy:  changedBy,
+		ChangedAt:  s.now(),
+	}); err != nil {
+		return WalletStatusDTO{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return WalletStatusDTO{}, err
+	}
+	return s.GetWalletStatus(ctx, in.UserID)
+}
+
+// walletStatus membaca status tanpa mengunci; wallet yang belum ada dianggap ACTIVE.
+func (s *WalletService) walletStatus(ctx context.Context, userID string) (string, error) {
+	st, err := s.repo.GetWalletStatus(ctx, userID)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return WalletStatusActive, nil
+		}
+		return "", err
+	}
+	return st.Status, nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hoshichaam/pln_backend_go/internal/repositories"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// wib dipakai untuk batas harian (limit direset tiap 00:00 WIB). Sengaja memakai
+// FixedZone supaya tidak bergantung pada tzdata di image container.
+var wib = time.FixedZone("WIB", 7*60*60)
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(wib)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, wib)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.In(wib)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, wib)
+}
+
+// TransferLimits membatasi transfer antar user. Nilai nol berarti tidak dibatasi.
+type TransferLimits struct {
+	MinAmount   money.Amount
+	MaxAmount   money.Amount
+	DailyAmount money.Amount
+}
+
+type TransferInput struct {
+	UserID      string       `json:"userId"      validate:"required,uuid4"`
+	Recipient   string       `json:"recipient"   validate:"required"` // email atau nomor telepon penerima
+	Jumlah      money.Amount `json:"jumlah"      validate:"required,gt=0"`
+	BalanceType string       `json:"balanceType" validate:"required"`
+	Notes       string       `json:"notes"       validate:"max=255"`
+}
+
+type TransferResult struct {
+	TransferID    string       `json:"transferId"`
+	RecipientID   string       `json:"recipientId"`
+	RecipientName string       `json:"recipientName"`
+	Amount        money.Amount `json:"amount"`
+	BalanceType   string       `json:"balanceType"`
+	Status        string       `json:"status"`
+}
+
+var transferOutTypes = []string{"TRANSFER_KELUAR_TOPUP", "TRANSFER_KELUAR_REDEEM"}
+
+// Transfer memindahkan saldo dari sub-saldo pilihan pengirim ke sub-saldo yang
+// sama milik penerima dalam satu transaksi DB.
+func (s *WalletService) Transfer(ctx context.Context, in TransferInput) (TransferResult, error) {
+	if err := s.validate.Struct(in); err != nil {
+		return TransferResult{}, ErrBadRequest{Err: err}
+	}
+	target, err := parseBalanceTarget(in.BalanceType)
+	if err != nil {
+		return TransferResult{}, err
+	}
+
+	limits := s.cfg.Transfer
+	amount := in.Jumlah
+	if !limits.MinAmount.IsZero() && amount.Cmp(limits.MinAmount) < 0 {
+		return TransferResult{}, ErrBadRequest{Err: fmt.Errorf("minimal transfer Rp%s", limits.MinAmount)}
+	}
+	if !limits.MaxAmount.IsZero() && amount.Cmp(limits.MaxAmount) > 0 {
+		return TransferResult{}, ErrLimitExceeded{Msg: fmt.Sprintf("maksimal transfer Rp%s per transaksi", limits.MaxAmount)}
+	}
+
+	recipient, err := s.repo.FindUserByContact(ctx, in.Recipient)
+	if err != nil {
+		var notFound repositories.ErrNotFound
+		if errors.As(err, &notFound) {
+			return TransferResult{}, ErrNotFoundResource{Msg: "penerima tidak ditemukan"}
+		}
+		return TransferResult{}, err
+	}
+	if recipient.ID == in.UserID {
+		return TransferResult{}, ErrBadRequest{Err: errors.New("tidak bisa transfer ke diri sendiri")}
+	}
+	sender, err := s.repo.GetUserProfile(ctx, in.UserID)
+	if err != nil {
+		return TransferResult{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	defer tx.Rollback()
+
+	// Kunci kedua wallet berurutan user_id supaya transfer A->B dan B->A yang
+	// berjalan bersamaan tidak saling deadlock.
+	var topupBalance, redeemBalance money.Amount
+	first, second := sender.ID, recipient.ID
+	if second < first {
+		first, second = second, first
+	}
+	for _, id := range []string{first, second} {
+		_, topup, redeem, _, err := s.repo.GetSaldoForUpdate(ctx, tx, id)
+		if err != nil {
+			var notFound repositories.ErrNotFound
+			// wallet penerima yang belum ada akan dibuat oleh ledger
+			if errors.As(err, &notFound) && id == recipient.ID {
+				continue
+			}
+			return TransferResult{}, err
+		}
+		if id == sender.ID {
+			heldTopup, heldRedeem, err := s.repo.GetHeldTx(ctx, tx, id)
+			if err != nil {
+				return TransferResult{}, err
+			}
+			topupBalance, redeemBalance = topup.Sub(heldTopup), redeem.Sub(heldRedeem)
+		}
+	}
+	if err := ensureWalletAllows(ctx, s.repo, tx, sender.ID, walletDebit); err != nil {
+		return TransferResult{}, err
+	}
+	if err := ensureWalletAllows(ctx, s.repo, tx, recipient.ID, walletCredit); err != nil {
+		var unavailable ErrWalletUnavailable
+		if errors.As(err, &unavailable) {
+			return TransferResult{}, ErrWalletUnavailable{Status: unavailable.Status, Msg: "wallet penerima tidak dapat menerima transfer"}
+		}
+		return TransferResult{}, err
+	}
+
+	var (
+		account string
+		outType string
+		inType  string
+	)
+	switch target {
+	case "topup":
+		if topupBalance.Cmp(amount) < 0 {
+			return TransferResult{}, ErrInsufficientBalance{Msg: "saldo top up tidak mencukupi"}
+		}
+		account, outType, inType = repositories.LedgerAccountUserTopup, "TRANSFER_KELUAR_TOPUP", "TRANSFER_MASUK_TOPUP"
+	case "redeem":
+		if redeemBalance.Cmp(amount) < 0 {
+			return TransferResult{}, ErrInsufficientBalance{Msg: "saldo redeem tidak mencukupi"}
+		}
+		account, outType, inType = repositories.LedgerAccountUserRedeem, "TRANSFER_KELUAR_REDEEM", "TRANSFER_MASUK_REDEEM"
+	}
+
+	now := s.now()
+	if !limits.DailyAmount.IsZero() {
+		sent, err := s.repo.SumTransactionsSince(ctx, tx, sender.ID, transferOutTypes, startOfDay(now))
+		if err != nil {
+			return TransferResult{}, err
+		}
+		if sent.Add(amount).Cmp(limits.DailyAmount) > 0 {
+			return TransferResult{}, ErrLimitExceeded{Msg: fmt.Sprintf("limit transfer harian Rp%s terlampaui", limits.DailyAmount)}
+		}
+	}
+
+	transferID := fmt.Sprintf("TRF-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
+	outDesc := "Transfer ke " + recipient.Name
+	inDesc := "Transfer dari " + sender.Name
+	if notes := strings.TrimSpace(in.Notes); notes != "" {
+		outDesc += " - " + notes
+		inDesc += " - " + notes
+	}
+
+	outTxnID, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
+		UserID:        sender.ID,
+		TipeTransaksi: outType,
+		Jumlah:        amount,
+		Deskripsi:     outDesc,
+		ReferensiID:   &transferID,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if _, err := createTransaction(ctx, s.repo, tx, repositories.CreateTransactionParams{
+		UserID:        recipient.ID,
+		TipeTransaksi: inType,
+		Jumlah:        amount,
+		Deskripsi:     inDesc,
+		ReferensiID:   &transferID,
+		CreatedAt:     now,
+	}); err != nil {
+		return TransferResult{}, err
+	}
+
+	if _, err := s.ledger.Post(ctx, tx, JournalEntry{
+		EntryType:     outType,
+		TransactionID: &outTxnID,
+		ReferensiID:   &transferID,
+		Deskripsi:     "Transfer antar user",
+		Postings: []LedgerPosting{
+			ledgerDebit(sender.ID, account, amount),
+			ledgerCredit(recipient.ID, account, amount),
+		},
+		CreatedAt: now,
+	}); err != nil {
+		return TransferResult{}, err
+	}
+
+	// saldo redeem yang ditransfer tetap membawa tanggal kedaluwarsa asalnya
+	if target == "redeem" {
+		consumed, err := s.lots.Consume(ctx, tx, sender.ID, repositories.LotKindRedeem, amount)
+		if err != nil {
+			return TransferResult{}, err
+		}
+		if err := s.lots.CreditMirrored(ctx, tx, LotCredit{
+			UserID:     recipient.ID,
+			Kind:       repositories.LotKindRedeem,
+			Quantity:   amount,
+			SourceType: inType,
+			SourceID:   transferID,
+			CreatedAt:  now,
+		}, consumed); err != nil {
+			return TransferResult{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TransferResult{}, err
+	}
+
+	return TransferResult{
+		TransferID:    transferID,
+		RecipientID:   recipient.ID,
+		RecipientName: recipient.Name,
+		Amount:        amount,
+		BalanceType:   target,
+		Status:        "SUCCESS",
+	}, nil
+}
@@ -0,0 +1,222 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+// invalidAccountSuffix: nomor rekening berakhiran ini dianggap tidak ada di bank,
+// untuk mencoba jalur validasi gagal dan payout yang ditolak Iris.
+const invalidAccountSuffix = "0000"
+
+// payout adalah satu payout Iris. Status mengikuti Iris: queued, approved, processed,
+// completed, failed atau rejected.
+type payout struct {
+	Reference       string
+	PartnerTrxID    string
+	Amount          money.Amount
+	BeneficiaryName string
+	Account         string
+	Bank            string
+	Status          string
+	ErrorMessage    string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	LastNotifyError string
+}
+
+func irisError(c *fiber.Ctx, code int, msg string, details ...string) error {
+	return c.Status(code).JSON(fiber.Map{"error_message": msg, "errors": details})
+}
+
+func (m *Midtrans) requireIrisCreator(c *fiber.Ctx) error {
+	user, pass, ok := basicAuth(c)
+	if !ok || user != m.cfg.IrisClientKey || pass != m.cfg.IrisClientSecret {
+		return irisError(c, fiber.StatusUnauthorized, "Unauthorized")
+	}
+	return c.Next()
+}
+
+func (m *Midtrans) requireIrisApprover(c *fiber.Ctx) error {
+	if m.cfg.IrisApproverKey == "" {
+		return irisError(c, fiber.StatusForbidden, "Approval payout tidak aktif di simulator")
+	}
+	user, _, ok := basicAuth(c)
+	if !ok || user != m.cfg.IrisApproverKey {
+		return irisError(c, fiber.StatusUnauthorized, "Unauthorized")
+	}
+	return c.Next()
+}
+
+func (m *Midtrans) irisValidateAccount(c *fiber.Ctx) error {
+	bank, account := c.Query("bank"), c.Query("account")
+	if bank == "" || account == "" {
+		return irisError(c, fiber.StatusBadRequest, "bank dan account wajib diisi")
+	}
+	if strings.HasSuffix(account, invalidAccountSuffix) {
+		return irisError(c, fiber.StatusBadRequest, "Account does not exist")
+	}
+	return c.JSON(fiber.Map{
+		"account_name": "SIMULATOR " + account,
+		"account_no":   account,
+		"bank_name":    strings.ToUpper(bank),
+	})
+}
+
+func (m *Midtrans) irisCreate(c *fiber.Ctx) error {
+	var req struct {
+		Payouts []struct {
+			BeneficiaryName    string       `json:"beneficiary_name"`
+			BeneficiaryAccount string       `json:"beneficiary_account"`
+			BeneficiaryBank    string       `json:"beneficiary_bank"`
+			Amount             money.Amount `json:"amount"`
+			PartnerTrxID       string       `json:"partner_trx_id"`
+		} `json:"payouts"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return irisError(c, fiber.StatusBadRequest, "An error occurred when creating payouts", err.Error())
+	}
+	if len(req.Payouts) == 0 {
+		return irisError(c, fiber.StatusBadRequest, "An error occurred when creating payouts", "payouts wajib diisi")
+	}
+	for i, p := range req.Payouts {
+		if !p.Amount.IsPositive() || p.BeneficiaryAccount == "" || p.BeneficiaryBank == "" {
+			return irisError(c, fiber.StatusBadRequest, "An error occurred when creating payouts", fmt.Sprintf("payouts[%d] tidak lengkap", i))
+		}
+		if strings.HasSuffix(p.BeneficiaryAccount, invalidAccountSuffix) {
+			return irisError(c, fiber.StatusBadRequest, "An error occurred when creating payouts", fmt.Sprintf("payouts[%d]: Account does not exist", i))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	out := make([]fiber.Map, 0, len(req.Payouts))
+	for _, p := range req.Payouts {
+		po := &payout{
+			Reference:       randomHex(10),
+			PartnerTrxID:    p.PartnerTrxID,
+			Amount:          p.Amount,
+			BeneficiaryName: p.BeneficiaryName,
+			Account:         p.BeneficiaryAccount,
+			Bank:            p.BeneficiaryBank,
+			Status:          "queued",
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		m.payouts[po.Reference] = po
+		out = append(out, fiber.Map{"status": po.Status, "reference_no": po.Reference})
+		log.Printf("midtrans simulator: payout %s (%s) dibuat, selesaikan di %s/", po.Reference, po.PartnerTrxID, m.cfg.PublicURL)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"payouts": out})
+}
+
+type irisDecisionRequest struct {
+	ReferenceNos []string `json:"reference_nos"`
+	RejectReason string   `json:"reject_reason"`
+}
+
+func (m *Midtrans) irisApprove(c *fiber.Ctx) error {
+	return m.irisDecide(c, "approved")
+}
+
+func (m *Midtrans) irisReject(c *fiber.Ctx) error {
+	return m.irisDecide(c, "rejected")
+}
+
+func (m *Midtrans) irisDecide(c *fiber.Ctx, status string) error {
+	var req irisDecisionRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return irisError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ref := range req.ReferenceNos {
+		po, ok := m.payouts[ref]
+		if !ok {
+			return irisError(c, fiber.StatusNotFound, "Payout not found", ref)
+		}
+		if po.Status != "queued" {
+			return irisError(c, fiber.StatusBadRequest, "Payout is not in queued status", ref)
+		}
+	}
+	for _, ref := range req.ReferenceNos {
+		po := m.payouts[ref]
+		if err := m.setPayoutStatus(po, status, req.RejectReason); err != nil {
+			return irisError(c, fiber.StatusBadRequest, err.Error(), ref)
+		}
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// setPayoutStatus memindahkan status payout lalu mengirim notifikasinya. Harus
+// dipanggil dengan mu terkunci.
+func (m *Midtrans) setPayoutStatus(po *payout, status, reason string) error {
+	allowed := false
+	switch status {
+	case "approved", "rejected":
+		allowed = po.Status == "queued"
+	case "completed", "failed":
+		// tanpa approval, payout queued langsung diproses
+		allowed = po.Status == "approved" || po.Status == "processed" ||
+			(po.Status == "queued" && m.cfg.IrisApproverKey == "")
+	}
+	if !allowed {
+		return fmt.Errorf("payout berstatus %s tidak bisa diubah ke %s", po.Status, status)
+	}
+	po.Status = status
+	po.ErrorMessage = reason
+	po.UpdatedAt = m.now()
+	m.notifyPayout(po)
+	return nil
+}
+
+// notifyPayout mengirim notifikasi Iris bersignature ke IrisNotifyURL. Harus
+// dipanggil dengan mu terkunci.
+func (m *Midtrans) notifyPayout(po *payout) {
+	payload := fiber.Map{
+		"reference_no": po.Reference,
+		"amount":       grossString(po.Amount),
+		"status":       po.Status,
+		"updated_at":   po.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if po.Status == "failed" || po.Status == "rejected" {
+		payload["error_code"] = "SIMULATOR"
+		payload["error_message"] = po.ErrorMessage
+	}
+	body, _ := json.Marshal(payload)
+	headers := map[string]string{"Iris-Signature": sha512Hex(string(body) + m.cfg.IrisMerchantKey)}
+	ref := po.Reference
+	go func() {
+		err := m.post(m.cfg.IrisNotifyURL, body, headers)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if cur, ok := m.payouts[ref]; ok {
+			cur.LastNotifyError = ""
+			if err != nil {
+				cur.LastNotifyError = err.Error()
+			}
+		}
+	}()
+}
+
+// sortedPayouts mengembalikan salinan payout terbaru dulu untuk halaman indeks.
+func (m *Midtrans) sortedPayouts() []payout {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]payout, 0, len(m.payouts))
+	for _, po := range m.payouts {
+		out = append(out, *po)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
@@ -0,0 +1,571 @@
+// Package simulator berisi tiruan Midtrans (Snap, Core API dan Iris) untuk
+// pengembangan lokal. Semua state disimpan di memori dan hilang saat restart.
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
+)
+
+type Config struct {
+	ServerKey        string
+	CallbackToken    string // dikirim sebagai X-Callback-Token bila diisi
+	IrisClientKey    string
+	IrisClientSecret string
+	IrisApproverKey  string // kosong = payout langsung diproses tanpa approval
+	IrisMerchantKey  string // untuk header Iris-Signature
+	PublicURL        string // alamat simulator yang dibuka browser, mis. http://localhost:3000/simulator/midtrans
+	NotifyURL        string // endpoint notifikasi pembayaran
+	IrisNotifyURL    string // endpoint notifikasi payout
+}
+
+// Midtrans melayani endpoint tiruan Snap, Core API dan Iris, halaman checkout untuk
+// membayar, menolak atau mengakhiri order, serta mengirim notifikasi bersignature
+// seperti Midtrans asli.
+type Midtrans struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	orders  map[string]*order  // per order_id
+	tokens  map[string]string  // snap token ke order_id
+	payouts map[string]*payout // per reference_no
+}
+
+func New(cfg Config) *Midtrans {
+	cfg.PublicURL = strings.TrimRight(cfg.PublicURL, "/")
+	return &Midtrans{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+		orders:  make(map[string]*order),
+		tokens:  make(map[string]string),
+		payouts: make(map[string]*payout),
+	}
+}
+
+// Register memasang semua rute simulator di r, mis. app.Group("/simulator/midtrans").
+func (m *Midtrans) Register(r fiber.Router) {
+	r.Get("/", m.index)
+	r.Get("/checkout/:token", m.checkoutPage)
+	r.Post("/checkout/:token", m.checkoutAction)
+	r.Post("/payouts/:reference", m.payoutAction)
+
+	r.Post("/snap/v1/transactions", m.requireServerKey, m.snapCreate)
+	r.Post("/v2/charge", m.requireServerKey, m.charge)
+	r.Get("/v2/:orderId/status", m.requireServerKey, m.status)
+	r.Post("/v2/:orderId/cancel", m.requireServerKey, m.cancel)
+	r.Post("/v2/:orderId/refund", m.requireServerKey, m.refund)
+
+	r.Get("/iris/api/v1/account_validation", m.requireIrisCreator, m.irisValidateAccount)
+	r.Post("/iris/api/v1/payouts", m.requireIrisCreator, m.irisCreate)
+	r.Post("/iris/api/v1/payouts/approve", m.requireIrisApprover, m.irisApprove)
+	r.Post("/iris/api/v1/payouts/reject", m.requireIrisApprover, m.irisReject)
+}
+
+// order adalah satu transaksi pembayaran. PaymentType kosong berarti order Snap
+// yang metodenya belum dipilih; Midtrans asli belum mengenal transaksinya.
+type order struct {
+	OrderID         string
+	Token           string
+	Gross           money.Amount
+	PaymentType     string
+	TransactionID   string
+	Status          string // pending, capture, settlement, deny, cancel, expire, refund, partial_refund
+	FraudStatus     string
+	Bank            string
+	VANumber        string
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	SettlementTime  time.Time
+	RefundKeys      map[string]bool
+	RefundedAmount  money.Amount
+	Notifications   int
+	LastNotifyError string
+}
+
+func (o *order) paid() bool {
+	return o.Status == "settlement" || o.Status == "capture"
+}
+
+// statusCode meniru status_code Midtrans per transaction_status; nilainya ikut
+// dihitung di signature_key.
+func statusCode(status string) string {
+	switch status {
+	case "pending":
+		return "201"
+	case "deny":
+		return "202"
+	case "expire":
+		return "407"
+	}
+	return "200"
+}
+
+// grossString memformat nominal seperti Midtrans, mis. "10000.00".
+func grossString(a money.Amount) string {
+	return a.RupiahString() + ".00"
+}
+
+func sha512Hex(s string) string {
+	sum := sha512.Sum512([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomDigits(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = '0' + b[i]%10
+	}
+	return string(b)
+}
+
+// expireIfDue mengakhiri order pending yang sudah lewat batas bayar. Seperti Midtrans,
+// notifikasi hanya dikirim untuk transaksi yang metodenya sudah dipilih. Harus
+// dipanggil dengan mu terkunci.
+func (m *Midtrans) expireIfDue(o *order) bool {
+	if o.Status != "pending" || m.now().Before(o.ExpiresAt) {
+		return false
+	}
+	o.Status = "expire"
+	if o.PaymentType != "" {
+		m.notifyPayment(o)
+	}
+	return true
+}
+
+// payload membangun body notifikasi sekaligus respons status API. Harus dipanggil
+// dengan mu terkunci.
+func (m *Midtrans) payload(o *order) fiber.Map {
+	gross := grossString(o.Gross)
+	code := statusCode(o.Status)
+	out := fiber.Map{
+		"transaction_time":   o.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+		"transaction_status": o.Status,
+		"transaction_id":     o.TransactionID,
+		"status_message":     "midtrans simulator",
+		"status_code":        code,
+		"signature_key":      sha512Hex(o.OrderID + code + gross + m.cfg.ServerKey),
+		"payment_type":       o.PaymentType,
+		"order_id":           o.OrderID,
+		"merchant_id":        "SIMULATOR",
+		"gross_amount":       gross,
+		"currency":           "IDR",
+		"expiry_time":        o.ExpiresAt.UTC().Format("2006-01-02 15:04:05"),
+	}
+	if o.FraudStatus != "" {
+		out["fraud_status"] = o.FraudStatus
+	}
+	if !o.SettlementTime.IsZero() {
+		out["settlement_time"] = o.SettlementTime.UTC().Format("2006-01-02 15:04:05")
+	}
+	if o.VANumber != "" {
+		out["va_numbers"] = []fiber.Map{{"bank": o.Bank, "va_number": o.VANumber}}
+	}
+	if !o.RefundedAmount.IsZero() {
+		out["refund_amount"] = grossString(o.RefundedAmount)
+	}
+	return out
+}
+
+// notifyPayment mengirim status order ke NotifyURL di goroutine terpisah. Harus
+// dipanggil dengan mu terkunci.
+func (m *Midtrans) notifyPayment(o *order) {
+	body, _ := json.Marshal(m.payload(o))
+	headers := map[string]string{}
+	if m.cfg.CallbackToken != "" {
+		headers["X-Callback-Token"] = m.cfg.CallbackToken
+	}
+	o.Notifications++
+	orderID := o.OrderID
+	go func() {
+		err := m.post(m.cfg.NotifyURL, body, headers)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if cur, ok := m.orders[orderID]; ok {
+			cur.LastNotifyError = ""
+			if err != nil {
+				cur.LastNotifyError = err.Error()
+			}
+		}
+	}()
+}
+
+func (m *Midtrans) post(url string, body []byte, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		log.Printf("midtrans simulator: notifikasi ke %s gagal: %v", url, err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("notifikasi ke %s dibalas HTTP %d", url, resp.StatusCode)
+		log.Printf("midtrans simulator: %v", err)
+		return err
+	}
+	return nil
+}
+
+// midtransError membalas dengan bentuk error Midtrans; status_code di body sama
+// dengan kode HTTP.
+func midtransError(c *fiber.Ctx, code int, msg string) error {
+	return c.Status(code).JSON(fiber.Map{
+		"status_code":    fmt.Sprint(code),
+		"status_message": msg,
+		"error_messages": []string{msg},
+	})
+}
+
+func (m *Midtrans) requireServerKey(c *fiber.Ctx) error {
+	user, _, ok := basicAuth(c)
+	if !ok || user != m.cfg.ServerKey {
+		return midtransError(c, fiber.StatusUnauthorized, "Access denied due to unauthorized transaction, please check client or server key")
+	}
+	return c.Next()
+}
+
+func basicAuth(c *fiber.Ctx) (user, pass string, ok bool) {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) < 6 || !strings.EqualFold(auth[:6], "basic ") {
+		return "", "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(auth[6:])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(raw), ":")
+}
+
+type transactionDetails struct {
+	OrderID     string       `json:"order_id"`
+	GrossAmount money.Amount `json:"gross_amount"`
+}
+
+// expiryFrom membaca batas bayar dari expiry Snap atau custom_expiry Core API.
+func (m *Midtrans) expiryFrom(start string, duration int, unit string) time.Time {
+	from := m.now()
+	if t, err := time.Parse("2006-01-02 15:04:05 -0700", start); err == nil {
+		from = t
+	}
+	d := 24 * time.Hour
+	if duration > 0 {
+		switch strings.ToLower(unit) {
+		case "second":
+			d = time.Duration(duration) * time.Second
+		case "hour":
+			d = time.Duration(duration) * time.Hour
+		case "day":
+			d = time.Duration(duration) * 24 * time.Hour
+		default:
+			d = time.Duration(duration) * time.Minute
+		}
+	}
+	return from.Add(d)
+}
+
+// newOrder mendaftarkan order baru. Harus dipanggil dengan mu terkunci.
+func (m *Midtrans) newOrder(td transactionDetails, expiresAt time.Time) (*order, error) {
+	if strings.TrimSpace(td.OrderID) == "" || !td.GrossAmount.IsPositive() {
+		return nil, fmt.Errorf("transaction_details.order_id dan gross_amount wajib diisi")
+	}
+	if _, exists := m.orders[td.OrderID]; exists {
+		return nil, fmt.Errorf("transaction_details.order_id sudah digunakan")
+	}
+	o := &order{
+		OrderID:       td.OrderID,
+		Token:         randomHex(16),
+		Gross:         td.GrossAmount,
+		TransactionID: randomHex(16),
+		Status:        "pending",
+		CreatedAt:     m.now(),
+		ExpiresAt:     expiresAt,
+		RefundKeys:    make(map[string]bool),
+	}
+	m.orders[o.OrderID] = o
+	m.tokens[o.Token] = o.OrderID
+	return o, nil
+}
+
+func (m *Midtrans) checkoutURL(o *order) string {
+	return m.cfg.PublicURL + "/checkout/" + o.Token
+}
+
+func (m *Midtrans) snapCreate(c *fiber.Ctx) error {
+	var req struct {
+		TransactionDetails transactionDetails `json:"transaction_details"`
+		Expiry             *struct {
+			StartTime string `json:"start_time"`
+			Unit      string `json:"unit"`
+			Duration  int    `json:"duration"`
+		} `json:"expiry"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return midtransError(c, fiber.StatusBadRequest, err.Error())
+	}
+	expiresAt := m.expiryFrom("", 0, "")
+	if req.Expiry != nil {
+		expiresAt = m.expiryFrom(req.Expiry.StartTime, req.Expiry.Duration, req.Expiry.Unit)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, err := m.newOrder(req.TransactionDetails, expiresAt)
+	if err != nil {
+		return midtransError(c, fiber.StatusBadRequest, err.Error())
+	}
+	log.Printf("midtrans simulator: order %s dibuat, bayar di %s", o.OrderID, m.checkoutURL(o))
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":        o.Token,
+		"redirect_url": m.checkoutURL(o),
+	})
+}
+
+func (m *Midtrans) charge(c *fiber.Ctx) error {
+	var req struct {
+		PaymentType        string             `json:"payment_type"`
+		TransactionDetails transactionDetails `json:"transaction_details"`
+		BankTransfer       *struct {
+			Bank string `json:"bank"`
+		} `json:"bank_transfer"`
+		CustomExpiry *struct {
+			OrderTime      string `json:"order_time"`
+			ExpiryDuration int    `json:"expiry_duration"`
+			Unit           string `json:"unit"`
+		} `json:"custom_expiry"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return midtransError(c, fiber.StatusBadRequest, err.Error())
+	}
+	expiresAt := m.expiryFrom("", 0, "")
+	if req.CustomExpiry != nil {
+		expiresAt = m.expiryFrom(req.CustomExpiry.OrderTime, req.CustomExpiry.ExpiryDuration, req.CustomExpiry.Unit)
+	}
+	bank := ""
+	switch req.PaymentType {
+	case "bank_transfer":
+		if req.BankTransfer == nil || req.BankTransfer.Bank == "" {
+			return midtransError(c, fiber.StatusBadRequest, "bank_transfer.bank wajib diisi")
+		}
+		bank = strings.ToLower(req.BankTransfer.Bank)
+	case "echannel":
+		bank = "mandiri"
+	case "qris", "gopay":
+	default:
+		return midtransError(c, fiber.StatusBadRequest, fmt.Sprintf("payment_type %q tidak didukung simulator", req.PaymentType))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, err := m.newOrder(req.TransactionDetails, expiresAt)
+	if err != nil {
+		return midtransError(c, fiber.StatusBadRequest, err.Error())
+	}
+	o.PaymentType = req.PaymentType
+	o.Bank = bank
+
+	res := m.payload(o)
+	res["status_message"] = "Success, transaction is created"
+	delete(res, "signature_key")
+	switch {
+	case req.PaymentType == "echannel":
+		res["bill_key"] = randomDigits(12)
+		res["biller_code"] = "70012"
+	case bank == "permata":
+		o.VANumber = randomDigits(16)
+		res["permata_va_number"] = o.VANumber
+	case req.PaymentType == "bank_transfer":
+		o.VANumber = randomDigits(16)
+		res["va_numbers"] = []fiber.Map{{"bank": bank, "va_number": o.VANumber}}
+	case req.PaymentType == "qris":
+		res["qr_string"] = "SIMULATOR-QRIS-" + o.OrderID
+		res["actions"] = []fiber.Map{{"name": "generate-qr-code", "method": "GET", "url": m.checkoutURL(o)}}
+	case req.PaymentType == "gopay":
+		res["qr_string"] = "SIMULATOR-GOPAY-" + o.OrderID
+		res["actions"] = []fiber.Map{
+			{"name": "generate-qr-code", "method": "GET", "url": m.checkoutURL(o)},
+			{"name": "deeplink-redirect", "method": "GET", "url": m.checkoutURL(o)},
+		}
+	}
+	log.Printf("midtrans simulator: charge %s %s dibuat, bayar di %s", req.PaymentType, o.OrderID, m.checkoutURL(o))
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+// lookup mengembalikan order yang sudah dikenal Midtrans; order Snap yang metodenya
+// belum dipilih dianggap belum ada. Harus dipanggil dengan mu terkunci.
+func (m *Midtrans) lookup(orderID string) (*order, bool) {
+	o, ok := m.orders[orderID]
+	if !ok || o.PaymentType == "" {
+		return nil, false
+	}
+	return o, true
+}
+
+func (m *Midtrans) status(c *fiber.Ctx) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.lookup(c.Params("orderId"))
+	if !ok {
+		return midtransError(c, fiber.StatusNotFound, "Transaction doesn't exist.")
+	}
+	m.expireIfDue(o)
+	return c.JSON(m.payload(o))
+}
+
+func (m *Midtrans) cancel(c *fiber.Ctx) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.lookup(c.Params("orderId"))
+	if !ok {
+		return midtransError(c, fiber.StatusNotFound, "Transaction doesn't exist.")
+	}
+	if m.expireIfDue(o) || o.Status != "pending" {
+		return midtransError(c, fiber.StatusPreconditionFailed, "Merchant cannot modify the status of the transaction")
+	}
+	o.Status = "cancel"
+	m.notifyPayment(o)
+	return c.JSON(m.payload(o))
+}
+
+func (m *Midtrans) refund(c *fiber.Ctx) error {
+	var req struct {
+		RefundKey string       `json:"refund_key"`
+		Amount    money.Amount `json:"amount"`
+		Reason    string       `json:"reason"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return midtransError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.lookup(c.Params("orderId"))
+	if !ok {
+		return midtransError(c, fiber.StatusNotFound, "Transaction doesn't exist.")
+	}
+	// refund_key yang sama tidak diproses dua kali
+	if req.RefundKey != "" && o.RefundKeys[req.RefundKey] {
+		return c.JSON(m.refundResponse(o, req.RefundKey))
+	}
+	if !o.paid() && o.Status != "partial_refund" {
+		return midtransError(c, fiber.StatusPreconditionFailed, "Transaction status cannot be updated")
+	}
+	amount := req.Amount
+	if amount.IsZero() {
+		amount = o.Gross.Sub(o.RefundedAmount)
+	}
+	if !amount.IsPositive() || o.RefundedAmount.Add(amount).Cmp(o.Gross) > 0 {
+		return midtransError(c, fiber.StatusPreconditionFailed, "Refund amount exceeds the transaction amount")
+	}
+	o.RefundedAmount = o.RefundedAmount.Add(amount)
+	o.Status = "partial_refund"
+	if o.RefundedAmount == o.Gross {
+		o.Status = "refund"
+	}
+	if req.RefundKey != "" {
+		o.RefundKeys[req.RefundKey] = true
+	}
+	m.notifyPayment(o)
+	return c.JSON(m.refundResponse(o, req.RefundKey))
+}
+
+func (m *Midtrans) refundResponse(o *order, key string) fiber.Map {
+	return fiber.Map{
+		"status_code":        "200",
+		"status_message":     "Success, refund request is approved",
+		"transaction_id":     o.TransactionID,
+		"order_id":           o.OrderID,
+		"transaction_status": o.Status,
+		"refund_key":         key,
+		"refund_amount":      grossString(o.RefundedAmount),
+	}
+}
+
+// applyCheckout menjalankan aksi pay, deny atau expire dari halaman checkout. Harus
+// dipanggil dengan mu terkunci.
+func (m *Midtrans) applyCheckout(o *order, action, paymentType string) error {
+	if action != "pay" && action != "deny" && action != "expire" {
+		return fmt.Errorf("aksi %q tidak dikenal", action)
+	}
+	if m.expireIfDue(o) {
+		return fmt.Errorf("order sudah kedaluwarsa")
+	}
+	if o.Status != "pending" {
+		return fmt.Errorf("order sudah berstatus %s", o.Status)
+	}
+	if o.PaymentType == "" {
+		o.PaymentType = firstNonEmpty(paymentType, "bank_transfer")
+	}
+	switch action {
+	case "pay":
+		now := m.now()
+		o.SettlementTime = now
+		o.Status = "settlement"
+		if o.PaymentType == "credit_card" {
+			o.Status = "capture"
+			o.FraudStatus = "accept"
+		}
+	case "deny":
+		o.Status = "deny"
+		o.FraudStatus = "deny"
+	case "expire":
+		o.Status = "expire"
+	}
+	m.notifyPayment(o)
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// sortedOrders mengembalikan salinan order terbaru dulu untuk halaman indeks.
+func (m *Midtrans) sortedOrders() []order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]order, 0, len(m.orders))
+	for _, o := range m.orders {
+		m.expireIfDue(o)
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
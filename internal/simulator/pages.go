@@ -0,0 +1,164 @@
+package simulator
+
+import (
+	"bytes"
+	"html/template"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var pages = template.Must(template.New("pages").Funcs(template.FuncMap{
+	"rupiah": grossString,
+}).Parse(`
+{{define "head"}}<!doctype html>
+<html><head><meta charset="utf-8"><title>Midtrans Simulator</title>
+<style>
+body{font-family:sans-serif;max-width:960px;margin:2rem auto;padding:0 1rem}
+table{border-collapse:collapse;width:100%}td,th{border:1px solid #ccc;padding:.3rem .5rem;text-align:left}
+.error{color:#b00020}.muted{color:#666}form{display:inline}button{margin-right:.3rem}
+</style></head><body>
+<h1><a href="{{.Base}}/">Midtrans Simulator</a></h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{end}}
+
+{{define "index"}}{{template "head" .}}
+<h2>Pembayaran</h2>
+{{if .Orders}}<table>
+<tr><th>Order</th><th>Nominal</th><th>Metode</th><th>Status</th><th>Notifikasi</th><th></th></tr>
+{{range .Orders}}<tr>
+<td>{{.OrderID}}</td><td>{{rupiah .Gross}}</td><td>{{or .PaymentType "-"}}</td><td>{{.Status}}</td>
+<td>{{.Notifications}}{{if .LastNotifyError}} <span class="error">{{.LastNotifyError}}</span>{{end}}</td>
+<td><a href="{{$.Base}}/checkout/{{.Token}}">buka</a></td>
+</tr>{{end}}
+</table>{{else}}<p class="muted">Belum ada order.</p>{{end}}
+
+<h2>Payout</h2>
+{{if .Payouts}}<table>
+<tr><th>Reference</th><th>Partner trx</th><th>Nominal</th><th>Rekening</th><th>Status</th><th></th></tr>
+{{range .Payouts}}<tr>
+<td>{{.Reference}}</td><td>{{.PartnerTrxID}}</td><td>{{rupiah .Amount}}</td>
+<td>{{.Bank}} {{.Account}} {{.BeneficiaryName}}</td>
+<td>{{.Status}}{{if .LastNotifyError}} <span class="error">{{.LastNotifyError}}</span>{{end}}</td>
+<td>{{if or (eq .Status "queued") (eq .Status "approved") (eq .Status "processed")}}
+<form method="post" action="{{$.Base}}/payouts/{{.Reference}}">
+{{if and $.Approval (eq .Status "queued")}}<button name="action" value="approve">approve</button><button name="action" value="reject">reject</button>
+{{else}}<button name="action" value="complete">complete</button><button name="action" value="fail">fail</button>{{end}}
+</form>{{end}}</td>
+</tr>{{end}}
+</table>{{else}}<p class="muted">Belum ada payout.</p>{{end}}
+</body></html>{{end}}
+
+{{define "checkout"}}{{template "head" .}}
+{{with .Order}}
+<h2>Order {{.OrderID}}</h2>
+<table>
+<tr><th>Nominal</th><td>{{rupiah .Gross}}</td></tr>
+<tr><th>Metode</th><td>{{or .PaymentType "belum dipilih"}}{{if .VANumber}} ({{.Bank}} VA {{.VANumber}}){{end}}</td></tr>
+<tr><th>Status</th><td>{{.Status}}{{if .FraudStatus}} / {{.FraudStatus}}{{end}}</td></tr>
+<tr><th>Batas bayar</th><td>{{.ExpiresAt.Format "2006-01-02 15:04:05 -0700"}}</td></tr>
+<tr><th>Notifikasi</th><td>{{.Notifications}} terkirim{{if .LastNotifyError}} <span class="error">{{.LastNotifyError}}</span>{{end}}</td></tr>
+</table>
+{{if eq .Status "pending"}}
+<form method="post">
+{{if not .PaymentType}}<p><label>Metode <select name="payment_type">
+<option value="bank_transfer">bank_transfer</option><option value="qris">qris</option>
+<option value="gopay">gopay</option><option value="credit_card">credit_card</option>
+</select></label></p>{{end}}
+<p><button name="action" value="pay">Bayar</button><button name="action" value="deny">Tolak</button><button name="action" value="expire">Kedaluwarsakan</button></p>
+</form>
+{{end}}
+{{end}}
+</body></html>{{end}}
+`))
+
+func (m *Midtrans) render(c *fiber.Ctx, name string, data fiber.Map) error {
+	data["Base"] = m.cfg.PublicURL
+	data["Error"] = c.Query("error")
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	c.Type("html")
+	return c.Send(buf.Bytes())
+}
+
+// back mengarahkan browser kembali ke path setelah aksi form, membawa pesan error
+// bila ada.
+func (m *Midtrans) back(c *fiber.Ctx, path string, err error) error {
+	target := m.cfg.PublicURL + path
+	if err != nil {
+		target += "?error=" + url.QueryEscape(err.Error())
+	}
+	return c.Redirect(target, fiber.StatusSeeOther)
+}
+
+func (m *Midtrans) index(c *fiber.Ctx) error {
+	return m.render(c, "index", fiber.Map{
+		"Orders":   m.sortedOrders(),
+		"Payouts":  m.sortedPayouts(),
+		"Approval": m.cfg.IrisApproverKey != "",
+	})
+}
+
+func (m *Midtrans) orderByToken(token string) (*order, bool) {
+	o, ok := m.orders[m.tokens[token]]
+	return o, ok
+}
+
+func (m *Midtrans) checkoutPage(c *fiber.Ctx) error {
+	m.mu.Lock()
+	o, ok := m.orderByToken(c.Params("token"))
+	var snapshot order
+	if ok {
+		m.expireIfDue(o)
+		snapshot = *o
+	}
+	m.mu.Unlock()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("order tidak ditemukan")
+	}
+	return m.render(c, "checkout", fiber.Map{"Order": snapshot})
+}
+
+func (m *Midtrans) checkoutAction(c *fiber.Ctx) error {
+	token := c.Params("token")
+	m.mu.Lock()
+	o, ok := m.orderByToken(token)
+	var err error
+	if ok {
+		err = m.applyCheckout(o, c.FormValue("action"), c.FormValue("payment_type"))
+	}
+	m.mu.Unlock()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("order tidak ditemukan")
+	}
+	return m.back(c, "/checkout/"+token, err)
+}
+
+// payoutAction meniru keputusan di dashboard Iris (approve/reject) dan hasil
+// transfer bank (complete/fail).
+func (m *Midtrans) payoutAction(c *fiber.Ctx) error {
+	m.mu.Lock()
+	po, ok := m.payouts[c.Params("reference")]
+	var err error
+	if ok {
+		switch c.FormValue("action") {
+		case "approve":
+			err = m.setPayoutStatus(po, "approved", "")
+		case "reject":
+			err = m.setPayoutStatus(po, "rejected", "Ditolak di simulator")
+		case "complete":
+			err = m.setPayoutStatus(po, "completed", "")
+		case "fail":
+			err = m.setPayoutStatus(po, "failed", "Transfer gagal di simulator")
+		default:
+			err = fiber.NewError(fiber.StatusBadRequest, "aksi tidak dikenal")
+		}
+	}
+	m.mu.Unlock()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("payout tidak ditemukan")
+	}
+	return m.back(c, "/", err)
+}
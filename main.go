@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -16,9 +17,12 @@ import (
 
 	"github.com/hoshichaam/pln_backend_go/internal/database"
 	"github.com/hoshichaam/pln_backend_go/internal/handlers"
+	"github.com/hoshichaam/pln_backend_go/internal/jobs"
 	"github.com/hoshichaam/pln_backend_go/internal/middleware"
 	"github.com/hoshichaam/pln_backend_go/internal/repositories"
 	"github.com/hoshichaam/pln_backend_go/internal/services"
+	"github.com/hoshichaam/pln_backend_go/internal/simulator"
+	"github.com/hoshichaam/pln_backend_go/pkg/money"
 	myvalidator "github.com/hoshichaam/pln_backend_go/pkg/validator"
 )
 
@@ -43,34 +47,163 @@ func main() {
 	// 4) Init dependencies
 	v := myvalidator.New()
 	repo := repositories.NewWalletRepo(database.DB)
+	ledgerSvc := services.NewLedgerService(repositories.NewLedgerRepo(database.DB))
+	lotRepo := repositories.NewLotRepo(database.DB)
+	lotSvc := services.NewLotService(lotRepo, services.LotConfig{
+		RedeemTTL: envDuration("REDEEM_BALANCE_TTL", 365*24*time.Hour),
+		PointsTTL: envDuration("EV_POIN_TTL", 365*24*time.Hour),
+	})
+	pointsSvc := services.NewPointsService(repositories.NewPointsRepo(database.DB), lotSvc)
 
-	midtransServerKey := strings.TrimSpace(os.Getenv("MIDTRANS_SERVER_KEY"))
-	if midtransServerKey == "" {
-		log.Println("warning: MIDTRANS_SERVER_KEY kosong, integrasi Midtrans Snap dimatikan")
-	}
-	snapBaseURL := strings.TrimSpace(os.Getenv("MIDTRANS_SNAP_BASE_URL"))
-	var snapClient *services.SnapClient
-	if midtransServerKey != "" {
-		snapClient = services.NewSnapClient(midtransServerKey, snapBaseURL)
+	port := strings.TrimSpace(os.Getenv("PORT"))
+	if port == "" {
+		port = "3000"
 	}
 
+	midtransServerKey := strings.TrimSpace(os.Getenv("MIDTRANS_SERVER_KEY"))
+	midtransCallbackToken := strings.TrimSpace(os.Getenv("MIDTRANS_CALLBACK_TOKEN"))
+	snapBaseURL := strings.TrimSpace(os.Getenv("MIDTRANS_SNAP_BASE_URL"))
+	midtransAPIBaseURL := strings.TrimSpace(os.Getenv("MIDTRANS_API_BASE_URL"))
 	irisClientKey := strings.TrimSpace(os.Getenv("MIDTRANS_IRIS_CLIENT_KEY"))
 	irisClientSecret := strings.TrimSpace(os.Getenv("MIDTRANS_IRIS_CLIENT_SECRET"))
 	irisBaseURL := strings.TrimSpace(os.Getenv("MIDTRANS_IRIS_BASE_URL"))
 	if irisBaseURL == "" {
 		irisBaseURL = "https://app.sandbox.midtrans.com/iris/api/v1/payouts"
 	}
-	var irisClient *services.IrisClient
+	irisMerchantKey := strings.TrimSpace(os.Getenv("MIDTRANS_IRIS_MERCHANT_KEY"))
+	irisApproverKey := strings.TrimSpace(os.Getenv("MIDTRANS_IRIS_APPROVER_KEY"))
+
+	// Mode simulator: Snap, Core API dan Iris dilayani tiruan di dalam binary ini
+	// (lihat /simulator/midtrans), notifikasinya dikirim balik ke server ini sendiri.
+	// Hanya boleh di APP_ENV=development; env lain (termasuk kosong) menolak start.
+	var midtransSim *simulator.Midtrans
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("MIDTRANS_SIMULATOR")), "true") {
+		if !isDev() {
+			log.Fatalf("MIDTRANS_SIMULATOR hanya boleh aktif di APP_ENV=development (APP_ENV=%q)", os.Getenv("APP_ENV"))
+		}
+		// MIDTRANS_SIMULATOR_URL diisi bila server diakses lewat host lain, mis. di docker
+		selfURL := strings.TrimRight(envString("MIDTRANS_SIMULATOR_URL", "http://localhost:"+port), "/")
+		simBaseURL := selfURL + "/simulator/midtrans"
+		midtransServerKey = firstNonEmpty(midtransServerKey, "SB-Mid-server-simulator")
+		irisClientKey = firstNonEmpty(irisClientKey, "iris-simulator-creator")
+		irisClientSecret = firstNonEmpty(irisClientSecret, "iris-simulator-secret")
+		irisMerchantKey = firstNonEmpty(irisMerchantKey, "iris-simulator-merchant")
+		snapBaseURL = simBaseURL + "/snap/v1/transactions"
+		midtransAPIBaseURL = simBaseURL + "/v2"
+		irisBaseURL = simBaseURL + "/iris/api/v1/payouts"
+		midtransSim = simulator.New(simulator.Config{
+			ServerKey:        midtransServerKey,
+			CallbackToken:    midtransCallbackToken,
+			IrisClientKey:    irisClientKey,
+			IrisClientSecret: irisClientSecret,
+			IrisApproverKey:  irisApproverKey,
+			IrisMerchantKey:  irisMerchantKey,
+			PublicURL:        simBaseURL,
+			NotifyURL:        selfURL + "/api/v1/midtrans/notify",
+			IrisNotifyURL:    selfURL + "/api/v1/iris/notify",
+		})
+		log.Printf("warning: MIDTRANS_SIMULATOR aktif, pembayaran dan payout tidak diteruskan ke Midtrans (dashboard: %s/)", simBaseURL)
+	}
+
+	if midtransServerKey == "" {
+		log.Println("warning: MIDTRANS_SERVER_KEY kosong, integrasi Midtrans Snap dimatikan")
+	}
+	var paymentGateways []services.PaymentGateway
+	if midtransServerKey != "" {
+		paymentGateways = append(paymentGateways, services.NewMidtransGateway(
+			services.NewSnapClient(midtransServerKey, snapBaseURL),
+			services.NewMidtransCoreClient(midtransServerKey, midtransAPIBaseURL),
+			services.MidtransGatewayConfig{
+				ServerKey:        midtransServerKey,
+				CallbackToken:    midtransCallbackToken,
+				GoPayCallbackURL: strings.TrimSpace(os.Getenv("MIDTRANS_GOPAY_CALLBACK_URL")),
+			},
+		))
+	}
+	// provider lain cukup ditambahkan ke daftar di atas; order baru dipilihkan gateway
+	// per channel, mis. PAYMENT_PROVIDER_ROUTES="QRIS=xendit"
+	gateways := services.NewPaymentGateways(services.PaymentRouting{
+		Default:  envString("PAYMENT_PROVIDER_DEFAULT", services.ProviderMidtrans),
+		Channels: envRoutes("PAYMENT_PROVIDER_ROUTES", strings.ToUpper),
+	}, paymentGateways...)
+
+	var payoutProviderList []services.PayoutProvider
 	if irisClientKey != "" && irisClientSecret != "" {
-		irisClient = services.NewIrisClient(irisClientKey, irisClientSecret, irisBaseURL)
+		irisClient := services.NewIrisClient(irisClientKey, irisClientSecret, irisBaseURL)
+		// akun Iris dengan maker-checker butuh kunci approver terpisah
+		irisClient.ApproverKey = irisApproverKey
+		payoutProviderList = append(payoutProviderList, services.NewIrisPayoutProvider(irisClient, irisMerchantKey))
+		if irisMerchantKey == "" {
+			log.Println("warning: MIDTRANS_IRIS_MERCHANT_KEY kosong, notifikasi Iris akan ditolak")
+		}
+	}
+	// mis. PAYOUT_PROVIDER_ROUTES="bca=bca_h2h" untuk host-to-host ke bank tertentu
+	payoutRouting := services.PayoutRouting{
+		Default: envString("PAYOUT_PROVIDER_DEFAULT", services.ProviderMidtrans),
+		Banks:   envRoutes("PAYOUT_PROVIDER_ROUTES", strings.ToLower),
 	}
+	payoutProviders := services.NewPayoutProviders(payoutRouting, payoutProviderList...)
 
-	callbackToken := strings.TrimSpace(os.Getenv("MIDTRANS_CALLBACK_TOKEN"))
+	limitSvc := services.NewLimitService(repositories.NewLimitRepo(database.DB))
+	beneficiaryRepo := repositories.NewBeneficiaryRepo(database.DB)
+	walletSvc := services.NewWalletService(repo, ledgerSvc, pointsSvc, lotSvc, limitSvc, beneficiaryRepo, v, gateways, services.WalletConfig{
+		Transfer: services.TransferLimits{
+			MinAmount:   envAmount("TRANSFER_MIN_AMOUNT", money.FromRupiah(10000)),
+			MaxAmount:   envAmount("TRANSFER_MAX_AMOUNT", money.FromRupiah(5000000)),
+			DailyAmount: envAmount("TRANSFER_DAILY_AMOUNT", money.FromRupiah(10000000)),
+		},
+		Points: services.PointsRedeemConfig{
+			RupiahPerPoint: envAmount("POINTS_REDEEM_RATE", money.FromRupiah(100)),
+			MinPoints:      envInt("POINTS_REDEEM_MIN", 100),
+		},
+		PendingTopUp: services.PendingTopUpConfig{
+			Expiry:      envDuration("TOPUP_EXPIRY", 24*time.Hour),
+			ExpiryGrace: envDuration("TOPUP_EXPIRY_GRACE", 30*time.Minute),
+			StaleAfter:  envDuration("TOPUP_STALE_AFTER", 15*time.Minute),
+			MaxAge:      envDuration("TOPUP_RECONCILE_MAX_AGE", 72*time.Hour),
+		},
+		Payouts: payoutRouting,
+	})
 
-	walletSvc := services.NewWalletService(repo, v, snapClient, irisClient, midtransServerKey, callbackToken)
+	idemTTL := 24 * time.Hour
+	if raw := strings.TrimSpace(os.Getenv("IDEMPOTENCY_KEY_TTL")); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			idemTTL = d
+		} else {
+			log.Printf("warning: IDEMPOTENCY_KEY_TTL=%q tidak valid, pakai default %s", raw, idemTTL)
+		}
+	}
+	idemSvc := services.NewIdempotencyService(repositories.NewIdempotencyRepo(database.DB), idemTTL,
+		envDuration("IDEMPOTENCY_LEASE", 2*time.Minute))
+
+	holdSvc := services.NewHoldService(repositories.NewHoldRepo(database.DB), repo, ledgerSvc, pointsSvc, lotSvc, v, services.HoldConfig{
+		DefaultTTL: envDuration("HOLD_DEFAULT_TTL", 2*time.Hour),
+		MaxTTL:     envDuration("HOLD_MAX_TTL", 24*time.Hour),
+	})
+
+	expirySvc := services.NewExpiryService(lotRepo, repo, ledgerSvc, pointsSvc)
+
+	reconSvc := services.NewReconciliationService(repositories.NewReconciliationRepo(database.DB), repo, ledgerSvc, pointsSvc)
+
+	statementSvc := services.NewStatementService(repositories.NewStatementRepo(database.DB), repo, v)
+	reversalSvc := services.NewReversalService(repositories.NewReversalRepo(database.DB), repo, ledgerSvc, lotSvc, v)
+	topUpRefundSvc := services.NewTopUpRefundService(repo, reversalSvc, ledgerSvc, limitSvc, gateways, v)
+	beneficiarySvc := services.NewBeneficiaryService(beneficiaryRepo, payoutProviders, v)
+	payoutSvc := services.NewPayoutService(repo, reversalSvc, limitSvc, payoutProviders, v, services.PayoutConfig{
+		MaxAttempts:       envInt("PAYOUT_MAX_ATTEMPTS", 5),
+		BaseBackoff:       envDuration("PAYOUT_RETRY_BACKOFF", time.Minute),
+		MaxBackoff:        envDuration("PAYOUT_RETRY_MAX_BACKOFF", time.Hour),
+		ApprovalThreshold: envAmount("PAYOUT_APPROVAL_THRESHOLD", money.FromRupiah(5000000)),
+	})
 
 	// 5) Init handlers
-	walletHandler := handlers.NewWalletHandler(walletSvc)
+	walletHandler := handlers.NewWalletHandler(walletSvc, idemSvc)
+	holdHandler := handlers.NewHoldHandler(holdSvc, idemSvc)
+	statementHandler := handlers.NewStatementHandler(statementSvc)
+	reversalHandler := handlers.NewReversalHandler(reversalSvc, idemSvc)
+	topUpRefundHandler := handlers.NewTopUpRefundHandler(topUpRefundSvc, idemSvc)
+	payoutHandler := handlers.NewPayoutHandler(payoutSvc, idemSvc)
+	beneficiaryHandler := handlers.NewBeneficiaryHandler(beneficiarySvc)
 	authHandler := handlers.NewAuthHandler(secret)
 
 	// 6) Fiber app dengan timeout & proxy aware (untuk IP akurat di balik reverse proxy)
@@ -100,7 +233,7 @@ func main() {
 	}
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     allowOrigins,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
 		AllowCredentials: true,
 	}))
@@ -116,10 +249,31 @@ func main() {
 	api.Post("/tarik-saldo", walletHandler.Withdraw)
 	api.Get("/wallet/transactions/:userId", walletHandler.GetTransactions)
 	api.Get("/wallet/vouchers/:userId", walletHandler.ListVouchers)
+	api.Get("/wallet/points/:userId", walletHandler.GetPointsHistory)
+	api.Get("/wallet/expiring/:userId", walletHandler.ExpiringSoon)
+	api.Get("/wallet/limits/:userId", walletHandler.GetLimits)
+	api.Get("/wallet/statements/:userId", middleware.JWTRequired(secret), middleware.SelfOrAdminRequired(database.DB, "userId"), statementHandler.Export)
+	// create memanggil ValidateAccount ke provider payout, jadi dibatasi per user
+	beneficiaries := api.Group("/wallet/beneficiaries", middleware.JWTRequired(secret))
+	beneficiaries.Get("/:userId", beneficiaryHandler.List)
+	beneficiaries.Post("/", middleware.UserRateLimit(envInt("BENEFICIARY_CREATE_LIMIT", 10), envDuration("BENEFICIARY_CREATE_WINDOW", time.Hour)), beneficiaryHandler.Create)
+	beneficiaries.Delete("/:userId/:beneficiaryId", beneficiaryHandler.Delete)
+	api.Post("/wallet/points/redeem", walletHandler.RedeemPoints)
 	api.Post("/wallet/topup", walletHandler.TopUp)
 	api.Post("/topup", walletHandler.TopUp)
+	api.Post("/wallet/topup/charge", walletHandler.ChargeTopUp)
+	api.Post("/wallet/transfer", middleware.JWTRequired(secret), walletHandler.Transfer)
+	holds := api.Group("/wallet/holds", middleware.JWTRequired(secret))
+	holds.Post("/", holdHandler.Create)
+	holds.Get("/:holdId", holdHandler.Get)
+	holds.Post("/:holdId/capture", holdHandler.Capture)
+	holds.Post("/:holdId/void", holdHandler.Void)
 	api.Get("/payment/status/:orderId", walletHandler.GetPaymentStatus)
-	api.Post("/midtrans/notify", walletHandler.MidtransNotification)
+	api.Post("/wallet/topup/:orderId/cancel", walletHandler.CancelTopUp)
+	api.Post("/payments/:provider/notify", walletHandler.PaymentNotification)
+	api.Post("/payouts/:provider/notify", payoutHandler.PayoutNotification)
+	api.Post("/midtrans/notify", walletHandler.PaymentNotification)
+	api.Post("/iris/notify", payoutHandler.PayoutNotification)
 
 	// auth
 	api.Post("/auth/register", authHandler.Register)
@@ -129,14 +283,99 @@ func main() {
 	api.Post("/auth/forgot-password", authHandler.ForgotPassword)
 	api.Post("/auth/reset-password", middleware.JWTOptional(secret), authHandler.ResetPassword)
 
-	// 9) Server start
-	port := strings.TrimSpace(os.Getenv("PORT"))
-	if port == "" {
-		port = "3000"
+	// admin
+	admin := api.Group("/admin", middleware.JWTRequired(secret), middleware.AdminRequired(database.DB))
+	admin.Get("/wallets/:userId/status", walletHandler.GetWalletStatus)
+	admin.Put("/wallets/:userId/status", walletHandler.ChangeWalletStatus)
+	admin.Post("/transactions/:transactionId/reverse", reversalHandler.Reverse)
+	admin.Post("/topups/:orderId/refund", topUpRefundHandler.Refund)
+	admin.Get("/payouts/approvals", payoutHandler.ListApprovals)
+	admin.Post("/payouts/:payoutId/approve", payoutHandler.Approve)
+	admin.Post("/payouts/:payoutId/reject", payoutHandler.Reject)
+	admin.Get("/payouts/unknown", payoutHandler.ListUnknown)
+	admin.Post("/payouts/:payoutId/resolve", payoutHandler.Resolve)
+
+	if midtransSim != nil {
+		midtransSim.Register(app.Group("/simulator/midtrans"))
 	}
+
+	// 9) Server start
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("Starting server on %s (CORS origins: %s)", addr, allowOrigins)
 
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	scheduler := jobs.NewScheduler()
+
+	// RECONCILE_INTERVAL kosong = rekonsiliasi terjadwal dimatikan (tetap bisa via cmd/reconcile)
+	if raw := strings.TrimSpace(os.Getenv("RECONCILE_INTERVAL")); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("RECONCILE_INTERVAL=%q tidak valid: %v", raw, err)
+		}
+		apply := strings.EqualFold(strings.TrimSpace(os.Getenv("RECONCILE_APPLY")), "true")
+		scheduler.Every(jobsCtx, "reconcile", interval, func(ctx context.Context) error {
+			report, err := reconSvc.Run(ctx, services.ReconcileOptions{Apply: apply, TriggeredBy: "scheduler"})
+			log.Printf("job reconcile: run=%s user=%d selisih=%d penyesuaian=%d",
+				report.RunID, report.UsersChecked, len(report.Mismatches), report.Adjustments)
+			return err
+		})
+	}
+
+	// hold yang lewat masa berlaku harus dilepas agar saldo user tidak tertahan
+	scheduler.Every(jobsCtx, "hold-expiry", envDuration("HOLD_EXPIRY_INTERVAL", time.Minute), func(ctx context.Context) error {
+		n, err := holdSvc.ExpireStale(ctx)
+		if n > 0 {
+			log.Printf("job hold-expiry: %d hold dilepas", n)
+		}
+		return err
+	})
+
+	scheduler.Every(jobsCtx, "balance-expiry", envDuration("EXPIRY_SWEEP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		report, err := expirySvc.Sweep(ctx)
+		if report.Users > 0 {
+			log.Printf("job balance-expiry: user=%d saldo_redeem=%s ev_poin=%d",
+				report.Users, report.RedeemExpired, report.PointsExpired)
+		}
+		return err
+	})
+
+	// notifikasi gateway bisa hilang; order PENDING yang basi ditanyakan langsung
+	if gateways.Len() > 0 {
+		scheduler.Every(jobsCtx, "topup-reconcile", envDuration("TOPUP_RECONCILE_INTERVAL", 5*time.Minute), func(ctx context.Context) error {
+			report, err := walletSvc.ReconcilePendingTopUps(ctx)
+			if report.Updated > 0 {
+				log.Printf("job topup-reconcile: dicek=%d diperbarui=%d belum_bayar=%d",
+					report.Checked, report.Updated, report.NotFound)
+			}
+			return err
+		})
+
+		// order lewat batas bayar dikonfirmasi ke gateway sebelum ditandai EXPIRED
+		scheduler.Every(jobsCtx, "topup-expiry", envDuration("TOPUP_EXPIRY_INTERVAL", 10*time.Minute), func(ctx context.Context) error {
+			report, err := walletSvc.ExpirePendingTopUps(ctx)
+			if report.Expired+report.Updated > 0 {
+				log.Printf("job topup-expiry: dicek=%d kedaluwarsa=%d diperbarui=%d",
+					report.Checked, report.Expired, report.Updated)
+			}
+			return err
+		})
+	}
+
+	// tarik saldo hanya mencatat payout PENDING; pengiriman ke provider dilakukan di sini
+	if payoutProviders.Len() > 0 {
+		scheduler.Every(jobsCtx, "payout-dispatch", envDuration("PAYOUT_DISPATCH_INTERVAL", 15*time.Second), func(ctx context.Context) error {
+			report, err := payoutSvc.DispatchPending(ctx)
+			if report.Requested+report.Retrying+report.Failed+report.Unknown+report.Approved > 0 {
+				log.Printf("job payout-dispatch: terkirim=%d retry=%d gagal=%d tidak_pasti=%d disetujui=%d",
+					report.Requested, report.Retrying, report.Failed, report.Unknown, report.Approved)
+			}
+			return err
+		})
+	} else {
+		log.Println("warning: belum ada payout provider, payout tarik saldo tidak dikirim")
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -149,6 +388,8 @@ func main() {
 
 	<-quit
 	log.Println("Shutdown signal received, stopping server...")
+	stopJobs()
+	scheduler.Wait()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -158,3 +399,81 @@ func main() {
 	}
 	log.Println("Server stopped gracefully.")
 }
+
+// envAmount membaca nominal rupiah dari env; "0" berarti tanpa batas.
+func envAmount(key string, def money.Amount) money.Amount {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	amt, err := money.Parse(raw)
+	if err != nil || amt.IsNegative() {
+		log.Printf("warning: %s=%q tidak valid, pakai default %s", key, raw, def)
+		return def
+	}
+	return amt
+}
+
+// envDuration membaca durasi (format time.ParseDuration) dari env.
+func envDuration(key string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Printf("warning: %s=%q tidak valid, pakai default %s", key, raw, def)
+		return def
+	}
+	return d
+}
+
+func envString(key, def string) string {
+	if raw := strings.TrimSpace(os.Getenv(key)); raw != "" {
+		return raw
+	}
+	return def
+}
+
+// envRoutes membaca pasangan "kunci=provider" yang dipisah koma; kunci dinormalkan
+// dengan normalize agar cocok dengan lookup routing.
+func envRoutes(key string, normalize func(string) string) map[string]string {
+	routes := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, provider, ok := strings.Cut(pair, "=")
+		k, provider = strings.TrimSpace(k), strings.TrimSpace(provider)
+		if !ok || k == "" || provider == "" {
+			log.Printf("warning: %s berisi %q yang tidak valid, diabaikan", key, pair)
+			continue
+		}
+		routes[normalize(k)] = provider
+	}
+	return routes
+}
+
+func envInt(key string, def int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Printf("warning: %s=%q tidak valid, pakai default %d", key, raw, def)
+		return def
+	}
+	return n
+}
+
+func isDev() bool { return strings.EqualFold(os.Getenv("APP_ENV"), "development") }
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
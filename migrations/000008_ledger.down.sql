@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trg_ledger_postings_balanced ON ledger_postings;
+DROP TRIGGER IF EXISTS trg_ledger_accounts_updated_at ON ledger_accounts;
+DROP FUNCTION IF EXISTS ledger_check_entry_balanced();
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+
+DROP TYPE IF EXISTS ledger_side;
+DROP TYPE IF EXISTS ledger_account_kind;
@@ -0,0 +1,117 @@
+CREATE TYPE ledger_account_kind AS ENUM (
+  'USER_TOPUP',
+  'USER_REDEEM',
+  'MIDTRANS_CLEARING',
+  'PAYOUT_CLEARING',
+  'VOUCHER_FUNDING',
+  'OPENING_BALANCE'
+);
+
+CREATE TYPE ledger_side AS ENUM (
+  'DEBIT',
+  'CREDIT'
+);
+
+-- akun milik user (user_id terisi) menyimpan saldo berjalan di kolom balance;
+-- akun sistem (user_id NULL) saldonya dihitung dari ledger_postings agar tidak jadi hot row.
+CREATE TABLE ledger_accounts (
+  id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id     uuid REFERENCES users(id) ON DELETE CASCADE,
+  kind        ledger_account_kind NOT NULL,
+  normal_side ledger_side NOT NULL,
+  balance     numeric(19,4) NOT NULL DEFAULT 0,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX uq_ledger_accounts_user_kind ON ledger_accounts(user_id, kind) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX uq_ledger_accounts_system_kind ON ledger_accounts(kind) WHERE user_id IS NULL;
+
+CREATE TABLE ledger_entries (
+  id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  entry_type     varchar(64) NOT NULL,
+  transaction_id uuid REFERENCES transactions(id) ON DELETE SET NULL,
+  referensi_id   varchar(255),
+  deskripsi      text,
+  created_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE ledger_postings (
+  id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  entry_id   uuid NOT NULL REFERENCES ledger_entries(id) ON DELETE RESTRICT,
+  account_id uuid NOT NULL REFERENCES ledger_accounts(id) ON DELETE RESTRICT,
+  side       ledger_side NOT NULL,
+  amount     numeric(19,4) NOT NULL CHECK (amount > 0),
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ledger_entries_transaction ON ledger_entries(transaction_id);
+CREATE INDEX idx_ledger_postings_entry ON ledger_postings(entry_id);
+CREATE INDEX idx_ledger_postings_account_created_at ON ledger_postings(account_id, created_at);
+
+CREATE TRIGGER trg_ledger_accounts_updated_at
+BEFORE UPDATE ON ledger_accounts
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- setiap jurnal wajib seimbang (total debit = total kredit), dicek saat commit
+CREATE OR REPLACE FUNCTION ledger_check_entry_balanced()
+RETURNS trigger AS $$
+DECLARE
+  diff numeric;
+BEGIN
+  SELECT COALESCE(SUM(CASE WHEN side = 'DEBIT' THEN amount ELSE -amount END), 0)
+    INTO diff
+    FROM ledger_postings
+   WHERE entry_id = NEW.entry_id;
+  IF diff <> 0 THEN
+    RAISE EXCEPTION 'ledger entry % tidak seimbang (selisih %)', NEW.entry_id, diff;
+  END IF;
+  RETURN NULL;
+END; $$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_ledger_postings_balanced
+AFTER INSERT OR UPDATE ON ledger_postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION ledger_check_entry_balanced();
+
+-- akun sistem
+INSERT INTO ledger_accounts (kind, normal_side) VALUES
+  ('MIDTRANS_CLEARING', 'DEBIT'),
+  ('PAYOUT_CLEARING', 'CREDIT'),
+  ('VOUCHER_FUNDING', 'DEBIT'),
+  ('OPENING_BALANCE', 'DEBIT');
+
+-- saldo awal: pindahkan isi wallet_summary yang sudah ada ke akun ledger user
+INSERT INTO ledger_accounts (user_id, kind, normal_side, balance)
+SELECT user_id, 'USER_TOPUP', 'CREDIT', saldo_topup FROM wallet_summary;
+
+INSERT INTO ledger_accounts (user_id, kind, normal_side, balance)
+SELECT user_id, 'USER_REDEEM', 'CREDIT', saldo_redeem FROM wallet_summary;
+
+INSERT INTO ledger_entries (entry_type, referensi_id, deskripsi)
+SELECT 'OPENING_BALANCE', user_id::text, 'Saldo awal migrasi ledger'
+FROM wallet_summary
+WHERE saldo_topup <> 0 OR saldo_redeem <> 0;
+
+WITH opening AS (
+  SELECT e.id AS entry_id, a.id AS account_id, a.balance
+  FROM ledger_entries e
+  JOIN ledger_accounts a ON a.user_id::text = e.referensi_id
+  WHERE e.entry_type = 'OPENING_BALANCE' AND a.balance <> 0
+),
+system_account AS (
+  SELECT id FROM ledger_accounts WHERE kind = 'OPENING_BALANCE' AND user_id IS NULL
+)
+INSERT INTO ledger_postings (entry_id, account_id, side, amount)
+SELECT entry_id, account_id,
+       (CASE WHEN balance > 0 THEN 'CREDIT' ELSE 'DEBIT' END)::ledger_side,
+       abs(balance)
+FROM opening
+UNION ALL
+SELECT o.entry_id, s.id,
+       (CASE WHEN o.balance > 0 THEN 'DEBIT' ELSE 'CREDIT' END)::ledger_side,
+       abs(o.balance)
+FROM opening o CROSS JOIN system_account s;
+
+-- total_saldo selalu diturunkan dari saldo akun ledger
+UPDATE wallet_summary SET total_saldo = saldo_topup + saldo_redeem;
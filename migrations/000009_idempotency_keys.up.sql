@@ -0,0 +1,20 @@
+CREATE TABLE idempotency_keys (
+  id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  scope         varchar(64) NOT NULL,
+  idem_key      varchar(255) NOT NULL,
+  request_hash  char(64) NOT NULL,
+  status        varchar(16) NOT NULL DEFAULT 'IN_PROGRESS',
+  response_code int,
+  response_body bytea,
+  expires_at    timestamptz NOT NULL,
+  created_at    timestamptz NOT NULL DEFAULT now(),
+  updated_at    timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT idempotency_keys_scope_key_unique UNIQUE (scope, idem_key),
+  CONSTRAINT idempotency_keys_status_check CHECK (status IN ('IN_PROGRESS', 'COMPLETED'))
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+CREATE TRIGGER trg_idempotency_keys_updated_at
+BEFORE UPDATE ON idempotency_keys
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reconciliation_mismatches;
+DROP TABLE IF EXISTS reconciliation_runs;
+
+-- nilai enum 'RECONCILIATION_ADJUSTMENT' tidak bisa dihapus dari ledger_account_kind tanpa membuat ulang tipe
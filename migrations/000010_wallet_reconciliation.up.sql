@@ -0,0 +1,29 @@
+ALTER TYPE ledger_account_kind ADD VALUE IF NOT EXISTS 'RECONCILIATION_ADJUSTMENT';
+
+CREATE TABLE reconciliation_runs (
+  id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  triggered_by  varchar(64) NOT NULL,
+  apply         boolean NOT NULL DEFAULT false,
+  users_checked int NOT NULL DEFAULT 0,
+  mismatches    int NOT NULL DEFAULT 0,
+  adjustments   int NOT NULL DEFAULT 0,
+  error         text,
+  started_at    timestamptz NOT NULL DEFAULT now(),
+  finished_at   timestamptz
+);
+
+CREATE TABLE reconciliation_mismatches (
+  id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  run_id          uuid NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+  user_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  field           varchar(32) NOT NULL,
+  source          varchar(32) NOT NULL,
+  expected        numeric(19,4) NOT NULL,
+  actual          numeric(19,4) NOT NULL,
+  adjusted        boolean NOT NULL DEFAULT false,
+  ledger_entry_id uuid REFERENCES ledger_entries(id) ON DELETE SET NULL,
+  created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_reconciliation_mismatches_run ON reconciliation_mismatches(run_id);
+CREATE INDEX idx_reconciliation_mismatches_user ON reconciliation_mismatches(user_id, created_at DESC);
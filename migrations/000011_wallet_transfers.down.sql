@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_phone;
+
+-- nilai enum TRANSFER_* tidak bisa dihapus dari transaction_type tanpa membuat ulang tipe
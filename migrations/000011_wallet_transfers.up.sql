@@ -0,0 +1,6 @@
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'TRANSFER_KELUAR_TOPUP';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'TRANSFER_KELUAR_REDEEM';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'TRANSFER_MASUK_TOPUP';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'TRANSFER_MASUK_REDEEM';
+
+CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone) WHERE phone IS NOT NULL;
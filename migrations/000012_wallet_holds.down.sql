@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trg_wallet_holds_updated_at ON wallet_holds;
+DROP TABLE IF EXISTS wallet_holds;
+
+ALTER TABLE wallet_summary
+  DROP COLUMN IF EXISTS held_topup,
+  DROP COLUMN IF EXISTS held_redeem;
+
+DROP TYPE IF EXISTS wallet_hold_status;
+
+-- nilai enum PEMBAYARAN_* dan 'MERCHANT_SETTLEMENT' tidak bisa dihapus tanpa membuat ulang tipe
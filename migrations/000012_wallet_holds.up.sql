@@ -0,0 +1,38 @@
+CREATE TYPE wallet_hold_status AS ENUM (
+  'ACTIVE',
+  'CAPTURED',
+  'VOIDED',
+  'EXPIRED'
+);
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBAYARAN_TOPUP';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBAYARAN_REDEEM';
+ALTER TYPE ledger_account_kind ADD VALUE IF NOT EXISTS 'MERCHANT_SETTLEMENT';
+
+-- saldo yang sedang ditahan; saldo tersedia = saldo_* - held_*
+ALTER TABLE wallet_summary
+  ADD COLUMN held_topup  numeric(19,4) NOT NULL DEFAULT 0 CHECK (held_topup >= 0),
+  ADD COLUMN held_redeem numeric(19,4) NOT NULL DEFAULT 0 CHECK (held_redeem >= 0);
+
+CREATE TABLE wallet_holds (
+  id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  balance_type    varchar(16) NOT NULL CHECK (balance_type IN ('topup', 'redeem')),
+  amount          numeric(19,4) NOT NULL CHECK (amount > 0),
+  captured_amount numeric(19,4),
+  status          wallet_hold_status NOT NULL DEFAULT 'ACTIVE',
+  referensi_id    varchar(128),
+  deskripsi       text,
+  transaction_id  uuid REFERENCES transactions(id) ON DELETE SET NULL,
+  expires_at      timestamptz NOT NULL,
+  released_at     timestamptz,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_wallet_holds_user ON wallet_holds(user_id, created_at DESC);
+CREATE INDEX idx_wallet_holds_active_expiry ON wallet_holds(expires_at) WHERE status = 'ACTIVE';
+
+CREATE TRIGGER trg_wallet_holds_updated_at
+BEFORE UPDATE ON wallet_holds
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_points_rules_updated_at ON points_rules;
+DROP TABLE IF EXISTS points_ledger;
+DROP TABLE IF EXISTS points_rules;
@@ -0,0 +1,46 @@
+-- Aturan perolehan ev_poin per jenis event. Poin = flat_points + floor(amount / unit_amount) * points_per_unit,
+-- dibatasi max_points_per_event dan daily_cap (NULL = tanpa batas).
+CREATE TABLE points_rules (
+  event_type           varchar(32) PRIMARY KEY,
+  flat_points          int NOT NULL DEFAULT 0 CHECK (flat_points >= 0),
+  points_per_unit      int NOT NULL DEFAULT 0 CHECK (points_per_unit >= 0),
+  unit_amount          numeric(19,4) NOT NULL DEFAULT 1 CHECK (unit_amount > 0),
+  min_amount           numeric(19,4) NOT NULL DEFAULT 0,
+  max_points_per_event int CHECK (max_points_per_event > 0),
+  daily_cap            int CHECK (daily_cap > 0),
+  active               boolean NOT NULL DEFAULT true,
+  created_at           timestamptz NOT NULL DEFAULT now(),
+  updated_at           timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO points_rules (event_type, flat_points, points_per_unit, unit_amount, min_amount, max_points_per_event, daily_cap) VALUES
+  ('TOP_UP',        0, 1, 10000, 10000, 100, 300),
+  ('KLAIM_VOUCHER', 5, 0, 1,     0,     NULL, 20),
+  ('PEMBAYARAN',    0, 1, 5000,  5000,  200, 500);
+
+-- Setiap perubahan ev_poin dicatat di sini; wallet_summary.ev_poin = SUM(delta).
+CREATE TABLE points_ledger (
+  id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  event_type     varchar(32) NOT NULL,
+  delta          int NOT NULL CHECK (delta <> 0),
+  balance_after  int NOT NULL CHECK (balance_after >= 0),
+  source_id      varchar(128) NOT NULL,
+  transaction_id uuid REFERENCES transactions(id) ON DELETE SET NULL,
+  deskripsi      text,
+  created_at     timestamptz NOT NULL DEFAULT now(),
+  -- satu event sumber hanya boleh memberi poin sekali
+  CONSTRAINT points_ledger_event_source_unique UNIQUE (user_id, event_type, source_id)
+);
+
+CREATE INDEX idx_points_ledger_user ON points_ledger(user_id, created_at DESC);
+
+-- poin yang sudah ada sebelum ledger poin menjadi saldo awal
+INSERT INTO points_ledger (user_id, event_type, delta, balance_after, source_id, deskripsi)
+SELECT user_id, 'OPENING_BALANCE', ev_poin, ev_poin, 'migration-000013', 'Saldo awal ev_poin'
+FROM wallet_summary
+WHERE ev_poin > 0;
+
+CREATE TRIGGER trg_points_rules_updated_at
+BEFORE UPDATE ON points_rules
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
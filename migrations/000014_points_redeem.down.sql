@@ -0,0 +1 @@
+-- nilai enum 'TUKAR_POIN' dan 'POINTS_FUNDING' tidak bisa dihapus tanpa membuat ulang tipe
@@ -0,0 +1,2 @@
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'TUKAR_POIN';
+ALTER TYPE ledger_account_kind ADD VALUE IF NOT EXISTS 'POINTS_FUNDING';
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS trg_balance_lots_updated_at ON balance_lots;
+DROP TABLE IF EXISTS balance_lots;
+DROP TYPE IF EXISTS balance_lot_kind;
+
+-- nilai enum 'KEDALUWARSA_REDEEM' dan 'EXPIRED_BALANCE' tidak bisa dihapus tanpa membuat ulang tipe
@@ -0,0 +1,43 @@
+CREATE TYPE balance_lot_kind AS ENUM (
+  'REDEEM',
+  'POINTS'
+);
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'KEDALUWARSA_REDEEM';
+ALTER TYPE ledger_account_kind ADD VALUE IF NOT EXISTS 'EXPIRED_BALANCE';
+
+-- Lot saldo redeem / ev_poin beserta tanggal kedaluwarsanya. Pemakaian saldo
+-- mengurangi remaining secara FIFO (yang paling cepat kedaluwarsa lebih dulu).
+-- Untuk POINTS, original/remaining berisi jumlah poin (bilangan bulat).
+CREATE TABLE balance_lots (
+  id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id     uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind        balance_lot_kind NOT NULL,
+  source_type varchar(32) NOT NULL,
+  source_id   varchar(128) NOT NULL,
+  original    numeric(19,4) NOT NULL CHECK (original > 0),
+  remaining   numeric(19,4) NOT NULL CHECK (remaining >= 0),
+  expires_at  timestamptz,
+  expired_at  timestamptz,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT balance_lots_remaining_le_original CHECK (remaining <= original)
+);
+
+CREATE INDEX idx_balance_lots_open ON balance_lots(user_id, kind, expires_at) WHERE remaining > 0;
+CREATE INDEX idx_balance_lots_due ON balance_lots(expires_at) WHERE remaining > 0 AND expires_at IS NOT NULL;
+
+-- saldo lama tidak punya tanggal kedaluwarsa
+INSERT INTO balance_lots (user_id, kind, source_type, source_id, original, remaining)
+SELECT user_id, 'REDEEM', 'OPENING_BALANCE', 'migration-000015', saldo_redeem, saldo_redeem
+FROM wallet_summary
+WHERE saldo_redeem > 0;
+
+INSERT INTO balance_lots (user_id, kind, source_type, source_id, original, remaining)
+SELECT user_id, 'POINTS', 'OPENING_BALANCE', 'migration-000015', ev_poin, ev_poin
+FROM wallet_summary
+WHERE ev_poin > 0;
+
+CREATE TRIGGER trg_balance_lots_updated_at
+BEFORE UPDATE ON balance_lots
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
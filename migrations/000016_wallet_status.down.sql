@@ -0,0 +1,11 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+DROP TABLE IF EXISTS wallet_status_history;
+
+ALTER TABLE wallet_summary
+  DROP COLUMN IF EXISTS status,
+  DROP COLUMN IF EXISTS status_reason,
+  DROP COLUMN IF EXISTS status_changed_at;
+
+DROP TYPE IF EXISTS wallet_status;
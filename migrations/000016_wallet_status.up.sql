@@ -0,0 +1,27 @@
+CREATE TYPE wallet_status AS ENUM (
+  'ACTIVE',
+  'FROZEN',        -- semua mutasi diblokir (investigasi fraud)
+  'DEBIT_BLOCKED', -- saldo masih bisa masuk, tidak bisa keluar
+  'CLOSED'
+);
+
+ALTER TABLE wallet_summary
+  ADD COLUMN status            wallet_status NOT NULL DEFAULT 'ACTIVE',
+  ADD COLUMN status_reason     text,
+  ADD COLUMN status_changed_at timestamptz;
+
+CREATE TABLE wallet_status_history (
+  id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id     uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  from_status wallet_status NOT NULL,
+  to_status   wallet_status NOT NULL,
+  reason      text NOT NULL,
+  changed_by  uuid REFERENCES users(id) ON DELETE SET NULL,
+  created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_wallet_status_history_user ON wallet_status_history(user_id, created_at DESC);
+
+-- role dipakai untuk endpoint admin (ubah status wallet, dsb.)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role varchar(16) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS limit_usage;
+DROP TABLE IF EXISTS limit_rules;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tier_check;
+ALTER TABLE users DROP COLUMN IF EXISTS tier;
@@ -0,0 +1,39 @@
+-- Tingkat verifikasi user menentukan limit top up dan tarik saldo.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tier varchar(16) NOT NULL DEFAULT 'BASIC';
+ALTER TABLE users ADD CONSTRAINT users_tier_check CHECK (tier IN ('BASIC', 'VERIFIED', 'PREMIUM'));
+
+-- Limit per tier dan jenis operasi. NULL = tanpa batas.
+CREATE TABLE limit_rules (
+  tier        varchar(16) NOT NULL,
+  operation   varchar(16) NOT NULL CHECK (operation IN ('TOP_UP', 'WITHDRAW')),
+  per_txn_max numeric(19,4) CHECK (per_txn_max > 0),
+  daily_max   numeric(19,4) CHECK (daily_max > 0),
+  monthly_max numeric(19,4) CHECK (monthly_max > 0),
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  updated_at  timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (tier, operation)
+);
+
+INSERT INTO limit_rules (tier, operation, per_txn_max, daily_max, monthly_max) VALUES
+  ('BASIC',    'TOP_UP',   2000000,  2000000,   10000000),
+  ('BASIC',    'WITHDRAW', 1000000,  1000000,   5000000),
+  ('VERIFIED', 'TOP_UP',   10000000, 20000000,  40000000),
+  ('VERIFIED', 'WITHDRAW', 10000000, 20000000,  40000000),
+  ('PREMIUM',  'TOP_UP',   NULL,     100000000, 500000000),
+  ('PREMIUM',  'WITHDRAW', NULL,     100000000, 500000000);
+
+-- Akumulasi per hari/bulan kalender (WIB). Baris periode baru dibuat otomatis,
+-- jadi counter "reset" tanpa job terpisah.
+CREATE TABLE limit_usage (
+  user_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  operation    varchar(16) NOT NULL,
+  period       varchar(8) NOT NULL CHECK (period IN ('DAY', 'MONTH')),
+  period_start date NOT NULL,
+  amount       numeric(19,4) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+  updated_at   timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, operation, period, period_start)
+);
+
+CREATE TRIGGER trg_limit_rules_updated_at
+BEFORE UPDATE ON limit_rules
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
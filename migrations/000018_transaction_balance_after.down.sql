@@ -0,0 +1,4 @@
+ALTER TABLE transactions
+  DROP COLUMN IF EXISTS balance_after_total,
+  DROP COLUMN IF EXISTS balance_after_topup,
+  DROP COLUMN IF EXISTS balance_after_redeem;
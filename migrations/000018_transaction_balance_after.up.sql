@@ -0,0 +1,5 @@
+-- Saldo setelah transaksi. Baris lama tetap NULL sampai dijalankan cmd/backfill-balance.
+ALTER TABLE transactions
+  ADD COLUMN balance_after_total  numeric(19,4),
+  ADD COLUMN balance_after_topup  numeric(19,4),
+  ADD COLUMN balance_after_redeem numeric(19,4);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transaction_reversals;
+
+-- nilai enum PEMBALIKAN_* tidak bisa dihapus tanpa membuat ulang tipe
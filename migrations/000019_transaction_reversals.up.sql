@@ -0,0 +1,17 @@
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBALIKAN_DEBIT_TOPUP';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBALIKAN_DEBIT_REDEEM';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBALIKAN_KREDIT_TOPUP';
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'PEMBALIKAN_KREDIT_REDEEM';
+
+-- Pembalikan transaksi oleh admin. Satu transaksi hanya boleh dibalik sekali.
+CREATE TABLE transaction_reversals (
+  id                      uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  original_transaction_id uuid NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE RESTRICT,
+  reversal_transaction_id uuid NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE RESTRICT,
+  user_id                 uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason                  text NOT NULL,
+  reversed_by             uuid REFERENCES users(id) ON DELETE SET NULL,
+  created_at              timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_transaction_reversals_user ON transaction_reversals(user_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- Nilai enum 'UNKNOWN' tidak bisa dihapus dari payout_request_status.
+DROP INDEX IF EXISTS idx_payout_requests_midtrans_payout_id;
+
+ALTER TABLE payout_requests DROP COLUMN IF EXISTS transaction_id;
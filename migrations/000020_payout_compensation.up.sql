@@ -0,0 +1,20 @@
+-- Hubungkan payout dengan transaksi tarik saldonya supaya payout yang gagal
+-- bisa dikembalikan ke sub-saldo asal.
+ALTER TABLE payout_requests
+  ADD COLUMN transaction_id uuid REFERENCES transactions(id) ON DELETE SET NULL;
+
+UPDATE payout_requests p
+SET transaction_id = e.transaction_id
+FROM ledger_entries e
+WHERE e.referensi_id = p.id::text
+  AND e.entry_type IN ('TARIK_SALDO_PENDAPATAN', 'TARIK_SALDO_REFUND')
+  AND e.transaction_id IS NOT NULL
+  AND p.transaction_id IS NULL;
+
+-- notifikasi Iris hanya membawa reference_no
+CREATE INDEX idx_payout_requests_midtrans_payout_id ON payout_requests(midtrans_payout_id);
+
+-- UNKNOWN: hasil pengiriman payout ke provider tidak pasti; dipakai 000021 untuk
+-- payout lama. Ditambahkan di migrasi terpisah karena nilai enum baru belum boleh
+-- dipakai di transaksi yang menambahkannya.
+ALTER TYPE payout_request_status ADD VALUE IF NOT EXISTS 'UNKNOWN';
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_payout_requests_dispatch;
+
+ALTER TABLE payout_requests
+  DROP CONSTRAINT IF EXISTS payout_requests_partner_trx_id_key,
+  DROP COLUMN IF EXISTS last_error,
+  DROP COLUMN IF EXISTS next_attempt_at,
+  DROP COLUMN IF EXISTS attempts,
+  DROP COLUMN IF EXISTS notes,
+  DROP COLUMN IF EXISTS beneficiary_email,
+  DROP COLUMN IF EXISTS partner_trx_id;
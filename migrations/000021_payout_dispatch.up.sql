@@ -0,0 +1,49 @@
+-- Payout dikirim ke Iris oleh dispatcher di background, jadi semua data untuk
+-- membentuk ulang request Iris disimpan di payout_requests.
+ALTER TABLE payout_requests
+  ADD COLUMN partner_trx_id    varchar(64),
+  ADD COLUMN beneficiary_email varchar(255),
+  ADD COLUMN notes             text,
+  ADD COLUMN attempts          integer NOT NULL DEFAULT 0,
+  ADD COLUMN next_attempt_at   timestamptz,
+  ADD COLUMN last_error        text;
+
+-- Sebelumnya tarik saldo langsung mengirim ke Iris dengan partner_trx_id acak yang
+-- tidak disimpan, dan status balasan Iris gagal tercatat; payout yang sudah diterima
+-- Iris tetap PENDING. Payout lama mendapat partner_trx_id pengganti dan yang belum
+-- final diparkir sebagai UNKNOWN agar tidak dikirim ulang dispatcher, sampai
+-- direkonsiliasi admin (POST /admin/payouts/:payoutId/resolve).
+UPDATE payout_requests
+SET partner_trx_id = 'WD-' || replace(id::text, '-', '')
+WHERE partner_trx_id IS NULL;
+
+UPDATE payout_requests
+SET status = 'UNKNOWN',
+    last_error = 'payout sebelum dispatcher; status di Iris perlu dicek manual',
+    updated_at = now()
+WHERE status IN ('PENDING', 'REQUESTED');
+
+-- Transaksi tarik saldo lama tidak punya referensi ke payout (000020 hanya menemukan
+-- yang punya jurnal ledger); dicocokkan lewat user, nominal dan waktu yang sama karena
+-- keduanya dibuat dalam satu transaksi database. Payout yang tidak cocok tidak bisa
+-- direfund otomatis.
+UPDATE payout_requests p
+SET transaction_id = (
+  SELECT t.id
+  FROM transactions t
+  WHERE t.user_id = p.user_id
+    AND t.tipe_transaksi IN ('TARIK_SALDO_PENDAPATAN', 'TARIK_SALDO_REFUND')
+    AND t.jumlah = p.amount
+    AND t.created_at BETWEEN p.requested_at - interval '1 minute' AND p.requested_at + interval '1 minute'
+    AND NOT EXISTS (SELECT 1 FROM payout_requests o WHERE o.transaction_id = t.id)
+  ORDER BY abs(extract(epoch FROM t.created_at - p.requested_at))
+  LIMIT 1
+)
+WHERE p.transaction_id IS NULL;
+
+ALTER TABLE payout_requests
+  ALTER COLUMN partner_trx_id SET NOT NULL,
+  ADD CONSTRAINT payout_requests_partner_trx_id_key UNIQUE (partner_trx_id);
+
+CREATE INDEX idx_payout_requests_dispatch ON payout_requests(next_attempt_at, requested_at)
+  WHERE status = 'PENDING';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_payout_requests_approval;
+
+ALTER TABLE payout_requests
+  DROP COLUMN IF EXISTS approval_decided_at,
+  DROP COLUMN IF EXISTS approval_note,
+  DROP COLUMN IF EXISTS approved_by,
+  DROP COLUMN IF EXISTS approval_status;
@@ -0,0 +1,11 @@
+-- Persetujuan payout Iris (maker-checker). NULL = Iris tidak butuh approver,
+-- AUTO = disetujui otomatis oleh sistem, PENDING = menunggu review finance.
+ALTER TABLE payout_requests
+  ADD COLUMN approval_status     varchar(16)
+    CHECK (approval_status IN ('AUTO', 'PENDING', 'APPROVED', 'REJECTED')),
+  ADD COLUMN approved_by         uuid REFERENCES users(id) ON DELETE SET NULL,
+  ADD COLUMN approval_note       text,
+  ADD COLUMN approval_decided_at timestamptz;
+
+CREATE INDEX idx_payout_requests_approval ON payout_requests(approval_status, requested_at)
+  WHERE status = 'REQUESTED';
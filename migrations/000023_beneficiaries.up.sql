@@ -0,0 +1,24 @@
+-- Rekening tujuan tarik saldo yang sudah divalidasi lewat Iris.
+CREATE TABLE beneficiaries (
+  id                  uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id             uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  alias               varchar(64),
+  bank_code           varchar(32) NOT NULL,
+  bank_name           varchar(128),
+  account_number      varchar(64) NOT NULL,
+  account_holder_name varchar(128) NOT NULL,
+  validated_at        timestamptz NOT NULL,
+  deleted_at          timestamptz,
+  created_at          timestamptz NOT NULL DEFAULT now(),
+  updated_at          timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX uq_beneficiaries_user_account ON beneficiaries(user_id, bank_code, account_number)
+  WHERE deleted_at IS NULL;
+
+CREATE TRIGGER trg_beneficiaries_updated_at
+BEFORE UPDATE ON beneficiaries
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE payout_requests
+  ADD COLUMN beneficiary_id uuid REFERENCES beneficiaries(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payment_orders_pending_expiry;
+
+ALTER TABLE payment_orders DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE payment_orders DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,11 @@
+-- Batas waktu bayar order top up. Order lama mengikuti default Snap (24 jam).
+ALTER TABLE payment_orders ADD COLUMN expires_at timestamptz;
+
+UPDATE payment_orders SET expires_at = created_at + interval '24 hours';
+
+ALTER TABLE payment_orders ALTER COLUMN expires_at SET NOT NULL;
+
+ALTER TABLE payment_orders ADD COLUMN cancelled_at timestamptz;
+
+CREATE INDEX idx_payment_orders_pending_expiry ON payment_orders(expires_at)
+  WHERE status = 'PENDING';
@@ -0,0 +1,11 @@
+ALTER TABLE payment_orders
+  DROP COLUMN IF EXISTS refund_error,
+  DROP COLUMN IF EXISTS refunded_at,
+  DROP COLUMN IF EXISTS refund_requested_at,
+  DROP COLUMN IF EXISTS refund_requested_by,
+  DROP COLUMN IF EXISTS refund_transaction_id,
+  DROP COLUMN IF EXISTS refund_reason,
+  DROP COLUMN IF EXISTS refund_key,
+  DROP COLUMN IF EXISTS refund_status;
+
+-- nilai enum REFUND_TOPUP tidak bisa dihapus tanpa membuat ulang tipe
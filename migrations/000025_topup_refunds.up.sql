@@ -0,0 +1,14 @@
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'REFUND_TOPUP';
+
+-- Refund top up yang sudah settlement lewat Midtrans. Status order tetap SETTLEMENT;
+-- status refund dicatat terpisah. Refund selalu penuh sebesar gross_amount.
+ALTER TABLE payment_orders
+  ADD COLUMN refund_status         varchar(16)
+    CHECK (refund_status IN ('PENDING', 'REFUNDED', 'FAILED')),
+  ADD COLUMN refund_key            varchar(64) UNIQUE,
+  ADD COLUMN refund_reason         text,
+  ADD COLUMN refund_transaction_id uuid REFERENCES transactions(id) ON DELETE RESTRICT,
+  ADD COLUMN refund_requested_by   uuid REFERENCES users(id) ON DELETE SET NULL,
+  ADD COLUMN refund_requested_at   timestamptz,
+  ADD COLUMN refunded_at           timestamptz,
+  ADD COLUMN refund_error          text;
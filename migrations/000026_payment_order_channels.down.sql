@@ -0,0 +1,3 @@
+ALTER TABLE payment_orders
+  DROP COLUMN IF EXISTS channel_data,
+  DROP COLUMN IF EXISTS payment_channel;
@@ -0,0 +1,6 @@
+-- Top up lewat Core API (VA, QRIS, GoPay) tidak punya snap token (disimpan kosong);
+-- instruksi bayar per channel disimpan di channel_data agar bisa ditampilkan ulang.
+ALTER TABLE payment_orders
+  ADD COLUMN payment_channel varchar(16) NOT NULL DEFAULT 'SNAP'
+    CHECK (payment_channel IN ('SNAP', 'BANK_TRANSFER', 'QRIS', 'GOPAY')),
+  ADD COLUMN channel_data jsonb;
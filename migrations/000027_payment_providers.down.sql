@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_payout_requests_provider_reference;
+CREATE INDEX idx_payout_requests_midtrans_payout_id ON payout_requests(provider_reference);
+
+ALTER TABLE payout_requests RENAME COLUMN provider_reference TO midtrans_payout_id;
+ALTER TABLE payout_requests DROP COLUMN IF EXISTS provider;
+
+ALTER TABLE payment_orders RENAME COLUMN provider_transaction_id TO midtrans_transaction_id;
+ALTER TABLE payment_orders DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,10 @@
+-- Order top up dan payout ditandai provider yang memprosesnya; kolom id transaksi
+-- dari provider tidak lagi khusus Midtrans.
+ALTER TABLE payment_orders ADD COLUMN provider varchar(32) NOT NULL DEFAULT 'midtrans';
+ALTER TABLE payment_orders RENAME COLUMN midtrans_transaction_id TO provider_transaction_id;
+
+ALTER TABLE payout_requests ADD COLUMN provider varchar(32) NOT NULL DEFAULT 'midtrans';
+ALTER TABLE payout_requests RENAME COLUMN midtrans_payout_id TO provider_reference;
+
+DROP INDEX IF EXISTS idx_payout_requests_midtrans_payout_id;
+CREATE INDEX idx_payout_requests_provider_reference ON payout_requests(provider, provider_reference);
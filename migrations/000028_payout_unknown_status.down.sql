@@ -0,0 +1,7 @@
+ALTER TABLE payout_requests
+  DROP COLUMN IF EXISTS dispatch_in_flight,
+  DROP COLUMN IF EXISTS resolved_by,
+  DROP COLUMN IF EXISTS resolution_note,
+  DROP COLUMN IF EXISTS resolved_at;
+
+-- nilai enum 'UNKNOWN' tidak bisa dihapus tanpa membuat ulang tipe
@@ -0,0 +1,11 @@
+-- UNKNOWN: hasil pengiriman payout ke provider tidak pasti (timeout, 5xx, proses mati
+-- sebelum hasilnya tercatat). Payout seperti ini tidak dikirim ulang maupun
+-- dikembalikan ke wallet sampai direkonsiliasi admin.
+ALTER TYPE payout_request_status ADD VALUE IF NOT EXISTS 'UNKNOWN';
+
+ALTER TABLE payout_requests
+  -- true sejak request payout mulai dikirim sampai hasilnya tercatat
+  ADD COLUMN dispatch_in_flight  boolean NOT NULL DEFAULT false,
+  ADD COLUMN resolved_by         uuid REFERENCES users(id) ON DELETE SET NULL,
+  ADD COLUMN resolution_note     text,
+  ADD COLUMN resolved_at         timestamptz;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS limit_usage (
+  user_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  operation    varchar(16) NOT NULL,
+  period       varchar(8) NOT NULL CHECK (period IN ('DAY', 'MONTH')),
+  period_start date NOT NULL,
+  amount       numeric(19,4) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+  updated_at   timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, operation, period, period_start)
+);
+
+-- Entri dikembalikan ke counter kalender WIB.
+INSERT INTO limit_usage (user_id, operation, period, period_start, amount)
+SELECT user_id, operation, 'DAY', (occurred_at AT TIME ZONE 'Asia/Jakarta')::date, SUM(amount)
+FROM limit_usage_entries
+WHERE released_at IS NULL
+GROUP BY 1, 2, 4
+UNION ALL
+SELECT user_id, operation, 'MONTH', date_trunc('month', occurred_at AT TIME ZONE 'Asia/Jakarta')::date, SUM(amount)
+FROM limit_usage_entries
+WHERE released_at IS NULL
+GROUP BY 1, 2, 4;
+
+DROP INDEX IF EXISTS idx_payment_orders_user_pending;
+DROP TABLE IF EXISTS limit_usage_entries;
@@ -0,0 +1,33 @@
+-- Pemakaian limit dicatat per transaksi agar bisa dijumlahkan dalam jendela bergulir
+-- (24 jam dan 30 hari terakhir), bukan per hari/bulan kalender. reference menunjuk
+-- sumbernya (order top up atau partner_trx_id payout) sehingga refund dan payout
+-- gagal melepas tepat entri yang dicatat.
+CREATE TABLE limit_usage_entries (
+  id          bigserial PRIMARY KEY,
+  user_id     uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  operation   varchar(16) NOT NULL,
+  reference   varchar(128) NOT NULL,
+  amount      numeric(19,4) NOT NULL CHECK (amount > 0),
+  occurred_at timestamptz NOT NULL,
+  released_at timestamptz,
+  created_at  timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (user_id, operation, reference)
+);
+
+CREATE INDEX idx_limit_usage_entries_window ON limit_usage_entries(user_id, operation, occurred_at)
+  WHERE released_at IS NULL;
+
+-- Order top up yang belum dibayar ikut dihitung ke limit.
+CREATE INDEX idx_payment_orders_user_pending ON payment_orders(user_id, created_at)
+  WHERE status = 'PENDING' AND NOT balance_applied;
+
+-- Counter harian 30 hari terakhir dibawa sebagai satu entri per hari (awal hari WIB)
+-- agar limit tidak tiba-tiba longgar setelah migrasi. Entri ini tidak bisa dilepas
+-- refund dan habis sendiri setelah keluar jendela.
+INSERT INTO limit_usage_entries (user_id, operation, reference, amount, occurred_at)
+SELECT user_id, operation, 'LEGACY-' || to_char(period_start, 'YYYY-MM-DD'), amount,
+       period_start::timestamp AT TIME ZONE 'Asia/Jakarta'
+FROM limit_usage
+WHERE period = 'DAY' AND amount > 0 AND period_start >= (now() AT TIME ZONE 'Asia/Jakarta')::date - 30;
+
+DROP TABLE limit_usage;
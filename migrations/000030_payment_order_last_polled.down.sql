@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payment_orders_pending_poll;
+ALTER TABLE payment_orders DROP COLUMN IF EXISTS last_polled_at;
@@ -0,0 +1,7 @@
+-- Waktu terakhir status order ditanyakan ke gateway. Polling mendahulukan order yang
+-- belum/paling lama tidak dicek agar order baru tidak tertahan order lama yang
+-- statusnya belum pasti.
+ALTER TABLE payment_orders ADD COLUMN last_polled_at timestamptz;
+
+CREATE INDEX idx_payment_orders_pending_poll ON payment_orders(last_polled_at NULLS FIRST, created_at)
+  WHERE status = 'PENDING' AND NOT balance_applied;
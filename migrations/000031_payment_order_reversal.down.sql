@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_payment_orders_transaction;
+ALTER TABLE payment_orders
+  DROP COLUMN IF EXISTS reversed_at,
+  DROP COLUMN IF EXISTS reversal_transaction_id,
+  DROP COLUMN IF EXISTS transaction_id;
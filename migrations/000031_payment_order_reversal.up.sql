@@ -0,0 +1,20 @@
+-- Transaksi TOP_UP hasil settlement dan pembalikannya dicatat di order, agar refund
+-- tidak mendebit top up yang saldonya sudah ditarik lewat pembalikan (dan sebaliknya).
+ALTER TABLE payment_orders
+  ADD COLUMN transaction_id          uuid REFERENCES transactions(id) ON DELETE RESTRICT,
+  ADD COLUMN reversal_transaction_id uuid REFERENCES transactions(id) ON DELETE RESTRICT,
+  ADD COLUMN reversed_at             timestamptz;
+
+-- order lama: transaksi TOP_UP ditemukan lewat jurnal ledger-nya
+UPDATE payment_orders o
+SET transaction_id = e.transaction_id
+FROM ledger_entries e
+WHERE e.entry_type = 'TOP_UP' AND e.referensi_id = o.order_id AND e.transaction_id IS NOT NULL
+  AND o.balance_applied;
+
+UPDATE payment_orders o
+SET reversal_transaction_id = r.reversal_transaction_id, reversed_at = r.created_at
+FROM transaction_reversals r
+WHERE r.original_transaction_id = o.transaction_id;
+
+CREATE UNIQUE INDEX idx_payment_orders_transaction ON payment_orders(transaction_id);
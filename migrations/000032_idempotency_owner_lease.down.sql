@@ -0,0 +1,11 @@
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+
+-- key yang sama milik beberapa user tidak bisa dipertahankan di constraint lama
+DELETE FROM idempotency_keys a
+USING idempotency_keys b
+WHERE a.scope = b.scope AND a.idem_key = b.idem_key AND (a.created_at, a.id) < (b.created_at, b.id);
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_owner_scope_key_unique;
+ALTER TABLE idempotency_keys
+  ADD CONSTRAINT idempotency_keys_scope_key_unique UNIQUE (scope, idem_key);
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS owner_id;
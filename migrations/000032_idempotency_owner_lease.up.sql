@@ -0,0 +1,13 @@
+-- Key idempotensi dipisah per pemilik (user yang login, atau userId di body untuk
+-- endpoint lama) agar key yang sama dari user berbeda tidak saling bentrok atau
+-- memutar ulang respons milik user lain.
+ALTER TABLE idempotency_keys ADD COLUMN owner_id varchar(64) NOT NULL DEFAULT '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_scope_key_unique;
+ALTER TABLE idempotency_keys
+  ADD CONSTRAINT idempotency_keys_owner_scope_key_unique UNIQUE (owner_id, scope, idem_key);
+
+-- Lease IN_PROGRESS: bila proses yang memegang key mati sebelum Finish/Abort, retry
+-- dengan body yang sama boleh mengambil alih key setelah locked_until lewat.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until timestamptz;
+UPDATE idempotency_keys SET locked_until = updated_at WHERE status = 'IN_PROGRESS';
@@ -0,0 +1 @@
+ALTER TABLE payout_requests DROP COLUMN IF EXISTS approval_in_flight_at;
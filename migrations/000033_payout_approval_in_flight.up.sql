@@ -0,0 +1,5 @@
+-- Waktu approve/reject payout terakhir dikirim ke Iris tanpa hasil yang tercatat.
+-- Dicatat di transaksi sendiri sebelum request dikirim; bila masih terisi saat
+-- payout diputuskan lagi, hasil percobaan sebelumnya tidak pasti sehingga penolakan
+-- dari Iris tidak boleh dianggap final.
+ALTER TABLE payout_requests ADD COLUMN approval_in_flight_at timestamptz;
@@ -0,0 +1,256 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale adalah jumlah satuan terkecil per 1 rupiah. Presisinya mengikuti
+// kolom numeric(19,4) di database.
+const (
+	Scale    = 10000
+	Decimals = 4
+)
+
+// Amount adalah nominal rupiah dengan presisi tetap 4 desimal, disimpan sebagai
+// bilangan bulat (1 = Rp0,0001) supaya tidak ada drift pembulatan float.
+//
+// Aturan pembulatan:
+//   - parsing menolak input dengan lebih dari 4 digit desimal (tidak dibulatkan diam-diam);
+//   - RoundRupiah membulatkan ke rupiah penuh dengan half-up (menjauhi nol);
+//   - nominal yang dikirim ke Midtrans (Snap/Iris) wajib rupiah bulat, lihat IsWholeRupiah.
+type Amount int64
+
+var (
+	ErrInvalid  = errors.New("format nominal tidak valid")
+	ErrPrecise  = errors.New("nominal maksimal 4 digit desimal")
+	ErrOverflow = errors.New("nominal terlalu besar")
+)
+
+// Zero adalah nominal nol.
+const Zero Amount = 0
+
+// FromRupiah membuat Amount dari rupiah bulat.
+func FromRupiah(rp int64) Amount { return Amount(rp * Scale) }
+
+// Parse membaca string desimal ("15000", "-12.5", "10000.0000") secara eksak.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalid
+	}
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, ErrInvalid
+	}
+	if hasDot && fracPart == "" {
+		return 0, ErrInvalid
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalid
+	}
+	// nol di belakang koma tidak menambah presisi ("10000.000000" tetap valid)
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > Decimals {
+		return 0, ErrPrecise
+	}
+	fracPart += strings.Repeat("0", Decimals-len(fracPart))
+
+	var units uint64
+	for _, ch := range intPart + fracPart {
+		d := uint64(ch - '0')
+		if units > (math.MaxInt64-d)/10 {
+			return 0, ErrOverflow
+		}
+		units = units*10 + d
+	}
+	if neg {
+		return Amount(-int64(units)), nil
+	}
+	return Amount(int64(units)), nil
+}
+
+// MustParse seperti Parse tetapi panic bila gagal; hanya untuk konstanta.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: %q: %v", s, err))
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Amount) Add(b Amount) Amount { return a + b }
+func (a Amount) Sub(b Amount) Amount { return a - b }
+func (a Amount) Neg() Amount         { return -a }
+
+// MulInt mengalikan nominal dengan bilangan bulat.
+func (a Amount) MulInt(n int64) Amount { return a * Amount(n) }
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Cmp mengembalikan -1, 0, atau 1.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) IsZero() bool     { return a == 0 }
+func (a Amount) IsPositive() bool { return a > 0 }
+func (a Amount) IsNegative() bool { return a < 0 }
+
+// IsWholeRupiah bernilai true bila tidak ada pecahan sen.
+func (a Amount) IsWholeRupiah() bool { return a%Scale == 0 }
+
+// RoundRupiah membulatkan ke rupiah penuh, half-up menjauhi nol (Rp0,5 -> Rp1).
+func (a Amount) RoundRupiah() Amount {
+	rem := a % Scale
+	base := a - rem
+	switch {
+	case rem >= Scale/2:
+		return base + Scale
+	case rem <= -Scale/2:
+		return base - Scale
+	default:
+		return base
+	}
+}
+
+// Rupiah mengembalikan nilai rupiah penuh setelah RoundRupiah.
+func (a Amount) Rupiah() int64 { return int64(a.RoundRupiah() / Scale) }
+
+// String menampilkan nominal tanpa nol berlebih di belakang koma ("15000", "12.5").
+func (a Amount) String() string {
+	s := a.StringFixed()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// StringFixed menampilkan nominal dengan tepat 4 digit desimal, sesuai kolom numeric(19,4).
+func (a Amount) StringFixed() string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = uint64(-a)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/Scale, Decimals, u%Scale)
+}
+
+// RupiahString menampilkan nominal rupiah bulat (setelah RoundRupiah), format yang diminta Midtrans.
+func (a Amount) RupiahString() string { return strconv.FormatInt(a.Rupiah(), 10) }
+
+// Float64 hanya untuk keperluan tampilan/log, jangan dipakai untuk menghitung.
+func (a Amount) Float64() float64 { return float64(a) / Scale }
+
+// MarshalJSON menulis nominal sebagai angka JSON tanpa melewati float.
+func (a Amount) MarshalJSON() ([]byte, error) { return []byte(a.String()), nil }
+
+// UnmarshalJSON menerima angka maupun string ("15000" atau 15000).
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	if bytes.ContainsAny(b, "eE") {
+		return ErrInvalid
+	}
+	v, err := Parse(string(b))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value menyimpan nominal ke kolom numeric sebagai string desimal.
+func (a Amount) Value() (driver.Value, error) { return a.StringFixed(), nil }
+
+// Scan membaca kolom numeric (lib/pq mengirimkannya sebagai []byte). NULL ditolak
+// agar tidak terbaca sebagai nol; kolom yang boleh NULL memakai NullAmount.
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		return errors.New("money: tidak bisa scan NULL ke Amount, pakai NullAmount")
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = FromRupiah(v)
+		return nil
+	default:
+		return fmt.Errorf("money: tidak bisa scan %T", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return fmt.Errorf("money: scan %q: %w", s, err)
+	}
+	*a = v
+	return nil
+}
+
+// NullAmount adalah Amount untuk kolom numeric yang boleh NULL, seperti sql.NullInt64.
+type NullAmount struct {
+	Amount Amount
+	Valid  bool // false bila kolomnya NULL
+}
+
+func (n *NullAmount) Scan(src any) error {
+	*n = NullAmount{}
+	if src == nil {
+		return nil
+	}
+	if err := n.Amount.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+func (n NullAmount) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Amount.Value()
+}
@@ -0,0 +1,196 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr error
+	}{
+		{"15000", FromRupiah(15000), nil},
+		{"+15000", FromRupiah(15000), nil},
+		{"-12.5", -125000, nil},
+		{" 42 ", FromRupiah(42), nil},
+		{".5", 5000, nil},
+		{"0.0001", 1, nil},
+		{"-0.0001", -1, nil},
+		{"10000.0000", FromRupiah(10000), nil},
+		{"10000.000000", FromRupiah(10000), nil}, // nol di belakang tidak menambah presisi
+		{"1.23450", 12345, nil},
+		{"1.23456", 0, ErrPrecise},
+		{"0.00001", 0, ErrPrecise},
+		{"-0.00001", 0, ErrPrecise},
+		{"", 0, ErrInvalid},
+		{".", 0, ErrInvalid},
+		{"+", 0, ErrInvalid},
+		{"-", 0, ErrInvalid},
+		{"12.", 0, ErrInvalid},
+		{"--1", 0, ErrInvalid},
+		{"+-1", 0, ErrInvalid},
+		{"1,5", 0, ErrInvalid},
+		{"1.2.3", 0, ErrInvalid},
+		{"1e3", 0, ErrInvalid},
+		{"922337203685477.5807", math.MaxInt64, nil},
+		{"-922337203685477.5807", -math.MaxInt64, nil},
+		{"922337203685477.5808", 0, ErrOverflow},
+		{"-922337203685477.5808", 0, ErrOverflow}, // MinInt64 sengaja tidak didukung
+		{"99999999999999999999", 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundRupiah(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1.4999", 1},
+		{"1.5", 2},
+		{"2.5", 3},
+		{"-0.4999", 0},
+		{"-0.5", -1},
+		{"-1.4999", -1},
+		{"-1.5", -2},
+		{"-2.5", -3},
+		{"-2", -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			a := MustParse(tt.in)
+			if got := a.RoundRupiah(); got != FromRupiah(tt.want) {
+				t.Fatalf("RoundRupiah(%s) = %s, want %d", tt.in, got, tt.want)
+			}
+			if got := a.Rupiah(); got != tt.want {
+				t.Fatalf("Rupiah(%s) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in    Amount
+		want  string
+		fixed string
+	}{
+		{FromRupiah(15000), "15000", "15000.0000"},
+		{125000, "12.5", "12.5000"},
+		{-125000, "-12.5", "-12.5000"},
+		{1, "0.0001", "0.0001"},
+		{0, "0", "0.0000"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("String(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := tt.in.StringFixed(); got != tt.fixed {
+			t.Errorf("StringFixed(%d) = %q, want %q", tt.in, got, tt.fixed)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr error
+	}{
+		{`15000`, FromRupiah(15000), nil},
+		{`"15000"`, FromRupiah(15000), nil},
+		{`-12.5`, -125000, nil},
+		{`"12.5000"`, 125000, nil},
+		{` 7 `, FromRupiah(7), nil},
+		{`1e3`, 0, ErrInvalid},
+		{`"1E3"`, 0, ErrInvalid},
+		{`1.5e-1`, 0, ErrInvalid},
+		{`""`, 0, ErrInvalid},
+		{`"abc"`, 0, ErrInvalid},
+		{`"15000`, 0, ErrInvalid},
+		{`1.00001`, 0, ErrPrecise},
+		{`"922337203685477.5808"`, 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var a Amount
+			err := a.UnmarshalJSON([]byte(tt.in))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnmarshalJSON(%s) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && a != tt.want {
+				t.Fatalf("UnmarshalJSON(%s) = %d, want %d", tt.in, a, tt.want)
+			}
+		})
+	}
+
+	// null tidak mengubah nilai yang sudah ada
+	a := FromRupiah(5)
+	if err := a.UnmarshalJSON([]byte("null")); err != nil || a != FromRupiah(5) {
+		t.Fatalf("UnmarshalJSON(null) = %d, %v", a, err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    Amount
+		wantErr bool
+	}{
+		{"bytes dari lib/pq", []byte("15000.0000"), FromRupiah(15000), false},
+		{"string negatif", "-12.5000", -125000, false},
+		{"int64 sebagai rupiah", int64(7), FromRupiah(7), false},
+		{"NULL", nil, 0, true},
+		{"float", 1.5, 0, true},
+		{"presisi berlebih", []byte("1.00001"), 0, true},
+		{"bukan angka", []byte("abc"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Amount(99)
+			err := a.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			}
+			if !tt.wantErr && a != tt.want {
+				t.Fatalf("Scan(%v) = %d, want %d", tt.src, a, tt.want)
+			}
+		})
+	}
+}
+
+func TestNullAmount(t *testing.T) {
+	n := NullAmount{Amount: 99, Valid: true}
+	if err := n.Scan(nil); err != nil || n.Valid || n.Amount != 0 {
+		t.Fatalf("Scan(nil) = %+v, %v", n, err)
+	}
+	if v, err := n.Value(); err != nil || v != nil {
+		t.Fatalf("Value() NULL = %v, %v", v, err)
+	}
+
+	if err := n.Scan([]byte("12.5000")); err != nil || !n.Valid || n.Amount != 125000 {
+		t.Fatalf("Scan(12.5) = %+v, %v", n, err)
+	}
+	if v, err := n.Value(); err != nil || v != "12.5000" {
+		t.Fatalf("Value() = %v, %v", v, err)
+	}
+
+	if err := n.Scan([]byte("abc")); err == nil {
+		t.Fatal("Scan(abc) harus gagal")
+	}
+}